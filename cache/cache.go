@@ -1,6 +1,9 @@
 package cache
 
 import (
+	"strings"
+	"sync"
+	"sync/atomic"
 	"time"
 
 	"code.cloudfoundry.org/clock"
@@ -9,39 +12,232 @@ import (
 
 type cacheEntry struct {
 	// location is the full url to the repo on github
-	location  string
-	updatedAt time.Time
+	location      string
+	vcs           string
+	subdir        string
+	forge         Forge
+	defaultBranch string
+	description   string
+	archived      bool
+	org           string
+	updatedAt     time.Time
 }
 
+// Entry describes where a repo lives.
+type Entry struct {
+	Location string
+	// VCS is the version control system of the repo, git if empty.
+	VCS string
+	// Subdir is the directory of the Go module inside the repo, if any.
+	Subdir        string
+	Forge         Forge
+	DefaultBranch string
+	Description   string
+	Archived      bool
+	// Org is the organization the repo was found in, if any.
+	Org       string
+	UpdatedAt time.Time
+	// Age is how long ago UpdatedAt was when the entry was looked up, or
+	// zero if UpdatedAt is not set.
+	Age time.Duration `json:"-"`
+}
+
+func (item *cacheEntry) entry() Entry {
+	return Entry{
+		Location:      item.location,
+		VCS:           item.vcs,
+		Subdir:        item.subdir,
+		Forge:         item.forge,
+		DefaultBranch: item.defaultBranch,
+		Description:   item.description,
+		Archived:      item.archived,
+		Org:           item.org,
+		UpdatedAt:     item.updatedAt,
+	}
+}
+
+// GetVCS returns the version control system of the repo.
+func (e Entry) GetVCS() string {
+	if e.VCS == "" {
+		return "git"
+	}
+	return e.VCS
+}
+
+// LocationCache is the in-memory Store. It is safe for concurrent use:
+// readers load the current snapshot atomically without locks, and changes are
+// made one at a time, each publishing a new snapshot.
 type LocationCache struct {
-	items  map[string]*cacheEntry
-	logger lager.Logger
-	clock  clock.Clock
+	current atomic.Value // *snapshot
+	// writeLock serializes the changes, so that none is lost.
+	writeLock sync.Mutex
+	logger    lager.Logger
+	clock     clock.Clock
 }
 
 func NewLocationCache(logger lager.Logger, clock clock.Clock) *LocationCache {
-	return &LocationCache{
-		items:  map[string]*cacheEntry{},
+	l := &LocationCache{
 		logger: logger,
 		clock:  clock,
 	}
+	l.current.Store(newSnapshot())
+	return l
+}
+
+func (l *LocationCache) snapshot() *snapshot {
+	return l.current.Load().(*snapshot)
+}
+
+// update publishes the snapshot returned by change, and returns the snapshot
+// that was replaced. If save is not nil, a changed snapshot is only published
+// once save succeeded for it, so that the cache is left as it was otherwise.
+func (l *LocationCache) update(change func(*snapshot) *snapshot, save func(*snapshot) error) (*snapshot, error) {
+	l.writeLock.Lock()
+	defer l.writeLock.Unlock()
+
+	old := l.snapshot()
+	s := change(old)
+	if save != nil && s != old {
+		if err := save(s); err != nil {
+			return old, err
+		}
+	}
+	l.current.Store(s)
+	return old, nil
+}
+
+func (l *LocationCache) Lookup(repoName string) (string, Entry, bool) {
+	s := l.snapshot()
+	if entry, ok := s.lookup(repoName); ok {
+		return repoName, l.withAge(entry), true
+	}
+
+	name, ok := s.folded[strings.ToLower(repoName)]
+	if !ok {
+		return "", Entry{}, false
+	}
+	entry, ok := s.lookup(name)
+	return name, l.withAge(entry), ok
+}
+
+func (l *LocationCache) List() []Repo {
+	s := l.snapshot()
+	repos := make([]Repo, 0, len(s.items))
+	for _, name := range s.names() {
+		repos = append(repos, Repo{Name: name, Entry: l.withAge(s.items[name].entry())})
+	}
+	return repos
+}
+
+// ReplaceAll replaces all repos, and records the time as the refresh time.
+// Repos without an UpdatedAt time are updated now.
+func (l *LocationCache) ReplaceAll(repos []Repo) error {
+	return l.replaceAll(repos, nil)
+}
+
+func (l *LocationCache) replaceAll(repos []Repo, save func(*snapshot) error) error {
+	now := l.clock.Now()
+	s := newSnapshot()
+	for _, repo := range repos {
+		s.add(l.logger, repo.Name, newCacheEntry(repo.Entry, now))
+	}
+	s.refreshedAt = now
+
+	old, err := l.update(func(*snapshot) *snapshot {
+		return s
+	}, save)
+	if err != nil {
+		return err
+	}
+	l.logger.Info("cache-items-swap", l.swapData(old, s))
+	return nil
+}
+
+// Upsert adds or replaces a repo, updated now unless its UpdatedAt time is
+// set. If a repo whose name only differs in case was added before,
+// case-insensitive lookups resolve to this one from now on, and the conflict
+// is recorded.
+//
+// Every call copies the cache, so building a large cache this way is slow;
+// use ReplaceAll instead.
+func (l *LocationCache) Upsert(repoName string, entry Entry) error {
+	return l.upsert(repoName, entry, nil)
+}
+
+func (l *LocationCache) upsert(repoName string, entry Entry, save func(*snapshot) error) error {
+	item := newCacheEntry(entry, l.clock.Now())
+	_, err := l.update(func(old *snapshot) *snapshot {
+		s := old.clone()
+		s.add(l.logger, repoName, item)
+		return s
+	}, save)
+	return err
+}
+
+func (l *LocationCache) Delete(repoName string) error {
+	return l.delete(repoName, nil)
 }
 
-func (l *LocationCache) Lookup(repoName string) (string, bool) {
-	if item, ok := l.items[repoName]; !ok {
-		return "", false
-	} else {
-		return item.location, true
+func (l *LocationCache) delete(repoName string, save func(*snapshot) error) error {
+	_, err := l.update(func(old *snapshot) *snapshot {
+		if _, ok := old.items[repoName]; !ok {
+			return old
+		}
+		s := old.clone()
+		s.remove(repoName)
+		return s
+	}, save)
+	return err
+}
+
+func (l *LocationCache) Stats() Stats {
+	s := l.snapshot()
+	stats := Stats{
+		Repos:         len(s.items),
+		RefreshedAt:   s.refreshedAt,
+		CaseConflicts: s.conflicts,
+	}
+	if !s.refreshedAt.IsZero() {
+		stats.Age = l.clock.Since(s.refreshedAt)
 	}
+	if oldest := s.oldestUpdate(); !oldest.IsZero() {
+		stats.OldestEntryAge = l.clock.Since(oldest)
+	}
+	return stats
 }
 
+func (l *LocationCache) withAge(entry Entry) Entry {
+	if !entry.UpdatedAt.IsZero() {
+		entry.Age = l.clock.Since(entry.UpdatedAt)
+	}
+	return entry
+}
+
+// Add stores the location of a repo, guessing its forge from the location.
 func (l *LocationCache) Add(repoName, location string) {
-	l.items[repoName] = &cacheEntry{location: location, updatedAt: l.clock.Now()}
+	l.Upsert(repoName, Entry{Location: location, Forge: DetectForge(location)})
 }
 
-func (l *LocationCache) Swap(newLocationCache *LocationCache) {
-	logger := l.logger
+func (l *LocationCache) swapData(old, new *snapshot) lager.Data {
+	return lager.Data{"old_len": len(old.items), "new_len": len(new.items), "case_conflicts": len(new.conflicts)}
+}
 
-	logger.Info("cache-items-swap", lager.Data{"old_len": len(l.items), "new_len": len(newLocationCache.items)})
-	l.items = newLocationCache.items
+// newCacheEntry stores entry, updated at updatedAt unless its UpdatedAt
+// time is set.
+func newCacheEntry(entry Entry, updatedAt time.Time) *cacheEntry {
+	if !entry.UpdatedAt.IsZero() {
+		updatedAt = entry.UpdatedAt
+	}
+
+	return &cacheEntry{
+		location:      entry.Location,
+		vcs:           entry.VCS,
+		subdir:        entry.Subdir,
+		forge:         entry.Forge,
+		defaultBranch: entry.DefaultBranch,
+		description:   entry.Description,
+		archived:      entry.Archived,
+		org:           entry.Org,
+		updatedAt:     updatedAt,
+	}
 }
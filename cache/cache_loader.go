@@ -2,23 +2,69 @@ package cache
 
 import (
 	"context"
+	"errors"
+	"fmt"
+	"math/rand"
 	"os"
+	"strings"
+	"sync"
 	"time"
 
-	"github.com/google/go-github/github"
 	"code.cloudfoundry.org/clock"
 	"code.cloudfoundry.org/lager"
-	"github.com/tedsuo/ifrit"
+	"github.com/google/go-github/github"
 )
 
 const CacheUpdateInterval = 10 * time.Minute
 
-type cacheLoader struct {
-	logger        lager.Logger
-	orgs          []string
-	locationCache *LocationCache
-	repoService   RepositoriesService
-	clock         clock.Clock
+const (
+	// rateLimitReserve is the share of the GitHub API quota below which the
+	// remaining requests are spread out until the quota is reset, so that
+	// other clients sharing the token are not starved.
+	rateLimitReserve = 0.1
+	// abuseRetryAfter is how long to back off after hitting the abuse rate
+	// limit when GitHub does not say.
+	abuseRetryAfter = time.Minute
+)
+
+// errSignaled is returned when the loader was signaled while refreshing.
+var errSignaled = errors.New("signaled while refreshing")
+
+// OrgsError is returned by a refresh when some of the orgs failed, and the
+// last good repos were kept for them.
+type OrgsError struct {
+	Orgs []string
+	// Err is the error of the first of the Orgs.
+	Err error
+}
+
+func (e OrgsError) Error() string {
+	return fmt.Sprintf("failed fetching orgs %s: %s", strings.Join(e.Orgs, ", "), e.Err)
+}
+
+// CacheLoader keeps a store up to date with the repos of the orgs.
+type CacheLoader struct {
+	logger      lager.Logger
+	orgs        []string
+	store       Store
+	repoService RepositoriesService
+	clock       clock.Clock
+	retry       RetryPolicy
+	concurrency int
+	guard       DeletionGuard
+	random      *rand.Rand
+
+	// storeLock serializes the changes to the store, so that accepting a
+	// blocked refresh cannot overwrite a newer refresh, and the diffs of
+	// webhook events are not mixed up with refreshes.
+	storeLock sync.Mutex
+
+	statusLock sync.Mutex
+	status     LoaderStatus
+	orgStatus  map[string]OrgStatus
+
+	diffsLock sync.Mutex
+	diffs     []RefreshDiff
 }
 
 //go:generate counterfeiter -o fakes/fake_repositories_service.go . RepositoriesService
@@ -26,91 +72,204 @@ type RepositoriesService interface {
 	ListByOrg(ctx context.Context, org string, opt *github.RepositoryListByOrgOptions) ([]*github.Repository, *github.Response, error)
 }
 
-func NewCacheLoader(logger lager.Logger, orgs []string, locationCache *LocationCache, repoService RepositoriesService, clock clock.Clock) ifrit.Runner {
-	return &cacheLoader{
-		logger:        logger,
-		orgs:          orgs,
-		locationCache: locationCache,
-		repoService:   repoService,
-		clock:         clock,
+// NewCacheLoader returns a runner that keeps store up to date with the repos
+// of the orgs, retrying failing refreshes according to retry. It makes at
+// most concurrency requests to GitHub at a time, and guard keeps refreshes
+// that would remove too many repos from being stored.
+func NewCacheLoader(logger lager.Logger, orgs []string, store Store, repoService RepositoriesService, clock clock.Clock, retry RetryPolicy, concurrency int, guard DeletionGuard) *CacheLoader {
+	if concurrency < 1 {
+		concurrency = 1
+	}
+
+	return &CacheLoader{
+		logger:      logger,
+		orgs:        orgs,
+		store:       store,
+		repoService: repoService,
+		clock:       clock,
+		retry:       retry,
+		concurrency: concurrency,
+		guard:       guard,
+		random:      rand.New(rand.NewSource(clock.Now().UnixNano())),
+		orgStatus:   map[string]OrgStatus{},
 	}
 }
 
-func (c *cacheLoader) Run(signals <-chan os.Signal, ready chan<- struct{}) error {
+func (c *CacheLoader) Run(signals <-chan os.Signal, ready chan<- struct{}) error {
 	logger := c.logger
+	startupDeadline := c.clock.Now().Add(c.retry.StartupTimeout)
 
-	// Initialize the cache
-	err := c.updateCache(logger)
-
-	// On starup, fail if there is an error with the initial call to github,
-	// becaue it's more likely to be noticed and there's a higher change the
-	// problem is with us. During the regular interval updates of the change,
-	// don't bring the process down if there's an error talking to github, as we
-	// expect it might just be temporary downtime for github.
-	if err != nil {
-		logger.Error("failed-starting-cache-loader", err)
-		return err
+	// If the store kept the repos of an earlier refresh, e.g. on disk, it can
+	// serve right away, and is refreshed in the background.
+	if stats := c.store.Stats(); !stats.RefreshedAt.IsZero() {
+		logger.Info("serving-stored-repos", lager.Data{"repos": stats.Repos, "age": stats.Age.String()})
+		close(ready)
+		ready = nil
 	}
 
-	close(ready)
-
-	timer := c.clock.NewTimer(CacheUpdateInterval)
 	for {
+		err := c.updateCache(logger, signals)
+		if err == errSignaled {
+			return nil
+		}
+
+		delay := CacheUpdateInterval
+		if err == nil {
+			c.recordSuccess()
+			if ready != nil {
+				close(ready)
+				ready = nil
+			}
+		} else {
+			delay = c.retry.backoff(c.recordFailure(err), c.random)
+
+			// If only some orgs failed, the repos of the others were stored,
+			// and can be served while the failed orgs are retried.
+			if _, partial := err.(OrgsError); partial && ready != nil {
+				close(ready)
+				ready = nil
+			}
+
+			if ready != nil {
+				// On startup, fail if the initial call to github keeps failing,
+				// because it's more likely to be noticed and there's a higher
+				// chance the problem is with us. Later on, don't bring the
+				// process down if there's an error talking to github, as we
+				// expect it might just be temporary downtime for github.
+				remaining := startupDeadline.Sub(c.clock.Now())
+				if remaining <= 0 {
+					logger.Error("failed-starting-cache-loader", err)
+					return err
+				}
+				if delay > remaining {
+					delay = remaining
+				}
+			}
+
+			logger.Error("failed-updating-cache", err, lager.Data{
+				"oldest-repo-age": c.store.Stats().OldestEntryAge.String(),
+				"failures":        c.Status().ConsecutiveFailures,
+				"retry-in":        delay.String(),
+			})
+		}
+
+		c.setNextRefresh(c.clock.Now().Add(delay))
+		timer := c.clock.NewTimer(delay)
 		select {
 		case <-timer.C():
-			err := c.updateCache(logger)
-			if err != nil {
-				logger.Error("failed-updating-cache", err)
-			}
-			timer.Reset(CacheUpdateInterval)
 		case signal := <-signals:
-			logger.Info("signaled", lager.Data{"signal": signal.String})
+			logger.Info("signaled", lager.Data{"signal": signal.String()})
 			timer.Stop()
+			return nil
 		}
 	}
-	return nil
 }
 
-func (c *cacheLoader) updateCache(logger lager.Logger) error {
+func (c *CacheLoader) updateCache(logger lager.Logger, signals <-chan os.Signal) error {
 	logger = logger.Session("update-cache")
-	logger.Info("fetching-orgs", lager.Data{"orgs": c.orgs})
+	logger.Info("fetching-orgs", lager.Data{"orgs": c.orgs, "concurrency": c.concurrency})
 
-	tempLocationCache := NewLocationCache(c.logger, c.clock)
-	for i := len(c.orgs) - 1; i >= 0; i-- {
-		org := c.orgs[i]
-		logger.Info("fetching-org", lager.Data{"org": org})
-		opt := &github.RepositoryListByOrgOptions{
-			Type:        "public",
-			ListOptions: github.ListOptions{PerPage: 100, Page: 1},
+	// A signal stops the requests in flight, and any waits for the rate
+	// limit.
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	signaled := false
+	stopWatching := make(chan struct{})
+	stoppedWatching := make(chan struct{})
+	go func() {
+		defer close(stoppedWatching)
+		select {
+		case signal := <-signals:
+			logger.Info("signaled", lager.Data{"signal": signal.String()})
+			signaled = true
+			cancel()
+		case <-stopWatching:
 		}
+	}()
 
-		for {
-			logger.Info("fetching-page", lager.Data{"org": org, "page": opt.Page})
-			repos, resp, err := c.repoService.ListByOrg(context.Background(), org, opt)
-			if err != nil {
-				logger.Error("failed-fetching-page", err, lager.Data{"org": org, "page": opt.Page})
-				return err
-			}
+	orgRepos, errs := c.fetchOrgs(ctx, logger)
 
-			for _, repo := range repos {
-				logger.Debug("found-repo", lager.Data{"repo": repo.Name})
-				if repo.Name == nil {
-					continue
-				}
+	close(stopWatching)
+	<-stoppedWatching
+	if signaled {
+		return errSignaled
+	}
 
-				tempLocationCache.Add(*repo.Name, *repo.HTMLURL)
-			}
+	c.storeLock.Lock()
+	defer c.storeLock.Unlock()
+
+	found, err := c.mergeOrgs(logger, orgRepos, errs)
+	if _, partial := err.(OrgsError); err != nil && !partial {
+		return err
+	}
+	logger.Info("finished-fetching-orgs", lager.Data{"orgs": c.orgs})
+
+	stored := c.store.List()
+	diff := diffRepos(stored, found, c.clock.Now(), DiffSourceRefresh)
+	if err := c.guardDeletions(logger, diff, len(stored), found); err != nil {
+		return err
+	}
 
-			logger.Info("finished-page", lager.Data{"org": org, "page": opt.Page, "next": resp.NextPage, "last": resp.LastPage})
-			if resp.NextPage == 0 {
-				break
+	if err := c.store.ReplaceAll(found); err != nil {
+		logger.Error("failed-storing-repos", err)
+		return err
+	}
+	c.recordDiff(logger, diff, len(stored))
+
+	return err
+}
+
+// mergeOrgs returns the repos of all orgs, with those of the first orgs last,
+// so that they win lookups of names that only differ in case. For the orgs
+// that failed, it keeps the repos of the org in the store, which are the last
+// good ones. If all orgs failed, it returns the error of the first one
+// instead, and if some did, an OrgsError along with the repos.
+func (c *CacheLoader) mergeOrgs(logger lager.Logger, orgRepos [][]Repo, errs []error) ([]Repo, error) {
+	var (
+		found  []Repo
+		failed OrgsError
+		stored map[string][]Repo
+	)
+	for i := len(c.orgs) - 1; i >= 0; i-- {
+		org := c.orgs[i]
+		if errs[i] == nil {
+			c.recordOrgSuccess(org, len(orgRepos[i]))
+			found = append(found, orgRepos[i]...)
+			continue
+		}
+
+		if stored == nil {
+			stored = map[string][]Repo{}
+			for _, repo := range c.store.List() {
+				stored[repo.Org] = append(stored[repo.Org], repo)
 			}
-			opt.ListOptions.Page = resp.NextPage
 		}
+		failures := c.recordOrgFailure(org, errs[i])
+		logger.Error("failed-fetching-org", errs[i], lager.Data{"org": org, "failures": failures, "kept-repos": len(stored[org])})
+
+		found = append(found, stored[org]...)
+		failed.Orgs = append([]string{org}, failed.Orgs...)
+		failed.Err = errs[i]
 	}
-	logger.Info("finished-fetching-orgs", lager.Data{"orgs": c.orgs})
 
-	c.locationCache.Swap(tempLocationCache)
+	if len(failed.Orgs) == len(c.orgs) {
+		return nil, failed.Err
+	}
+	if len(failed.Orgs) > 0 {
+		return found, failed
+	}
+	return found, nil
+}
 
-	return nil
+// githubEntry returns the entry of a repo reported by the GitHub API.
+func githubEntry(repo *github.Repository, org string) Entry {
+	return Entry{
+		Location:      repo.GetHTMLURL(),
+		Forge:         githubForge(repo.GetHTMLURL()),
+		DefaultBranch: repo.GetDefaultBranch(),
+		Description:   repo.GetDescription(),
+		Archived:      repo.GetArchived(),
+		Org:           org,
+	}
 }
@@ -3,6 +3,9 @@ package cache_test
 import (
 	"context"
 	"errors"
+	"fmt"
+	"os"
+	"sync/atomic"
 	"time"
 
 	"github.com/cloudfoundry/go-fetcher/cache"
@@ -20,18 +23,20 @@ import (
 var _ = Describe("CacheLoader", func() {
 	var (
 		fakeRepoService *fakes.FakeRepositoriesService
-		cacheLoader     ifrit.Runner
+		cacheLoader     *cache.CacheLoader
 		locCache        *cache.LocationCache
 		fakeClock       *fakeclock.FakeClock
+		retryPolicy     cache.RetryPolicy
 	)
 
 	BeforeEach(func() {
 		fakeRepoService = &fakes.FakeRepositoriesService{}
 		fakeClock = fakeclock.NewFakeClock(time.Now())
+		retryPolicy = cache.RetryPolicy{InitialBackoff: time.Second, MaxBackoff: time.Minute}
 		cacheLogger := lagertest.NewTestLogger("cache")
 		locCache = cache.NewLocationCache(cacheLogger, clock.NewClock())
 		logger := lagertest.NewTestLogger("cache-loader")
-		cacheLoader = cache.NewCacheLoader(logger, []string{"org1", "org2"}, locCache, fakeRepoService, fakeClock)
+		cacheLoader = cache.NewCacheLoader(logger, []string{"org1", "org2"}, locCache, fakeRepoService, fakeClock, retryPolicy, 1, cache.DeletionGuard{})
 		fakeRepoService.ListByOrgReturns(nil, &github.Response{}, nil)
 	})
 
@@ -58,11 +63,13 @@ var _ = Describe("CacheLoader", func() {
 	It("requests all repos for each org", func() {
 		ifrit.Invoke(cacheLoader)
 
-		_, org, _ := fakeRepoService.ListByOrgArgsForCall(0)
-		Expect(org).To(Equal("org2"))
-
-		_, org, _ = fakeRepoService.ListByOrgArgsForCall(1)
-		Expect(org).To(Equal("org1"))
+		var orgs []string
+		for i := 0; i < fakeRepoService.ListByOrgCallCount(); i++ {
+			_, org, opt := fakeRepoService.ListByOrgArgsForCall(i)
+			Expect(opt.Type).To(Equal("public"))
+			orgs = append(orgs, org)
+		}
+		Expect(orgs).To(ConsistOf("org1", "org2"))
 	})
 
 	It("stores the repos in the cache", func() {
@@ -82,23 +89,55 @@ var _ = Describe("CacheLoader", func() {
 		}
 
 		ifrit.Invoke(cacheLoader)
-		storedLocation, foundInCache := locCache.Lookup("repo1")
+		_, stored, foundInCache := locCache.Lookup("repo1")
 
 		Expect(foundInCache).To(BeTrue())
-		Expect(storedLocation).To(Equal("http://example.com/org1/repo1"))
-		storedLocation, foundInCache = locCache.Lookup("repo2")
+		Expect(stored.Location).To(Equal("http://example.com/org1/repo1"))
+		_, stored, foundInCache = locCache.Lookup("repo2")
 
 		Expect(foundInCache).To(BeTrue())
-		Expect(storedLocation).To(Equal("http://example.com/org2/repo2"))
+		Expect(stored.Location).To(Equal("http://example.com/org2/repo2"))
+	})
+
+	It("records the forge, default branch, description and archived status of the repos", func() {
+		fakeRepoService.ListByOrgStub = func(_ context.Context, org string, _ *github.RepositoryListByOrgOptions) ([]*github.Repository, *github.Response, error) {
+			if org == "org1" {
+				name := "repo1"
+				url := "https://github.com/org1/repo1"
+				branch := "main"
+				description := "The first repo"
+				archived := true
+				return []*github.Repository{{Name: &name, HTMLURL: &url, DefaultBranch: &branch, Description: &description, Archived: &archived}}, &github.Response{}, nil
+			}
+
+			name := "repo2"
+			url := "https://github.example.com/org2/repo2"
+			return []*github.Repository{{Name: &name, HTMLURL: &url}}, &github.Response{}, nil
+		}
+
+		ifrit.Invoke(cacheLoader)
+
+		_, entry, ok := locCache.Lookup("repo1")
+		Expect(ok).To(BeTrue())
+		Expect(entry.Forge).To(Equal(cache.ForgeGitHub))
+		Expect(entry.DefaultBranch).To(Equal("main"))
+		Expect(entry.Description).To(Equal("The first repo"))
+		Expect(entry.Archived).To(BeTrue())
+		Expect(entry.Org).To(Equal("org1"))
+
+		_, entry, ok = locCache.Lookup("repo2")
+		Expect(ok).To(BeTrue())
+		Expect(entry.Forge).To(Equal(cache.ForgeGitHubEnterprise))
+		Expect(entry.DefaultBranch).To(BeEmpty())
+		Expect(entry.Description).To(BeEmpty())
+		Expect(entry.Archived).To(BeFalse())
 	})
 
 	It("follows the NextPage link in paginated results", func() {
-		nextPage := 0
 		fakeRepoService.ListByOrgStub = func(_ context.Context, org string, opt *github.RepositoryListByOrgOptions) ([]*github.Repository, *github.Response, error) {
-			if nextPage == 3 {
+			nextPage := opt.Page + 1
+			if opt.Page == 4 {
 				nextPage = 0 // 0 signals no more pages
-			} else {
-				nextPage++
 			}
 			return []*github.Repository{
 					&github.Repository{},
@@ -106,16 +145,34 @@ var _ = Describe("CacheLoader", func() {
 				},
 				&github.Response{
 					NextPage: nextPage,
-					LastPage: 3,
 				}, nil
 		}
 
 		ifrit.Invoke(cacheLoader)
 
-		// Expect 8 calls because we have 2 organizations, and each have 4 pages (0,1,2,3)
+		// Expect 8 calls because we have 2 organizations, and each have 4 pages (1,2,3,4)
 		Expect(fakeRepoService.ListByOrgCallCount()).To(Equal(8))
 	})
 
+	It("fetches all pages up to the LastPage of the first one", func() {
+		fakeRepoService.ListByOrgStub = func(_ context.Context, org string, opt *github.RepositoryListByOrgOptions) ([]*github.Repository, *github.Response, error) {
+			name := fmt.Sprintf("%s-repo%d", org, opt.Page)
+			resp := &github.Response{}
+			if opt.Page == 1 {
+				resp.NextPage = 2
+				resp.LastPage = 3
+			}
+			return []*github.Repository{{Name: &name}}, resp, nil
+		}
+
+		ifrit.Invoke(cacheLoader)
+
+		Expect(fakeRepoService.ListByOrgCallCount()).To(Equal(6))
+		Expect(locCache.Stats().Repos).To(Equal(6))
+		_, _, ok := locCache.Lookup("org2-repo3")
+		Expect(ok).To(BeTrue())
+	})
+
 	It("updates the cache periodically", func() {
 		ifrit.Invoke(cacheLoader)
 
@@ -145,10 +202,29 @@ var _ = Describe("CacheLoader", func() {
 		}
 
 		ifrit.Invoke(cacheLoader)
-		storedLocation, foundInCache := locCache.Lookup("repo1")
+		_, stored, foundInCache := locCache.Lookup("repo1")
 
 		Expect(foundInCache).To(BeTrue())
-		Expect(storedLocation).To(Equal("http://example.com/org1/repo1"))
+		Expect(stored.Location).To(Equal("http://example.com/org1/repo1"))
+	})
+
+	It("prefers the first org for case-insensitive lookups and records the conflict", func() {
+		fakeRepoService.ListByOrgStub = func(_ context.Context, org string, _ *github.RepositoryListByOrgOptions) ([]*github.Repository, *github.Response, error) {
+			name := "routing-api"
+			if org == "org2" {
+				name = "Routing-API"
+			}
+			url := "http://example.com/" + org + "/" + name
+			return []*github.Repository{{Name: &name, HTMLURL: &url}}, &github.Response{}, nil
+		}
+
+		ifrit.Invoke(cacheLoader)
+		name, entry, ok := locCache.Lookup("ROUTING-API")
+
+		Expect(ok).To(BeTrue())
+		Expect(name).To(Equal("routing-api"))
+		Expect(entry.Org).To(Equal("org1"))
+		Expect(locCache.Stats().CaseConflicts["routing-api"]).To(Equal([]string{"Routing-API", "routing-api"}))
 	})
 
 	It("Forgets deleted repos when a new location cache is generated", func() {
@@ -168,7 +244,7 @@ var _ = Describe("CacheLoader", func() {
 		}
 
 		ifrit.Invoke(cacheLoader)
-		_, firstFoundInCache := locCache.Lookup("first-repo")
+		_, _, firstFoundInCache := locCache.Lookup("first-repo")
 		Expect(firstFoundInCache).To(BeTrue())
 
 		fakeRepoService.ListByOrgStub = func(_ context.Context, org string, _ *github.RepositoryListByOrgOptions) ([]*github.Repository, *github.Response, error) {
@@ -188,7 +264,496 @@ var _ = Describe("CacheLoader", func() {
 
 		fakeClock.WaitForWatcherAndIncrement(cache.CacheUpdateInterval)
 		fakeClock.WaitForWatcherAndIncrement(1)
-		_, firstFoundInCache = locCache.Lookup("first-repo")
+		_, _, firstFoundInCache = locCache.Lookup("first-repo")
 		Expect(firstFoundInCache).To(BeFalse())
 	})
+
+	Context("when the store has the repos of an earlier refresh", func() {
+		var refreshedAt time.Time
+
+		BeforeEach(func() {
+			refreshedAt = fakeClock.Now()
+			locCache = cache.NewLocationCache(lagertest.NewTestLogger("cache"), fakeClock)
+			locCache.ReplaceAll([]cache.Repo{
+				{Name: "saved-repo", Entry: cache.Entry{Location: "http://example.com/org1/saved-repo"}},
+			})
+			fakeClock.Increment(time.Hour)
+
+			cacheLoader = cache.NewCacheLoader(lagertest.NewTestLogger("cache-loader"), []string{"org1", "org2"}, locCache, fakeRepoService, fakeClock, retryPolicy, 1, cache.DeletionGuard{})
+		})
+
+		It("becomes ready with the stored repos before querying github", func() {
+			doneCh := make(chan struct{})
+			fakeRepoService.ListByOrgStub = func(_ context.Context, org string, opt *github.RepositoryListByOrgOptions) ([]*github.Repository, *github.Response, error) {
+				<-doneCh
+				return nil, &github.Response{}, nil
+			}
+
+			cacheLoaderProcess := ifrit.Background(cacheLoader)
+			Eventually(cacheLoaderProcess.Ready()).Should(BeClosed())
+
+			_, _, ok := locCache.Lookup("saved-repo")
+			Expect(ok).To(BeTrue())
+			Expect(locCache.Stats().RefreshedAt).To(Equal(refreshedAt))
+			Expect(locCache.Stats().Age).To(Equal(time.Hour))
+
+			close(doneCh)
+			Eventually(locCache.List).Should(BeEmpty())
+		})
+
+		It("keeps serving the stored repos when github fails", func() {
+			fakeRepoService.ListByOrgReturns(nil, nil, errors.New("github is down"))
+
+			cacheLoaderProcess := ifrit.Background(cacheLoader)
+			Eventually(cacheLoaderProcess.Ready()).Should(BeClosed())
+			Consistently(cacheLoaderProcess.Wait()).ShouldNot(Receive())
+
+			_, _, ok := locCache.Lookup("saved-repo")
+			Expect(ok).To(BeTrue())
+		})
+	})
+
+	Context("when fetching concurrently", func() {
+		newLoader := func(orgs []string, concurrency int) *cache.CacheLoader {
+			return cache.NewCacheLoader(lagertest.NewTestLogger("cache-loader"), orgs, locCache, fakeRepoService, fakeClock, retryPolicy, concurrency, cache.DeletionGuard{})
+		}
+
+		It("fetches the orgs and the pages once the last page is known at the same time", func() {
+			release := make(chan struct{})
+			fakeRepoService.ListByOrgStub = func(_ context.Context, org string, opt *github.RepositoryListByOrgOptions) ([]*github.Repository, *github.Response, error) {
+				if opt.Page == 1 {
+					return nil, &github.Response{NextPage: 2, LastPage: 3}, nil
+				}
+				<-release
+				return nil, &github.Response{}, nil
+			}
+
+			cacheLoaderProcess := ifrit.Background(newLoader([]string{"org1", "org2"}, 4))
+			Eventually(fakeRepoService.ListByOrgCallCount).Should(Equal(6))
+			Consistently(fakeRepoService.ListByOrgCallCount).Should(Equal(6))
+
+			close(release)
+			Eventually(cacheLoaderProcess.Ready()).Should(BeClosed())
+		})
+
+		It("makes no more requests at a time than the limit", func() {
+			var inFlight, maxInFlight int32
+			fakeRepoService.ListByOrgStub = func(_ context.Context, org string, opt *github.RepositoryListByOrgOptions) ([]*github.Repository, *github.Response, error) {
+				n := atomic.AddInt32(&inFlight, 1)
+				defer atomic.AddInt32(&inFlight, -1)
+				for {
+					max := atomic.LoadInt32(&maxInFlight)
+					if n <= max || atomic.CompareAndSwapInt32(&maxInFlight, max, n) {
+						break
+					}
+				}
+				time.Sleep(time.Millisecond)
+
+				name := fmt.Sprintf("%s-repo%d", org, opt.Page)
+				return []*github.Repository{{Name: &name}}, &github.Response{LastPage: 3}, nil
+			}
+
+			ifrit.Invoke(newLoader([]string{"org1", "org2", "org3", "org4"}, 3))
+
+			Expect(fakeRepoService.ListByOrgCallCount()).To(Equal(12))
+			Expect(locCache.Stats().Repos).To(Equal(12))
+			Expect(atomic.LoadInt32(&maxInFlight)).To(BeNumerically("<=", 3))
+		})
+
+		It("prefers the first org for each repo no matter which org finishes first", func() {
+			orgs := []string{"org1", "org2", "org3"}
+			completionOrders := [][]string{
+				{"org1", "org2", "org3"},
+				{"org1", "org3", "org2"},
+				{"org2", "org1", "org3"},
+				{"org2", "org3", "org1"},
+				{"org3", "org1", "org2"},
+				{"org3", "org2", "org1"},
+			}
+
+			for _, completionOrder := range completionOrders {
+				fakeRepoService = &fakes.FakeRepositoriesService{}
+				locCache = cache.NewLocationCache(lagertest.NewTestLogger("cache"), fakeClock)
+
+				release := map[string]chan struct{}{}
+				for _, org := range orgs {
+					release[org] = make(chan struct{})
+				}
+				finished := make(chan string, len(orgs))
+				fakeRepoService.ListByOrgStub = func(_ context.Context, org string, opt *github.RepositoryListByOrgOptions) ([]*github.Repository, *github.Response, error) {
+					<-release[org]
+					defer func() { finished <- org }()
+
+					shared, sharedCase := "shared", "Shared"
+					url := "https://github.com/" + org + "/shared"
+					caseURL := "https://github.com/" + org + "/Shared"
+					repos := []*github.Repository{{Name: &shared, HTMLURL: &url}}
+					if org != "org1" {
+						repos = append(repos, &github.Repository{Name: &sharedCase, HTMLURL: &caseURL})
+					}
+					return repos, &github.Response{}, nil
+				}
+
+				cacheLoaderProcess := ifrit.Background(newLoader(orgs, len(orgs)))
+				Eventually(fakeRepoService.ListByOrgCallCount).Should(Equal(len(orgs)))
+				for _, org := range completionOrder {
+					close(release[org])
+					Eventually(finished).Should(Receive(Equal(org)))
+				}
+				Eventually(cacheLoaderProcess.Ready()).Should(BeClosed(), "completion order %v", completionOrder)
+
+				name, entry, ok := locCache.Lookup("shared")
+				Expect(ok).To(BeTrue())
+				Expect(name).To(Equal("shared"))
+				Expect(entry.Org).To(Equal("org1"), "completion order %v", completionOrder)
+
+				_, entry, ok = locCache.Lookup("Shared")
+				Expect(ok).To(BeTrue())
+				Expect(entry.Org).To(Equal("org2"), "completion order %v", completionOrder)
+
+				name, _, ok = locCache.Lookup("SHARED")
+				Expect(ok).To(BeTrue())
+				Expect(name).To(Equal("shared"), "completion order %v", completionOrder)
+
+				cacheLoaderProcess.Signal(os.Interrupt)
+				Eventually(cacheLoaderProcess.Wait()).Should(Receive())
+			}
+		})
+
+		It("stops fetching the other pages of an org when one of them fails", func() {
+			fakeRepoService.ListByOrgStub = func(ctx context.Context, org string, opt *github.RepositoryListByOrgOptions) ([]*github.Repository, *github.Response, error) {
+				name := fmt.Sprintf("%s-repo%d", org, opt.Page)
+				switch {
+				case opt.Page == 1:
+					return []*github.Repository{{Name: &name}}, &github.Response{NextPage: 2, LastPage: 3}, nil
+				case org == "org1" && opt.Page == 2:
+					return nil, nil, errors.New("github is down")
+				case org == "org1":
+					<-ctx.Done()
+					return nil, nil, ctx.Err()
+				}
+				return []*github.Repository{{Name: &name}}, &github.Response{}, nil
+			}
+
+			cacheLoaderProcess := ifrit.Background(newLoader([]string{"org1", "org2"}, 4))
+			Eventually(cacheLoaderProcess.Ready()).Should(BeClosed())
+
+			_, _, ok := locCache.Lookup("org2-repo3")
+			Expect(ok).To(BeTrue())
+			_, _, ok = locCache.Lookup("org1-repo1")
+			Expect(ok).To(BeFalse())
+		})
+	})
+
+	Context("when some orgs fail", func() {
+		var failingOrgs map[string]bool
+
+		BeforeEach(func() {
+			failingOrgs = map[string]bool{}
+			fakeRepoService.ListByOrgStub = func(_ context.Context, org string, opt *github.RepositoryListByOrgOptions) ([]*github.Repository, *github.Response, error) {
+				if failingOrgs[org] {
+					return nil, nil, errors.New(org + " is down")
+				}
+				name, newName := "repo", "new-repo"
+				url := "https://github.com/" + org + "/repo"
+				newURL := "https://github.com/" + org + "/new-repo"
+				return []*github.Repository{{Name: &name, HTMLURL: &url}, {Name: &newName, HTMLURL: &newURL}}, &github.Response{}, nil
+			}
+
+			locCache = cache.NewLocationCache(lagertest.NewTestLogger("cache"), fakeClock)
+			locCache.ReplaceAll([]cache.Repo{
+				{Name: "repo", Entry: cache.Entry{Location: "https://github.com/org2/repo", Org: "org2"}},
+				{Name: "old-repo", Entry: cache.Entry{Location: "https://github.com/org2/old-repo", Org: "org2"}},
+				{Name: "repo", Entry: cache.Entry{Location: "https://github.com/org1/repo", Org: "org1"}},
+				{Name: "gone-repo", Entry: cache.Entry{Location: "https://github.com/org1/gone-repo", Org: "org1"}},
+			})
+			fakeClock.Increment(time.Hour)
+			cacheLoader = cache.NewCacheLoader(lagertest.NewTestLogger("cache-loader"), []string{"org1", "org2"}, locCache, fakeRepoService, fakeClock, retryPolicy, 2, cache.DeletionGuard{})
+		})
+
+		It("keeps the last good repos of the failed orgs and updates the others", func() {
+			failingOrgs["org2"] = true
+
+			cacheLoaderProcess := ifrit.Background(cacheLoader)
+			Eventually(func() int { return cacheLoader.Status().ConsecutiveFailures }).Should(Equal(1))
+			Expect(cacheLoader.Status().LastError).To(Equal("failed fetching orgs org2: org2 is down"))
+
+			_, entry, ok := locCache.Lookup("old-repo")
+			Expect(ok).To(BeTrue())
+			Expect(entry.Org).To(Equal("org2"))
+			Expect(entry.Age).To(Equal(time.Hour))
+
+			_, entry, ok = locCache.Lookup("new-repo")
+			Expect(ok).To(BeTrue())
+			Expect(entry.Org).To(Equal("org1"))
+			Expect(entry.Age).To(BeZero())
+
+			_, _, ok = locCache.Lookup("gone-repo")
+			Expect(ok).To(BeFalse())
+
+			_, entry, ok = locCache.Lookup("repo")
+			Expect(ok).To(BeTrue())
+			Expect(entry.Org).To(Equal("org1"))
+
+			Expect(cacheLoader.Status().Orgs).To(Equal([]cache.OrgStatus{
+				{Org: "org1", Repos: 2, LastSuccess: fakeClock.Now()},
+				{Org: "org2", ConsecutiveFailures: 1, LastError: "org2 is down"},
+			}))
+
+			failingOrgs["org2"] = false
+			fakeClock.WaitForWatcherAndIncrement(time.Second)
+			Eventually(func() int { return cacheLoader.Status().ConsecutiveFailures }).Should(BeZero())
+			Expect(cacheLoader.Status().Orgs[1]).To(Equal(cache.OrgStatus{Org: "org2", Repos: 2, LastSuccess: fakeClock.Now()}))
+
+			_, _, ok = locCache.Lookup("old-repo")
+			Expect(ok).To(BeFalse())
+			Consistently(cacheLoaderProcess.Wait()).ShouldNot(Receive())
+		})
+
+		It("keeps the first org winning when it failed", func() {
+			failingOrgs["org1"] = true
+
+			ifrit.Background(cacheLoader)
+			Eventually(func() int { return cacheLoader.Status().ConsecutiveFailures }).Should(Equal(1))
+
+			_, entry, ok := locCache.Lookup("repo")
+			Expect(ok).To(BeTrue())
+			Expect(entry.Location).To(Equal("https://github.com/org1/repo"))
+			_, entry, ok = locCache.Lookup("new-repo")
+			Expect(ok).To(BeTrue())
+			Expect(entry.Org).To(Equal("org2"))
+		})
+
+		It("keeps the store as it is when all orgs fail", func() {
+			failingOrgs["org1"] = true
+			failingOrgs["org2"] = true
+
+			ifrit.Background(cacheLoader)
+			Eventually(func() int { return cacheLoader.Status().ConsecutiveFailures }).Should(Equal(1))
+			Expect(cacheLoader.Status().LastError).To(Equal("org1 is down"))
+			Expect(locCache.Stats().Age).To(Equal(time.Hour))
+			Expect(locCache.Stats().Repos).To(Equal(3))
+		})
+
+		Context("on startup", func() {
+			BeforeEach(func() {
+				locCache = cache.NewLocationCache(lagertest.NewTestLogger("cache"), fakeClock)
+				cacheLoader = cache.NewCacheLoader(lagertest.NewTestLogger("cache-loader"), []string{"org1", "org2"}, locCache, fakeRepoService, fakeClock, retryPolicy, 2, cache.DeletionGuard{})
+			})
+
+			It("becomes ready with the repos of the other orgs", func() {
+				failingOrgs["org2"] = true
+
+				cacheLoaderProcess := ifrit.Background(cacheLoader)
+				Eventually(cacheLoaderProcess.Ready()).Should(BeClosed())
+
+				_, _, ok := locCache.Lookup("new-repo")
+				Expect(ok).To(BeTrue())
+				Expect(cacheLoader.Status().ConsecutiveFailures).To(Equal(1))
+			})
+		})
+	})
+
+	Context("when refreshing fails", func() {
+		var failUntil time.Time
+
+		BeforeEach(func() {
+			failUntil = time.Time{}
+			fakeRepoService.ListByOrgStub = func(_ context.Context, org string, opt *github.RepositoryListByOrgOptions) ([]*github.Repository, *github.Response, error) {
+				if fakeClock.Now().Before(failUntil) {
+					return nil, nil, errors.New("github is down")
+				}
+				return nil, &github.Response{}, nil
+			}
+		})
+
+		Context("on startup", func() {
+			BeforeEach(func() {
+				retryPolicy.StartupTimeout = 10 * time.Second
+				cacheLoader = cache.NewCacheLoader(lagertest.NewTestLogger("cache-loader"), []string{"org1", "org2"}, locCache, fakeRepoService, fakeClock, retryPolicy, 1, cache.DeletionGuard{})
+			})
+
+			It("retries with exponential backoff before becoming ready", func() {
+				start := fakeClock.Now()
+				failUntil = start.Add(3 * time.Second)
+
+				cacheLoaderProcess := ifrit.Background(cacheLoader)
+				Eventually(func() int { return cacheLoader.Status().ConsecutiveFailures }).Should(Equal(1))
+				Expect(cacheLoader.Status().LastError).To(Equal("github is down"))
+				Expect(cacheLoader.Status().NextRefresh).To(Equal(start.Add(time.Second)))
+
+				fakeClock.WaitForWatcherAndIncrement(time.Second)
+				Eventually(func() int { return cacheLoader.Status().ConsecutiveFailures }).Should(Equal(2))
+				Expect(cacheLoader.Status().NextRefresh).To(Equal(start.Add(3 * time.Second)))
+				Consistently(cacheLoaderProcess.Ready()).ShouldNot(BeClosed())
+
+				fakeClock.WaitForWatcherAndIncrement(2 * time.Second)
+				Eventually(cacheLoaderProcess.Ready()).Should(BeClosed())
+
+				status := cacheLoader.Status()
+				Expect(status.ConsecutiveFailures).To(BeZero())
+				Expect(status.LastError).To(BeEmpty())
+				Expect(status.LastSuccess).To(Equal(start.Add(3 * time.Second)))
+				Expect(status.NextRefresh).To(Equal(start.Add(3*time.Second + cache.CacheUpdateInterval)))
+			})
+
+			It("gives up once the startup timeout has passed", func() {
+				failUntil = fakeClock.Now().Add(time.Hour)
+
+				cacheLoaderProcess := ifrit.Background(cacheLoader)
+				for _, backoff := range []time.Duration{1, 2, 4, 3} {
+					fakeClock.WaitForWatcherAndIncrement(backoff * time.Second)
+				}
+
+				Eventually(cacheLoaderProcess.Wait()).Should(Receive(MatchError("github is down")))
+				Expect(fakeRepoService.ListByOrgCallCount()).To(Equal(10))
+			})
+		})
+
+		Context("after startup", func() {
+			It("retries with exponential backoff up to the maximum and then resumes the regular interval", func() {
+				cacheLoaderProcess := ifrit.Background(cacheLoader)
+				Eventually(cacheLoaderProcess.Ready()).Should(BeClosed())
+
+				failUntil = fakeClock.Now().Add(time.Hour)
+				fakeClock.WaitForWatcherAndIncrement(cache.CacheUpdateInterval)
+				for _, backoff := range []time.Duration{1, 2, 4, 8, 16, 32, 60, 60} {
+					Eventually(func() time.Time { return cacheLoader.Status().NextRefresh }).Should(Equal(fakeClock.Now().Add(backoff * time.Second)))
+					fakeClock.WaitForWatcherAndIncrement(backoff * time.Second)
+				}
+				Eventually(func() int { return cacheLoader.Status().ConsecutiveFailures }).Should(Equal(9))
+
+				failUntil = time.Time{}
+				fakeClock.WaitForWatcherAndIncrement(time.Minute)
+				Eventually(func() int { return cacheLoader.Status().ConsecutiveFailures }).Should(BeZero())
+				Expect(cacheLoader.Status().NextRefresh).To(Equal(fakeClock.Now().Add(cache.CacheUpdateInterval)))
+				Consistently(cacheLoaderProcess.Wait()).ShouldNot(Receive())
+			})
+		})
+
+		It("shortens the backoff by up to the jitter", func() {
+			retryPolicy.InitialBackoff = time.Minute
+			retryPolicy.Jitter = 0.5
+			retryPolicy.StartupTimeout = time.Hour
+			cacheLoader = cache.NewCacheLoader(lagertest.NewTestLogger("cache-loader"), []string{"org1", "org2"}, locCache, fakeRepoService, fakeClock, retryPolicy, 1, cache.DeletionGuard{})
+			failUntil = fakeClock.Now().Add(time.Second)
+
+			cacheLoaderProcess := ifrit.Background(cacheLoader)
+			Eventually(func() int { return cacheLoader.Status().ConsecutiveFailures }).Should(Equal(1))
+			nextRefresh := cacheLoader.Status().NextRefresh
+			Expect(nextRefresh).To(BeTemporally(">=", fakeClock.Now().Add(30*time.Second)))
+			Expect(nextRefresh).To(BeTemporally("<=", fakeClock.Now().Add(time.Minute)))
+
+			fakeClock.WaitForWatcherAndIncrement(time.Minute)
+			Eventually(cacheLoaderProcess.Ready()).Should(BeClosed())
+		})
+	})
+
+	Context("when github rate limits the requests", func() {
+		var reset time.Time
+
+		BeforeEach(func() {
+			reset = fakeClock.Now().Add(30 * time.Minute)
+		})
+
+		It("waits for the rate limit to reset and tries again", func() {
+			fakeRepoService.ListByOrgStub = func(_ context.Context, org string, opt *github.RepositoryListByOrgOptions) ([]*github.Repository, *github.Response, error) {
+				if fakeRepoService.ListByOrgCallCount() == 1 {
+					return nil, nil, &github.RateLimitError{Rate: github.Rate{Limit: 60, Remaining: 0, Reset: github.Timestamp{Time: reset}}}
+				}
+				return nil, &github.Response{}, nil
+			}
+
+			cacheLoaderProcess := ifrit.Background(cacheLoader)
+			Eventually(cacheLoader.Status).Should(Equal(cache.LoaderStatus{
+				RateLimit:        &cache.RateLimit{Limit: 60, Remaining: 0, Reset: reset},
+				RateLimitedUntil: reset,
+			}))
+			Consistently(cacheLoaderProcess.Ready()).ShouldNot(BeClosed())
+
+			fakeClock.WaitForWatcherAndIncrement(30 * time.Minute)
+			Eventually(cacheLoaderProcess.Ready()).Should(BeClosed())
+
+			Expect(fakeRepoService.ListByOrgCallCount()).To(Equal(3))
+			_, limitedOrg, _ := fakeRepoService.ListByOrgArgsForCall(0)
+			_, org, _ := fakeRepoService.ListByOrgArgsForCall(1)
+			Expect(org).To(Equal(limitedOrg))
+			Expect(cacheLoader.Status().RateLimitedUntil).To(BeZero())
+		})
+
+		It("waits as long as github asks when hitting the abuse rate limit", func() {
+			retryAfter := 10 * time.Second
+			fakeRepoService.ListByOrgStub = func(_ context.Context, org string, opt *github.RepositoryListByOrgOptions) ([]*github.Repository, *github.Response, error) {
+				if fakeRepoService.ListByOrgCallCount() == 1 {
+					return nil, nil, &github.AbuseRateLimitError{RetryAfter: &retryAfter}
+				}
+				return nil, &github.Response{}, nil
+			}
+
+			cacheLoaderProcess := ifrit.Background(cacheLoader)
+			Eventually(cacheLoader.Status).Should(Equal(cache.LoaderStatus{RateLimitedUntil: fakeClock.Now().Add(retryAfter)}))
+
+			fakeClock.WaitForWatcherAndIncrement(retryAfter)
+			Eventually(cacheLoaderProcess.Ready()).Should(BeClosed())
+			Expect(fakeRepoService.ListByOrgCallCount()).To(Equal(3))
+		})
+
+		It("defers the next page until the quota is reset once it is used up", func() {
+			fakeRepoService.ListByOrgStub = func(_ context.Context, org string, opt *github.RepositoryListByOrgOptions) ([]*github.Repository, *github.Response, error) {
+				if fakeRepoService.ListByOrgCallCount() == 1 {
+					return nil, &github.Response{NextPage: 2, Rate: github.Rate{Limit: 60, Remaining: 0, Reset: github.Timestamp{Time: reset}}}, nil
+				}
+				return nil, &github.Response{Rate: github.Rate{Limit: 60, Remaining: 59, Reset: github.Timestamp{Time: reset.Add(time.Hour)}}}, nil
+			}
+
+			cacheLoaderProcess := ifrit.Background(cacheLoader)
+			Eventually(func() time.Time { return cacheLoader.Status().RateLimitedUntil }).Should(Equal(reset))
+			Expect(fakeRepoService.ListByOrgCallCount()).To(Equal(1))
+
+			fakeClock.WaitForWatcherAndIncrement(30 * time.Minute)
+			Eventually(cacheLoaderProcess.Ready()).Should(BeClosed())
+			Expect(fakeRepoService.ListByOrgCallCount()).To(Equal(3))
+			Expect(cacheLoader.Status().RateLimit).To(Equal(&cache.RateLimit{Limit: 60, Remaining: 59, Reset: reset.Add(time.Hour)}))
+		})
+
+		It("spreads out the requests when the quota runs low", func() {
+			fakeRepoService.ListByOrgStub = func(_ context.Context, org string, opt *github.RepositoryListByOrgOptions) ([]*github.Repository, *github.Response, error) {
+				return nil, &github.Response{Rate: github.Rate{Limit: 5000, Remaining: 29, Reset: github.Timestamp{Time: reset}}}, nil
+			}
+
+			cacheLoaderProcess := ifrit.Background(cacheLoader)
+			Eventually(func() time.Time { return cacheLoader.Status().RateLimitedUntil }).Should(Equal(fakeClock.Now().Add(time.Minute)))
+			Expect(fakeRepoService.ListByOrgCallCount()).To(Equal(1))
+
+			fakeClock.WaitForWatcherAndIncrement(time.Minute)
+			Eventually(cacheLoaderProcess.Ready()).Should(BeClosed())
+			Expect(fakeRepoService.ListByOrgCallCount()).To(Equal(2))
+		})
+
+		It("exits when signaled while waiting", func() {
+			fakeRepoService.ListByOrgReturns(nil, nil, &github.RateLimitError{Rate: github.Rate{Limit: 60, Reset: github.Timestamp{Time: reset}}})
+
+			cacheLoaderProcess := ifrit.Background(cacheLoader)
+			Eventually(func() time.Time { return cacheLoader.Status().RateLimitedUntil }).Should(Equal(reset))
+
+			cacheLoaderProcess.Signal(os.Interrupt)
+			Eventually(cacheLoaderProcess.Wait()).Should(Receive(BeNil()))
+		})
+	})
+
+	Context("when the store fails", func() {
+		var fakeStore *fakes.FakeStore
+
+		BeforeEach(func() {
+			fakeStore = &fakes.FakeStore{}
+			fakeStore.ReplaceAllReturns(errors.New("disk full"))
+			cacheLoader = cache.NewCacheLoader(lagertest.NewTestLogger("cache-loader"), []string{"org1", "org2"}, fakeStore, fakeRepoService, fakeClock, retryPolicy, 1, cache.DeletionGuard{})
+		})
+
+		It("fails to start", func() {
+			cacheLoaderProcess := ifrit.Background(cacheLoader)
+			Eventually(cacheLoaderProcess.Wait()).Should(Receive(MatchError("disk full")))
+			Expect(fakeStore.ReplaceAllCallCount()).To(Equal(1))
+		})
+	})
 })
@@ -1,6 +1,8 @@
 package cache_test
 
 import (
+	"fmt"
+	"sync"
 	"time"
 
 	"github.com/cloudfoundry/go-fetcher/cache"
@@ -24,7 +26,7 @@ var _ = Describe("Location Cache", func() {
 	Describe("Lookup", func() {
 		Context("when there is nothing in the cache", func() {
 			It("returns not ok", func() {
-				_, ok := locationCache.Lookup("something")
+				_, _, ok := locationCache.Lookup("something")
 				Expect(ok).To(BeFalse())
 			})
 		})
@@ -35,34 +37,312 @@ var _ = Describe("Location Cache", func() {
 			})
 
 			It("returns ok", func() {
-				_, ok := locationCache.Lookup("repo-name")
+				_, _, ok := locationCache.Lookup("repo-name")
 				Expect(ok).To(BeTrue())
 			})
 
 			It("returns the cached location", func() {
-				location, _ := locationCache.Lookup("repo-name")
-				Expect(location).To(Equal("cached-location"))
+				name, entry, _ := locationCache.Lookup("repo-name")
+				Expect(name).To(Equal("repo-name"))
+				Expect(entry.Location).To(Equal("cached-location"))
 			})
 		})
+
+		Context("when the entry was upserted with all of its attributes", func() {
+			BeforeEach(func() {
+				Expect(locationCache.Upsert("repo-name", cache.Entry{
+					Location:      "https://github.example.com/org/repo-name",
+					VCS:           "hg",
+					Subdir:        "sdk/go",
+					Forge:         cache.ForgeGitHubEnterprise,
+					DefaultBranch: "main",
+					Description:   "A repo",
+					Archived:      true,
+					Org:           "org",
+				})).To(Succeed())
+			})
+
+			It("returns the whole entry", func() {
+				_, entry, ok := locationCache.Lookup("repo-name")
+				Expect(ok).To(BeTrue())
+				Expect(entry).To(Equal(cache.Entry{
+					Location:      "https://github.example.com/org/repo-name",
+					VCS:           "hg",
+					Subdir:        "sdk/go",
+					Forge:         cache.ForgeGitHubEnterprise,
+					DefaultBranch: "main",
+					Description:   "A repo",
+					Archived:      true,
+					Org:           "org",
+					UpdatedAt:     clock.Now(),
+				}))
+			})
+		})
+
+		Context("when the entry was added with only a location", func() {
+			BeforeEach(func() {
+				locationCache.Add("repo-name", "https://gitlab.com/group/repo-name")
+			})
+
+			It("detects the forge from the location", func() {
+				_, entry, ok := locationCache.Lookup("repo-name")
+				Expect(ok).To(BeTrue())
+				Expect(entry.Forge).To(Equal(cache.ForgeGitLab))
+			})
+
+			It("defaults the VCS to git", func() {
+				_, entry, ok := locationCache.Lookup("repo-name")
+				Expect(ok).To(BeTrue())
+				Expect(entry.GetVCS()).To(Equal("git"))
+			})
+		})
+
+		Context("when the name differs in case", func() {
+			BeforeEach(func() {
+				locationCache.Upsert("Routing-API", cache.Entry{Location: "https://github.com/org2/Routing-API", Org: "org2"})
+			})
+
+			It("finds the repo under its own name", func() {
+				name, entry, ok := locationCache.Lookup("routing-api")
+				Expect(ok).To(BeTrue())
+				Expect(name).To(Equal("Routing-API"))
+				Expect(entry.Location).To(Equal("https://github.com/org2/Routing-API"))
+			})
+
+			It("returns not ok for unknown repos", func() {
+				_, _, ok := locationCache.Lookup("routing")
+				Expect(ok).To(BeFalse())
+			})
+		})
+
+		Context("when there are repos that only differ in case", func() {
+			BeforeEach(func() {
+				locationCache.Upsert("Routing-API", cache.Entry{Location: "https://github.com/org2/Routing-API", Org: "org2"})
+				locationCache.Upsert("routing-api", cache.Entry{Location: "https://github.com/org1/routing-api", Org: "org1"})
+			})
+
+			It("prefers the exact match", func() {
+				name, entry, ok := locationCache.Lookup("Routing-API")
+				Expect(ok).To(BeTrue())
+				Expect(name).To(Equal("Routing-API"))
+				Expect(entry.Org).To(Equal("org2"))
+			})
+
+			It("resolves other variants to the last one added", func() {
+				name, _, ok := locationCache.Lookup("ROUTING-API")
+				Expect(ok).To(BeTrue())
+				Expect(name).To(Equal("routing-api"))
+			})
+
+			It("reports the conflict", func() {
+				Expect(locationCache.Stats().CaseConflicts).To(Equal(map[string][]string{
+					"routing-api": {"Routing-API", "routing-api"},
+				}))
+			})
+
+			It("keeps the conflicts when they are replaced in one go", func() {
+				newLocationCache := cache.NewLocationCache(lagertest.NewTestLogger("cache"), clock)
+				Expect(newLocationCache.ReplaceAll(locationCache.List())).To(Succeed())
+				Expect(newLocationCache.Stats().CaseConflicts["routing-api"]).To(HaveLen(2))
+			})
+
+			Context("when the repo lookups resolve to is deleted", func() {
+				BeforeEach(func() {
+					Expect(locationCache.Delete("routing-api")).To(Succeed())
+				})
+
+				It("resolves the variants to the remaining repo", func() {
+					name, _, ok := locationCache.Lookup("ROUTING-API")
+					Expect(ok).To(BeTrue())
+					Expect(name).To(Equal("Routing-API"))
+				})
+
+				It("no longer reports the conflict", func() {
+					Expect(locationCache.Stats().CaseConflicts).To(BeEmpty())
+				})
+			})
+		})
+	})
+
+	Describe("ages", func() {
+		BeforeEach(func() {
+			locationCache.Add("old", "old-location")
+			clock.Increment(time.Hour)
+			locationCache.Add("new", "new-location")
+			clock.Increment(time.Minute)
+		})
+
+		It("sets the age of the entries", func() {
+			_, entry, _ := locationCache.Lookup("old")
+			Expect(entry.Age).To(Equal(time.Hour + time.Minute))
+
+			repos := locationCache.List()
+			Expect(repos[0].Name).To(Equal("new"))
+			Expect(repos[0].Age).To(Equal(time.Minute))
+		})
+
+		It("reports the age of the oldest entry", func() {
+			Expect(locationCache.Stats().OldestEntryAge).To(Equal(time.Hour + time.Minute))
+
+			locationCache.Delete("old")
+			Expect(locationCache.Stats().OldestEntryAge).To(Equal(time.Minute))
+
+			locationCache.Add("new", "new-location")
+			Expect(locationCache.Stats().OldestEntryAge).To(BeZero())
+		})
 	})
 
-	Describe("Swap", func() {
-		Context("when we swapout the cache", func() {
+	Describe("List", func() {
+		It("returns the repos sorted by name", func() {
+			locationCache.Add("repo-b", "location-b")
+			locationCache.Add("repo-a", "location-a")
+
+			repos := locationCache.List()
+			Expect(repos).To(HaveLen(2))
+			Expect(repos[0].Name).To(Equal("repo-a"))
+			Expect(repos[0].Location).To(Equal("location-a"))
+			Expect(repos[1].Name).To(Equal("repo-b"))
+			Expect(locationCache.Stats().Repos).To(Equal(2))
+		})
+	})
+
+	Describe("Delete", func() {
+		It("removes the repo", func() {
+			locationCache.Add("repo-a", "location-a")
+			locationCache.Add("repo-b", "location-b")
+
+			Expect(locationCache.Delete("repo-a")).To(Succeed())
+			_, _, ok := locationCache.Lookup("repo-a")
+			Expect(ok).To(BeFalse())
+			Expect(locationCache.Stats().Repos).To(Equal(1))
+		})
+
+		It("ignores unknown repos", func() {
+			Expect(locationCache.Delete("repo-a")).To(Succeed())
+		})
+	})
+
+	Describe("ReplaceAll", func() {
+		Context("when we replace the contents of the cache", func() {
 
 			It("the cache should contain only the new items", func() {
 				locationCache.Add("before-repo-name", "cached-location")
 
-				logger := lagertest.NewTestLogger("cache")
-				newLocationCache := cache.NewLocationCache(logger, clock)
-				newLocationCache.Add("new-repo-name", "new-cached-location")
-
-				locationCache.Swap(newLocationCache)
-				_, ok := locationCache.Lookup("before-repo-name")
+				Expect(locationCache.ReplaceAll([]cache.Repo{
+					{Name: "new-repo-name", Entry: cache.Entry{Location: "new-cached-location"}},
+				})).To(Succeed())
+				_, _, ok := locationCache.Lookup("before-repo-name")
 				Expect(ok).To(BeFalse())
 
-				_, ok = locationCache.Lookup("new-repo-name")
+				_, _, ok = locationCache.Lookup("new-repo-name")
 				Expect(ok).To(BeTrue())
 			})
+
+			It("records the time of the refresh", func() {
+				Expect(locationCache.Stats().RefreshedAt.IsZero()).To(BeTrue())
+
+				clock.Increment(time.Minute)
+				locationCache.ReplaceAll(nil)
+				Expect(locationCache.Stats().RefreshedAt).To(Equal(clock.Now()))
+
+				clock.Increment(time.Hour)
+				Expect(locationCache.Stats().Age).To(Equal(time.Hour))
+			})
+
+			It("keeps the update time of repos that have one", func() {
+				updatedAt := clock.Now().Add(-time.Hour)
+				locationCache.ReplaceAll([]cache.Repo{
+					{Name: "old", Entry: cache.Entry{Location: "old-location", UpdatedAt: updatedAt}},
+					{Name: "new", Entry: cache.Entry{Location: "new-location"}},
+				})
+
+				_, entry, _ := locationCache.Lookup("old")
+				Expect(entry.UpdatedAt).To(Equal(updatedAt))
+				_, entry, _ = locationCache.Lookup("new")
+				Expect(entry.UpdatedAt).To(Equal(clock.Now()))
+			})
+		})
+	})
+
+	Describe("concurrent access", func() {
+		It("serves consistent lookups while the cache is replaced and added to", func() {
+			const rounds = 200
+
+			newRepos := func(round int) []cache.Repo {
+				return []cache.Repo{
+					{Name: "stable", Entry: cache.Entry{Location: fmt.Sprintf("location-%d", round)}},
+					{Name: "Stable-Case", Entry: cache.Entry{Location: fmt.Sprintf("location-%d", round)}},
+				}
+			}
+			locationCache.ReplaceAll(newRepos(0))
+
+			var wg sync.WaitGroup
+			done := make(chan struct{})
+
+			for i := 0; i < 4; i++ {
+				wg.Add(1)
+				go func() {
+					defer GinkgoRecover()
+					defer wg.Done()
+					for {
+						select {
+						case <-done:
+							return
+						default:
+						}
+
+						_, entry, ok := locationCache.Lookup("stable")
+						Expect(ok).To(BeTrue())
+						Expect(entry.Location).To(HavePrefix("location-"))
+
+						_, _, ok = locationCache.Lookup("stable-case")
+						Expect(ok).To(BeTrue())
+
+						Expect(locationCache.List()).NotTo(BeEmpty())
+						locationCache.Stats()
+					}
+				}()
+			}
+
+			wg.Add(2)
+			go func() {
+				defer GinkgoRecover()
+				defer wg.Done()
+				for round := 1; round <= rounds; round++ {
+					locationCache.ReplaceAll(newRepos(round))
+				}
+			}()
+			go func() {
+				defer GinkgoRecover()
+				defer wg.Done()
+				for round := 1; round <= rounds; round++ {
+					locationCache.Add(fmt.Sprintf("added-%d", round), "added-location")
+				}
+			}()
+
+			Eventually(func() bool {
+				_, entry, _ := locationCache.Lookup("stable")
+				return entry.Location == fmt.Sprintf("location-%d", rounds)
+			}).Should(BeTrue())
+			close(done)
+			wg.Wait()
+		})
+
+		It("does not lose concurrent additions", func() {
+			var wg sync.WaitGroup
+			for i := 0; i < 8; i++ {
+				wg.Add(1)
+				go func(i int) {
+					defer GinkgoRecover()
+					defer wg.Done()
+					for j := 0; j < 50; j++ {
+						locationCache.Add(fmt.Sprintf("repo-%d-%d", i, j), "location")
+					}
+				}(i)
+			}
+			wg.Wait()
+
+			Expect(locationCache.Stats().Repos).To(Equal(8 * 50))
 		})
 	})
 })
@@ -0,0 +1,145 @@
+package cache
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"time"
+
+	"code.cloudfoundry.org/lager"
+)
+
+// maxLoggedRemovals is how many of the removed repos of a blocked refresh are
+// logged.
+const maxLoggedRemovals = 20
+
+var (
+	// ErrNoBlockedRefresh is returned when accepting a blocked refresh while
+	// there is none.
+	ErrNoBlockedRefresh = errors.New("no refresh is blocked")
+	// ErrBlockedRefreshChanged is returned when accepting a blocked refresh
+	// whose changes are not the ones that were reviewed.
+	ErrBlockedRefreshChanged = errors.New("the blocked refresh has changed")
+)
+
+// DeletionGuard keeps a refresh from being stored if it would remove more
+// than MaxRemoved repos, or more than MaxRemovedPercent of them, e.g. because
+// of a misconfigured token or an org that GitHub briefly returns empty. Zero
+// disables either limit.
+type DeletionGuard struct {
+	MaxRemoved        int
+	MaxRemovedPercent float64
+}
+
+func (g DeletionGuard) blocks(removed, repos int) bool {
+	if g.MaxRemoved > 0 && removed > g.MaxRemoved {
+		return true
+	}
+	return g.MaxRemovedPercent > 0 && repos > 0 && float64(removed)*100 > g.MaxRemovedPercent*float64(repos)
+}
+
+// BlockedRefresh is a refresh that the DeletionGuard kept from being stored.
+type BlockedRefresh struct {
+	// ID identifies the changes, so that they can only be accepted as they
+	// were reviewed. Refreshes with the same changes have the same ID.
+	ID        string
+	BlockedAt time.Time
+	// Repos is the number of repos that are stored.
+	Repos   int
+	Removed []string
+	Added   []string
+
+	repos []Repo
+}
+
+// MassDeletionError is returned by a refresh that was blocked by the
+// DeletionGuard.
+type MassDeletionError struct {
+	ID      string
+	Removed int
+	Repos   int
+}
+
+func (e MassDeletionError) Error() string {
+	return fmt.Sprintf("refresh %s would remove %d of %d repos", e.ID, e.Removed, e.Repos)
+}
+
+// guardDeletions checks the diff of a refresh to the stored repos, and
+// returns a MassDeletionError and keeps the refresh as blocked if the
+// DeletionGuard blocks it.
+func (c *CacheLoader) guardDeletions(logger lager.Logger, diff RefreshDiff, stored int, found []Repo) error {
+	removed, added := names(diff.Removed), names(diff.Added)
+	if !c.guard.blocks(len(removed), stored) {
+		c.setBlocked(nil)
+		return nil
+	}
+
+	blocked := &BlockedRefresh{
+		ID:        diffID(removed, added),
+		BlockedAt: c.clock.Now(),
+		Repos:     stored,
+		Removed:   removed,
+		Added:     added,
+		repos:     found,
+	}
+	c.setBlocked(blocked)
+
+	err := MassDeletionError{ID: blocked.ID, Removed: len(removed), Repos: stored}
+	logged := removed
+	if len(logged) > maxLoggedRemovals {
+		logged = logged[:maxLoggedRemovals]
+	}
+	logger.Error("blocked-mass-deletion", err, lager.Data{
+		"id":                  blocked.ID,
+		"removed":             len(removed),
+		"added":               len(added),
+		"repos":               stored,
+		"max-removed":         c.guard.MaxRemoved,
+		"max-removed-percent": c.guard.MaxRemovedPercent,
+		"removed-repos":       logged,
+	})
+	return err
+}
+
+// AcceptBlocked stores the refresh that the DeletionGuard blocked, as long as
+// its ID is still id, and returns it. The ID is checked against the repos
+// stored now, so that the refresh is not accepted if they changed since it
+// was blocked, e.g. because of a webhook.
+func (c *CacheLoader) AcceptBlocked(id string) (BlockedRefresh, error) {
+	c.storeLock.Lock()
+	defer c.storeLock.Unlock()
+
+	blocked := c.Status().Blocked
+	if blocked == nil {
+		return BlockedRefresh{}, ErrNoBlockedRefresh
+	}
+
+	stored := c.store.List()
+	diff := diffRepos(stored, blocked.repos, c.clock.Now(), DiffSourceAccepted)
+	if blocked.ID != id || diffID(names(diff.Removed), names(diff.Added)) != id {
+		return BlockedRefresh{}, ErrBlockedRefreshChanged
+	}
+
+	if err := c.store.ReplaceAll(blocked.repos); err != nil {
+		c.logger.Error("failed-storing-accepted-refresh", err, lager.Data{"id": id})
+		return BlockedRefresh{}, err
+	}
+	c.recordDiff(c.logger.Session("accept-blocked-refresh"), diff, len(stored))
+
+	c.setBlocked(nil)
+
+	c.logger.Info("accepted-blocked-refresh", lager.Data{"id": id, "removed": len(blocked.Removed), "added": len(blocked.Added)})
+	return *blocked, nil
+}
+
+func diffID(removed, added []string) string {
+	hash := sha256.New()
+	for _, name := range removed {
+		fmt.Fprintf(hash, "-%s\n", name)
+	}
+	for _, name := range added {
+		fmt.Fprintf(hash, "+%s\n", name)
+	}
+	return hex.EncodeToString(hash.Sum(nil))[:12]
+}
@@ -0,0 +1,186 @@
+package cache_test
+
+import (
+	"context"
+	"os"
+	"time"
+
+	"code.cloudfoundry.org/clock/fakeclock"
+	"code.cloudfoundry.org/lager/lagertest"
+	"github.com/cloudfoundry/go-fetcher/cache"
+	"github.com/cloudfoundry/go-fetcher/cache/fakes"
+	"github.com/google/go-github/github"
+	"github.com/tedsuo/ifrit"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("DeletionGuard", func() {
+	var (
+		fakeRepoService *fakes.FakeRepositoriesService
+		fakeClock       *fakeclock.FakeClock
+		locCache        *cache.LocationCache
+		guard           cache.DeletionGuard
+		cacheLoader     *cache.CacheLoader
+		names           []string
+	)
+
+	BeforeEach(func() {
+		fakeRepoService = &fakes.FakeRepositoriesService{}
+		fakeRepoService.ListByOrgStub = func(_ context.Context, org string, _ *github.RepositoryListByOrgOptions) ([]*github.Repository, *github.Response, error) {
+			var repos []*github.Repository
+			for i := range names {
+				repos = append(repos, &github.Repository{Name: &names[i]})
+			}
+			return repos, &github.Response{}, nil
+		}
+
+		fakeClock = fakeclock.NewFakeClock(time.Date(2019, 6, 1, 12, 0, 0, 0, time.UTC))
+		locCache = cache.NewLocationCache(lagertest.NewTestLogger("cache"), fakeClock)
+		var stored []cache.Repo
+		for _, name := range []string{"repo1", "repo2", "repo3", "repo4", "repo5"} {
+			stored = append(stored, cache.Repo{Name: name, Entry: cache.Entry{Org: "org1"}})
+		}
+		Expect(locCache.ReplaceAll(stored)).To(Succeed())
+
+		guard = cache.DeletionGuard{}
+	})
+
+	refresh := func() {
+		retryPolicy := cache.RetryPolicy{InitialBackoff: time.Second, MaxBackoff: time.Minute}
+		cacheLoader = cache.NewCacheLoader(lagertest.NewTestLogger("cache-loader"), []string{"org1"}, locCache, fakeRepoService, fakeClock, retryPolicy, 1, guard)
+
+		process := ifrit.Background(cacheLoader)
+		Eventually(func() time.Time { return cacheLoader.Status().NextRefresh }).ShouldNot(BeZero())
+		process.Signal(os.Interrupt)
+		Eventually(process.Wait()).Should(Receive())
+	}
+
+	storedNames := func() []string {
+		var stored []string
+		for _, repo := range locCache.List() {
+			stored = append(stored, repo.Name)
+		}
+		return stored
+	}
+
+	Context("with a maximum number of removed repos", func() {
+		BeforeEach(func() {
+			guard.MaxRemoved = 2
+		})
+
+		It("stores refreshes that remove up to that many repos", func() {
+			names = []string{"repo1", "repo2", "repo3", "repo6"}
+			refresh()
+
+			Expect(storedNames()).To(Equal([]string{"repo1", "repo2", "repo3", "repo6"}))
+			Expect(cacheLoader.Status().Blocked).To(BeNil())
+		})
+
+		It("keeps the stored repos and exposes the changes if a refresh removes more", func() {
+			names = []string{"repo1", "repo2", "repo6"}
+			refresh()
+
+			Expect(storedNames()).To(Equal([]string{"repo1", "repo2", "repo3", "repo4", "repo5"}))
+
+			status := cacheLoader.Status()
+			Expect(status.ConsecutiveFailures).To(Equal(1))
+			Expect(status.LastError).To(MatchRegexp(`^refresh [0-9a-f]{12} would remove 3 of 5 repos$`))
+			Expect(status.Blocked).NotTo(BeNil())
+			Expect(status.Blocked.BlockedAt).To(Equal(fakeClock.Now()))
+			Expect(status.Blocked.Repos).To(Equal(5))
+			Expect(status.Blocked.Removed).To(Equal([]string{"repo3", "repo4", "repo5"}))
+			Expect(status.Blocked.Added).To(Equal([]string{"repo6"}))
+		})
+
+		It("identifies the same changes by the same ID", func() {
+			names = []string{"repo1"}
+			refresh()
+			id := cacheLoader.Status().Blocked.ID
+
+			refresh()
+			Expect(cacheLoader.Status().Blocked.ID).To(Equal(id))
+
+			names = []string{"repo2"}
+			refresh()
+			Expect(cacheLoader.Status().Blocked.ID).NotTo(Equal(id))
+		})
+	})
+
+	Context("with a maximum percentage of removed repos", func() {
+		BeforeEach(func() {
+			guard.MaxRemovedPercent = 50
+		})
+
+		It("blocks refreshes that remove more of the repos", func() {
+			names = []string{"repo1", "repo2"}
+			refresh()
+
+			Expect(storedNames()).To(HaveLen(5))
+			Expect(cacheLoader.Status().Blocked.Removed).To(HaveLen(3))
+		})
+
+		It("stores refreshes that remove fewer", func() {
+			names = []string{"repo1", "repo2", "repo3"}
+			refresh()
+
+			Expect(storedNames()).To(HaveLen(3))
+			Expect(cacheLoader.Status().Blocked).To(BeNil())
+		})
+
+		It("does not block filling an empty store", func() {
+			locCache = cache.NewLocationCache(lagertest.NewTestLogger("cache"), fakeClock)
+			names = []string{"repo1"}
+			refresh()
+
+			Expect(storedNames()).To(Equal([]string{"repo1"}))
+		})
+	})
+
+	Describe("AcceptBlocked", func() {
+		BeforeEach(func() {
+			guard.MaxRemoved = 1
+			names = []string{"repo1"}
+		})
+
+		It("stores the blocked refresh", func() {
+			refresh()
+			id := cacheLoader.Status().Blocked.ID
+
+			accepted, err := cacheLoader.AcceptBlocked(id)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(accepted.Removed).To(HaveLen(4))
+
+			Expect(storedNames()).To(Equal([]string{"repo1"}))
+			Expect(cacheLoader.Status().Blocked).To(BeNil())
+		})
+
+		It("fails if the changes are not the ones that were reviewed", func() {
+			refresh()
+
+			_, err := cacheLoader.AcceptBlocked("0123456789ab")
+			Expect(err).To(Equal(cache.ErrBlockedRefreshChanged))
+			Expect(storedNames()).To(HaveLen(5))
+		})
+
+		It("fails if the stored repos changed since the refresh was blocked", func() {
+			refresh()
+			id := cacheLoader.Status().Blocked.ID
+
+			Expect(locCache.Upsert("repo6", cache.Entry{Org: "org1"})).To(Succeed())
+
+			_, err := cacheLoader.AcceptBlocked(id)
+			Expect(err).To(Equal(cache.ErrBlockedRefreshChanged))
+			Expect(storedNames()).To(Equal([]string{"repo1", "repo2", "repo3", "repo4", "repo5", "repo6"}))
+		})
+
+		It("fails if no refresh is blocked", func() {
+			names = []string{"repo1", "repo2", "repo3", "repo4"}
+			refresh()
+
+			_, err := cacheLoader.AcceptBlocked("0123456789ab")
+			Expect(err).To(Equal(cache.ErrNoBlockedRefresh))
+		})
+	})
+})
@@ -0,0 +1,79 @@
+package cache
+
+import (
+	"os"
+
+	"code.cloudfoundry.org/clock"
+	"code.cloudfoundry.org/lager"
+)
+
+// DiskStore is a Store that keeps the repos in memory, and saves them to a
+// file with every change so that they survive a restart. A change is only
+// served once it is saved, so a change that fails to be saved is not made at
+// all.
+type DiskStore struct {
+	memory *LocationCache
+	path   string
+	logger lager.Logger
+}
+
+// NewDiskStore returns a store saved to the file at path, with the repos
+// saved there before. If the file cannot be loaded, the store starts out
+// empty.
+func NewDiskStore(logger lager.Logger, clock clock.Clock, path string) *DiskStore {
+	logger = logger.Session("disk-store", lager.Data{"path": path})
+	store := &DiskStore{
+		memory: NewLocationCache(logger, clock),
+		path:   path,
+		logger: logger,
+	}
+
+	if err := store.memory.Load(path); err != nil {
+		if os.IsNotExist(err) {
+			logger.Info("no-cache-file")
+		} else {
+			logger.Error("failed-loading-cache-file", err)
+		}
+		return store
+	}
+
+	stats := store.memory.Stats()
+	logger.Info("loaded-cache-file", lager.Data{"repos": stats.Repos, "age": stats.Age.String()})
+	return store
+}
+
+func (d *DiskStore) Lookup(repoName string) (string, Entry, bool) {
+	return d.memory.Lookup(repoName)
+}
+
+func (d *DiskStore) List() []Repo {
+	return d.memory.List()
+}
+
+func (d *DiskStore) Stats() Stats {
+	return d.memory.Stats()
+}
+
+func (d *DiskStore) ReplaceAll(repos []Repo) error {
+	return d.memory.replaceAll(repos, d.save)
+}
+
+func (d *DiskStore) Upsert(repoName string, entry Entry) error {
+	return d.memory.upsert(repoName, entry, d.save)
+}
+
+func (d *DiskStore) Delete(repoName string) error {
+	return d.memory.delete(repoName, d.save)
+}
+
+// save writes the snapshot with a change before it is published. Changes are
+// made one at a time, so an older snapshot never replaces a newer one on
+// disk.
+func (d *DiskStore) save(s *snapshot) error {
+	if err := s.save(d.path); err != nil {
+		d.logger.Error("failed-saving-cache-file", err)
+		return err
+	}
+	d.logger.Debug("saved-cache-file")
+	return nil
+}
@@ -0,0 +1,96 @@
+package cache_test
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"time"
+
+	"code.cloudfoundry.org/clock/fakeclock"
+	"code.cloudfoundry.org/lager/lagertest"
+	"github.com/cloudfoundry/go-fetcher/cache"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("DiskStore", func() {
+	var (
+		tmpDir    string
+		cacheFile string
+		clock     *fakeclock.FakeClock
+	)
+
+	newStore := func() *cache.DiskStore {
+		return cache.NewDiskStore(lagertest.NewTestLogger("disk-store"), clock, cacheFile)
+	}
+
+	BeforeEach(func() {
+		var err error
+		tmpDir, err = ioutil.TempDir("", "disk-store")
+		Expect(err).NotTo(HaveOccurred())
+		cacheFile = filepath.Join(tmpDir, "cache.json")
+		clock = fakeclock.NewFakeClock(time.Date(2019, 6, 1, 12, 0, 0, 0, time.UTC))
+	})
+
+	AfterEach(func() {
+		Expect(os.RemoveAll(tmpDir)).To(Succeed())
+	})
+
+	It("starts out empty without a file", func() {
+		store := newStore()
+		Expect(store.List()).To(BeEmpty())
+		Expect(store.Stats().RefreshedAt.IsZero()).To(BeTrue())
+	})
+
+	It("starts out empty with a corrupt file", func() {
+		Expect(ioutil.WriteFile(cacheFile, []byte("{"), 0644)).To(Succeed())
+
+		store := newStore()
+		Expect(store.List()).To(BeEmpty())
+		Expect(store.Stats().RefreshedAt.IsZero()).To(BeTrue())
+	})
+
+	It("keeps the repos and the refresh time across restarts", func() {
+		store := newStore()
+		Expect(store.ReplaceAll([]cache.Repo{
+			{Name: "repo-a", Entry: cache.Entry{Location: "location-a"}},
+			{Name: "repo-b", Entry: cache.Entry{Location: "location-b"}},
+		})).To(Succeed())
+
+		clock.Increment(time.Hour)
+		restarted := newStore()
+		Expect(restarted.List()).To(Equal(store.List()))
+		Expect(restarted.Stats().Age).To(Equal(time.Hour))
+	})
+
+	It("saves single changes", func() {
+		store := newStore()
+		Expect(store.Upsert("repo-a", cache.Entry{Location: "location-a"})).To(Succeed())
+		Expect(store.Upsert("repo-b", cache.Entry{Location: "location-b"})).To(Succeed())
+		Expect(store.Delete("repo-a")).To(Succeed())
+
+		restarted := newStore()
+		_, _, ok := restarted.Lookup("repo-a")
+		Expect(ok).To(BeFalse())
+		_, entry, ok := restarted.Lookup("REPO-B")
+		Expect(ok).To(BeTrue())
+		Expect(entry.Location).To(Equal("location-b"))
+	})
+
+	It("does not make changes that cannot be saved, and returns the error", func() {
+		cacheFile = filepath.Join(tmpDir, "dir", "cache.json")
+		Expect(os.Mkdir(filepath.Dir(cacheFile), 0755)).To(Succeed())
+
+		store := newStore()
+		Expect(store.ReplaceAll([]cache.Repo{{Name: "repo-a", Entry: cache.Entry{Location: "location-a"}}})).To(Succeed())
+		repos := store.List()
+
+		Expect(os.RemoveAll(filepath.Dir(cacheFile))).To(Succeed())
+		Expect(store.ReplaceAll([]cache.Repo{{Name: "repo-b", Entry: cache.Entry{Location: "location-b"}}})).NotTo(Succeed())
+		Expect(store.Upsert("repo-c", cache.Entry{Location: "location-c"})).NotTo(Succeed())
+		Expect(store.Delete("repo-a")).NotTo(Succeed())
+
+		Expect(store.List()).To(Equal(repos))
+	})
+})
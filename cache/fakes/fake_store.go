@@ -0,0 +1,474 @@
+// Code generated by counterfeiter. DO NOT EDIT.
+package fakes
+
+import (
+	"sync"
+
+	"github.com/cloudfoundry/go-fetcher/cache"
+)
+
+type FakeStore struct {
+	DeleteStub        func(string) error
+	deleteMutex       sync.RWMutex
+	deleteArgsForCall []struct {
+		arg1 string
+	}
+	deleteReturns struct {
+		result1 error
+	}
+	deleteReturnsOnCall map[int]struct {
+		result1 error
+	}
+	ListStub        func() []cache.Repo
+	listMutex       sync.RWMutex
+	listArgsForCall []struct {
+	}
+	listReturns struct {
+		result1 []cache.Repo
+	}
+	listReturnsOnCall map[int]struct {
+		result1 []cache.Repo
+	}
+	LookupStub        func(string) (string, cache.Entry, bool)
+	lookupMutex       sync.RWMutex
+	lookupArgsForCall []struct {
+		arg1 string
+	}
+	lookupReturns struct {
+		result1 string
+		result2 cache.Entry
+		result3 bool
+	}
+	lookupReturnsOnCall map[int]struct {
+		result1 string
+		result2 cache.Entry
+		result3 bool
+	}
+	ReplaceAllStub        func([]cache.Repo) error
+	replaceAllMutex       sync.RWMutex
+	replaceAllArgsForCall []struct {
+		arg1 []cache.Repo
+	}
+	replaceAllReturns struct {
+		result1 error
+	}
+	replaceAllReturnsOnCall map[int]struct {
+		result1 error
+	}
+	StatsStub        func() cache.Stats
+	statsMutex       sync.RWMutex
+	statsArgsForCall []struct {
+	}
+	statsReturns struct {
+		result1 cache.Stats
+	}
+	statsReturnsOnCall map[int]struct {
+		result1 cache.Stats
+	}
+	UpsertStub        func(string, cache.Entry) error
+	upsertMutex       sync.RWMutex
+	upsertArgsForCall []struct {
+		arg1 string
+		arg2 cache.Entry
+	}
+	upsertReturns struct {
+		result1 error
+	}
+	upsertReturnsOnCall map[int]struct {
+		result1 error
+	}
+	invocations      map[string][][]interface{}
+	invocationsMutex sync.RWMutex
+}
+
+func (fake *FakeStore) Delete(arg1 string) error {
+	fake.deleteMutex.Lock()
+	ret, specificReturn := fake.deleteReturnsOnCall[len(fake.deleteArgsForCall)]
+	fake.deleteArgsForCall = append(fake.deleteArgsForCall, struct {
+		arg1 string
+	}{arg1})
+	fake.recordInvocation("Delete", []interface{}{arg1})
+	fake.deleteMutex.Unlock()
+	if fake.DeleteStub != nil {
+		return fake.DeleteStub(arg1)
+	}
+	if specificReturn {
+		return ret.result1
+	}
+	fakeReturns := fake.deleteReturns
+	return fakeReturns.result1
+}
+
+func (fake *FakeStore) DeleteCallCount() int {
+	fake.deleteMutex.RLock()
+	defer fake.deleteMutex.RUnlock()
+	return len(fake.deleteArgsForCall)
+}
+
+func (fake *FakeStore) DeleteCalls(stub func(string) error) {
+	fake.deleteMutex.Lock()
+	defer fake.deleteMutex.Unlock()
+	fake.DeleteStub = stub
+}
+
+func (fake *FakeStore) DeleteArgsForCall(i int) string {
+	fake.deleteMutex.RLock()
+	defer fake.deleteMutex.RUnlock()
+	argsForCall := fake.deleteArgsForCall[i]
+	return argsForCall.arg1
+}
+
+func (fake *FakeStore) DeleteReturns(result1 error) {
+	fake.deleteMutex.Lock()
+	defer fake.deleteMutex.Unlock()
+	fake.DeleteStub = nil
+	fake.deleteReturns = struct {
+		result1 error
+	}{result1}
+}
+
+func (fake *FakeStore) DeleteReturnsOnCall(i int, result1 error) {
+	fake.deleteMutex.Lock()
+	defer fake.deleteMutex.Unlock()
+	fake.DeleteStub = nil
+	if fake.deleteReturnsOnCall == nil {
+		fake.deleteReturnsOnCall = make(map[int]struct {
+			result1 error
+		})
+	}
+	fake.deleteReturnsOnCall[i] = struct {
+		result1 error
+	}{result1}
+}
+
+func (fake *FakeStore) List() []cache.Repo {
+	fake.listMutex.Lock()
+	ret, specificReturn := fake.listReturnsOnCall[len(fake.listArgsForCall)]
+	fake.listArgsForCall = append(fake.listArgsForCall, struct {
+	}{})
+	fake.recordInvocation("List", []interface{}{})
+	fake.listMutex.Unlock()
+	if fake.ListStub != nil {
+		return fake.ListStub()
+	}
+	if specificReturn {
+		return ret.result1
+	}
+	fakeReturns := fake.listReturns
+	return fakeReturns.result1
+}
+
+func (fake *FakeStore) ListCallCount() int {
+	fake.listMutex.RLock()
+	defer fake.listMutex.RUnlock()
+	return len(fake.listArgsForCall)
+}
+
+func (fake *FakeStore) ListCalls(stub func() []cache.Repo) {
+	fake.listMutex.Lock()
+	defer fake.listMutex.Unlock()
+	fake.ListStub = stub
+}
+
+func (fake *FakeStore) ListReturns(result1 []cache.Repo) {
+	fake.listMutex.Lock()
+	defer fake.listMutex.Unlock()
+	fake.ListStub = nil
+	fake.listReturns = struct {
+		result1 []cache.Repo
+	}{result1}
+}
+
+func (fake *FakeStore) ListReturnsOnCall(i int, result1 []cache.Repo) {
+	fake.listMutex.Lock()
+	defer fake.listMutex.Unlock()
+	fake.ListStub = nil
+	if fake.listReturnsOnCall == nil {
+		fake.listReturnsOnCall = make(map[int]struct {
+			result1 []cache.Repo
+		})
+	}
+	fake.listReturnsOnCall[i] = struct {
+		result1 []cache.Repo
+	}{result1}
+}
+
+func (fake *FakeStore) Lookup(arg1 string) (string, cache.Entry, bool) {
+	fake.lookupMutex.Lock()
+	ret, specificReturn := fake.lookupReturnsOnCall[len(fake.lookupArgsForCall)]
+	fake.lookupArgsForCall = append(fake.lookupArgsForCall, struct {
+		arg1 string
+	}{arg1})
+	fake.recordInvocation("Lookup", []interface{}{arg1})
+	fake.lookupMutex.Unlock()
+	if fake.LookupStub != nil {
+		return fake.LookupStub(arg1)
+	}
+	if specificReturn {
+		return ret.result1, ret.result2, ret.result3
+	}
+	fakeReturns := fake.lookupReturns
+	return fakeReturns.result1, fakeReturns.result2, fakeReturns.result3
+}
+
+func (fake *FakeStore) LookupCallCount() int {
+	fake.lookupMutex.RLock()
+	defer fake.lookupMutex.RUnlock()
+	return len(fake.lookupArgsForCall)
+}
+
+func (fake *FakeStore) LookupCalls(stub func(string) (string, cache.Entry, bool)) {
+	fake.lookupMutex.Lock()
+	defer fake.lookupMutex.Unlock()
+	fake.LookupStub = stub
+}
+
+func (fake *FakeStore) LookupArgsForCall(i int) string {
+	fake.lookupMutex.RLock()
+	defer fake.lookupMutex.RUnlock()
+	argsForCall := fake.lookupArgsForCall[i]
+	return argsForCall.arg1
+}
+
+func (fake *FakeStore) LookupReturns(result1 string, result2 cache.Entry, result3 bool) {
+	fake.lookupMutex.Lock()
+	defer fake.lookupMutex.Unlock()
+	fake.LookupStub = nil
+	fake.lookupReturns = struct {
+		result1 string
+		result2 cache.Entry
+		result3 bool
+	}{result1, result2, result3}
+}
+
+func (fake *FakeStore) LookupReturnsOnCall(i int, result1 string, result2 cache.Entry, result3 bool) {
+	fake.lookupMutex.Lock()
+	defer fake.lookupMutex.Unlock()
+	fake.LookupStub = nil
+	if fake.lookupReturnsOnCall == nil {
+		fake.lookupReturnsOnCall = make(map[int]struct {
+			result1 string
+			result2 cache.Entry
+			result3 bool
+		})
+	}
+	fake.lookupReturnsOnCall[i] = struct {
+		result1 string
+		result2 cache.Entry
+		result3 bool
+	}{result1, result2, result3}
+}
+
+func (fake *FakeStore) ReplaceAll(arg1 []cache.Repo) error {
+	var arg1Copy []cache.Repo
+	if arg1 != nil {
+		arg1Copy = make([]cache.Repo, len(arg1))
+		copy(arg1Copy, arg1)
+	}
+	fake.replaceAllMutex.Lock()
+	ret, specificReturn := fake.replaceAllReturnsOnCall[len(fake.replaceAllArgsForCall)]
+	fake.replaceAllArgsForCall = append(fake.replaceAllArgsForCall, struct {
+		arg1 []cache.Repo
+	}{arg1Copy})
+	fake.recordInvocation("ReplaceAll", []interface{}{arg1Copy})
+	fake.replaceAllMutex.Unlock()
+	if fake.ReplaceAllStub != nil {
+		return fake.ReplaceAllStub(arg1)
+	}
+	if specificReturn {
+		return ret.result1
+	}
+	fakeReturns := fake.replaceAllReturns
+	return fakeReturns.result1
+}
+
+func (fake *FakeStore) ReplaceAllCallCount() int {
+	fake.replaceAllMutex.RLock()
+	defer fake.replaceAllMutex.RUnlock()
+	return len(fake.replaceAllArgsForCall)
+}
+
+func (fake *FakeStore) ReplaceAllCalls(stub func([]cache.Repo) error) {
+	fake.replaceAllMutex.Lock()
+	defer fake.replaceAllMutex.Unlock()
+	fake.ReplaceAllStub = stub
+}
+
+func (fake *FakeStore) ReplaceAllArgsForCall(i int) []cache.Repo {
+	fake.replaceAllMutex.RLock()
+	defer fake.replaceAllMutex.RUnlock()
+	argsForCall := fake.replaceAllArgsForCall[i]
+	return argsForCall.arg1
+}
+
+func (fake *FakeStore) ReplaceAllReturns(result1 error) {
+	fake.replaceAllMutex.Lock()
+	defer fake.replaceAllMutex.Unlock()
+	fake.ReplaceAllStub = nil
+	fake.replaceAllReturns = struct {
+		result1 error
+	}{result1}
+}
+
+func (fake *FakeStore) ReplaceAllReturnsOnCall(i int, result1 error) {
+	fake.replaceAllMutex.Lock()
+	defer fake.replaceAllMutex.Unlock()
+	fake.ReplaceAllStub = nil
+	if fake.replaceAllReturnsOnCall == nil {
+		fake.replaceAllReturnsOnCall = make(map[int]struct {
+			result1 error
+		})
+	}
+	fake.replaceAllReturnsOnCall[i] = struct {
+		result1 error
+	}{result1}
+}
+
+func (fake *FakeStore) Stats() cache.Stats {
+	fake.statsMutex.Lock()
+	ret, specificReturn := fake.statsReturnsOnCall[len(fake.statsArgsForCall)]
+	fake.statsArgsForCall = append(fake.statsArgsForCall, struct {
+	}{})
+	fake.recordInvocation("Stats", []interface{}{})
+	fake.statsMutex.Unlock()
+	if fake.StatsStub != nil {
+		return fake.StatsStub()
+	}
+	if specificReturn {
+		return ret.result1
+	}
+	fakeReturns := fake.statsReturns
+	return fakeReturns.result1
+}
+
+func (fake *FakeStore) StatsCallCount() int {
+	fake.statsMutex.RLock()
+	defer fake.statsMutex.RUnlock()
+	return len(fake.statsArgsForCall)
+}
+
+func (fake *FakeStore) StatsCalls(stub func() cache.Stats) {
+	fake.statsMutex.Lock()
+	defer fake.statsMutex.Unlock()
+	fake.StatsStub = stub
+}
+
+func (fake *FakeStore) StatsReturns(result1 cache.Stats) {
+	fake.statsMutex.Lock()
+	defer fake.statsMutex.Unlock()
+	fake.StatsStub = nil
+	fake.statsReturns = struct {
+		result1 cache.Stats
+	}{result1}
+}
+
+func (fake *FakeStore) StatsReturnsOnCall(i int, result1 cache.Stats) {
+	fake.statsMutex.Lock()
+	defer fake.statsMutex.Unlock()
+	fake.StatsStub = nil
+	if fake.statsReturnsOnCall == nil {
+		fake.statsReturnsOnCall = make(map[int]struct {
+			result1 cache.Stats
+		})
+	}
+	fake.statsReturnsOnCall[i] = struct {
+		result1 cache.Stats
+	}{result1}
+}
+
+func (fake *FakeStore) Upsert(arg1 string, arg2 cache.Entry) error {
+	fake.upsertMutex.Lock()
+	ret, specificReturn := fake.upsertReturnsOnCall[len(fake.upsertArgsForCall)]
+	fake.upsertArgsForCall = append(fake.upsertArgsForCall, struct {
+		arg1 string
+		arg2 cache.Entry
+	}{arg1, arg2})
+	fake.recordInvocation("Upsert", []interface{}{arg1, arg2})
+	fake.upsertMutex.Unlock()
+	if fake.UpsertStub != nil {
+		return fake.UpsertStub(arg1, arg2)
+	}
+	if specificReturn {
+		return ret.result1
+	}
+	fakeReturns := fake.upsertReturns
+	return fakeReturns.result1
+}
+
+func (fake *FakeStore) UpsertCallCount() int {
+	fake.upsertMutex.RLock()
+	defer fake.upsertMutex.RUnlock()
+	return len(fake.upsertArgsForCall)
+}
+
+func (fake *FakeStore) UpsertCalls(stub func(string, cache.Entry) error) {
+	fake.upsertMutex.Lock()
+	defer fake.upsertMutex.Unlock()
+	fake.UpsertStub = stub
+}
+
+func (fake *FakeStore) UpsertArgsForCall(i int) (string, cache.Entry) {
+	fake.upsertMutex.RLock()
+	defer fake.upsertMutex.RUnlock()
+	argsForCall := fake.upsertArgsForCall[i]
+	return argsForCall.arg1, argsForCall.arg2
+}
+
+func (fake *FakeStore) UpsertReturns(result1 error) {
+	fake.upsertMutex.Lock()
+	defer fake.upsertMutex.Unlock()
+	fake.UpsertStub = nil
+	fake.upsertReturns = struct {
+		result1 error
+	}{result1}
+}
+
+func (fake *FakeStore) UpsertReturnsOnCall(i int, result1 error) {
+	fake.upsertMutex.Lock()
+	defer fake.upsertMutex.Unlock()
+	fake.UpsertStub = nil
+	if fake.upsertReturnsOnCall == nil {
+		fake.upsertReturnsOnCall = make(map[int]struct {
+			result1 error
+		})
+	}
+	fake.upsertReturnsOnCall[i] = struct {
+		result1 error
+	}{result1}
+}
+
+func (fake *FakeStore) Invocations() map[string][][]interface{} {
+	fake.invocationsMutex.RLock()
+	defer fake.invocationsMutex.RUnlock()
+	fake.deleteMutex.RLock()
+	defer fake.deleteMutex.RUnlock()
+	fake.listMutex.RLock()
+	defer fake.listMutex.RUnlock()
+	fake.lookupMutex.RLock()
+	defer fake.lookupMutex.RUnlock()
+	fake.replaceAllMutex.RLock()
+	defer fake.replaceAllMutex.RUnlock()
+	fake.statsMutex.RLock()
+	defer fake.statsMutex.RUnlock()
+	fake.upsertMutex.RLock()
+	defer fake.upsertMutex.RUnlock()
+	copiedInvocations := map[string][][]interface{}{}
+	for key, value := range fake.invocations {
+		copiedInvocations[key] = value
+	}
+	return copiedInvocations
+}
+
+func (fake *FakeStore) recordInvocation(key string, args []interface{}) {
+	fake.invocationsMutex.Lock()
+	defer fake.invocationsMutex.Unlock()
+	if fake.invocations == nil {
+		fake.invocations = map[string][][]interface{}{}
+	}
+	if fake.invocations[key] == nil {
+		fake.invocations[key] = [][]interface{}{}
+	}
+	fake.invocations[key] = append(fake.invocations[key], args)
+}
+
+var _ cache.Store = new(FakeStore)
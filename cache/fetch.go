@@ -0,0 +1,222 @@
+package cache
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"code.cloudfoundry.org/lager"
+	"github.com/google/go-github/github"
+)
+
+// fetchOrgs fetches the repos of all orgs concurrently, making at most
+// c.concurrency requests at a time. It returns the repos and the error of
+// each org, in the order of the orgs, so that one org failing does not hold
+// up the others.
+func (c *CacheLoader) fetchOrgs(ctx context.Context, logger lager.Logger) ([][]Repo, []error) {
+	slots := make(chan struct{}, c.concurrency)
+	orgRepos := make([][]Repo, len(c.orgs))
+	errs := make([]error, len(c.orgs))
+
+	var wg sync.WaitGroup
+	for i, org := range c.orgs {
+		wg.Add(1)
+		go func(i int, org string) {
+			defer wg.Done()
+			orgRepos[i], errs[i] = c.fetchOrg(ctx, logger, slots, org)
+		}(i, org)
+	}
+	wg.Wait()
+
+	return orgRepos, errs
+}
+
+// fetchOrg fetches the repos of an org. Once the first page tells how many
+// there are, the others are fetched concurrently, until one of them fails.
+// Otherwise it follows the links to the next pages one by one.
+func (c *CacheLoader) fetchOrg(ctx context.Context, logger lager.Logger, slots chan struct{}, org string) ([]Repo, error) {
+	logger.Info("fetching-org", lager.Data{"org": org})
+
+	repos, resp, err := c.fetchPage(ctx, logger, slots, org, 1)
+	if err != nil {
+		return nil, err
+	}
+
+	if resp.LastPage <= 1 {
+		for resp.NextPage != 0 {
+			var more []Repo
+			more, resp, err = c.fetchPage(ctx, logger, slots, org, resp.NextPage)
+			if err != nil {
+				return nil, err
+			}
+			repos = append(repos, more...)
+		}
+		return repos, nil
+	}
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	pages := make([][]Repo, resp.LastPage-1)
+	errs := make([]error, resp.LastPage-1)
+	var wg sync.WaitGroup
+	for page := 2; page <= resp.LastPage; page++ {
+		wg.Add(1)
+		go func(page int) {
+			defer wg.Done()
+			pages[page-2], _, errs[page-2] = c.fetchPage(ctx, logger, slots, org, page)
+			if errs[page-2] != nil {
+				cancel()
+			}
+		}(page)
+	}
+	wg.Wait()
+
+	var firstErr error
+	for i := range pages {
+		if errs[i] != nil && (firstErr == nil || firstErr == context.Canceled) {
+			firstErr = errs[i]
+		}
+		repos = append(repos, pages[i]...)
+	}
+	if firstErr != nil {
+		return nil, firstErr
+	}
+	return repos, nil
+}
+
+// fetchPage fetches a page of the repos of an org, once one of the slots is
+// free.
+func (c *CacheLoader) fetchPage(ctx context.Context, logger lager.Logger, slots chan struct{}, org string, page int) ([]Repo, *github.Response, error) {
+	select {
+	case slots <- struct{}{}:
+		defer func() { <-slots }()
+	case <-ctx.Done():
+		return nil, nil, ctx.Err()
+	}
+	if err := ctx.Err(); err != nil {
+		return nil, nil, err
+	}
+
+	logger.Info("fetching-page", lager.Data{"org": org, "page": page})
+	opt := &github.RepositoryListByOrgOptions{
+		Type:        "public",
+		ListOptions: github.ListOptions{PerPage: 100, Page: page},
+	}
+	repos, resp, err := c.listByOrg(ctx, logger, org, opt)
+	if err != nil {
+		if ctx.Err() == nil {
+			logger.Error("failed-fetching-page", err, lager.Data{"org": org, "page": page})
+		}
+		return nil, nil, err
+	}
+
+	var found []Repo
+	for _, repo := range repos {
+		logger.Debug("found-repo", lager.Data{"repo": repo.Name})
+		if repo.Name == nil {
+			continue
+		}
+
+		found = append(found, Repo{Name: *repo.Name, Entry: githubEntry(repo, org)})
+	}
+
+	logger.Info("finished-page", lager.Data{
+		"org":            org,
+		"page":           page,
+		"next":           resp.NextPage,
+		"last":           resp.LastPage,
+		"rate-limit":     resp.Rate.Limit,
+		"rate-remaining": resp.Rate.Remaining,
+		"rate-reset":     resp.Rate.Reset.Time,
+	})
+	return found, resp, nil
+}
+
+// listByOrg fetches a page of the repos of an org. Before that it waits until
+// the quota of the GitHub API is reset if it is used up, and spreads out the
+// requests if it is running low. If GitHub rejects the request because a rate
+// limit was hit, it waits as long as GitHub asks and tries again.
+func (c *CacheLoader) listByOrg(ctx context.Context, logger lager.Logger, org string, opt *github.RepositoryListByOrgOptions) ([]*github.Repository, *github.Response, error) {
+	for {
+		if err := c.pace(ctx, logger); err != nil {
+			return nil, nil, err
+		}
+
+		repos, resp, err := c.repoService.ListByOrg(ctx, org, opt)
+		if resp != nil {
+			c.recordRate(resp.Rate)
+		}
+
+		var until time.Time
+		switch err := err.(type) {
+		case *github.RateLimitError:
+			c.recordRate(err.Rate)
+			until = err.Rate.Reset.Time
+		case *github.AbuseRateLimitError:
+			retryAfter := abuseRetryAfter
+			if err.RetryAfter != nil {
+				retryAfter = *err.RetryAfter
+			}
+			until = c.clock.Now().Add(retryAfter)
+		default:
+			return repos, resp, err
+		}
+
+		logger.Info("rate-limited", lager.Data{"org": org, "page": opt.Page, "until": until})
+		if err := c.waitForRateLimit(ctx, until); err != nil {
+			return nil, nil, err
+		}
+	}
+}
+
+// pace waits before the next request to the GitHub API according to the last
+// quota it reported.
+func (c *CacheLoader) pace(ctx context.Context, logger lager.Logger) error {
+	rate := c.Status().RateLimit
+	if rate == nil || rate.Limit == 0 {
+		return nil
+	}
+
+	now := c.clock.Now()
+	untilReset := rate.Reset.Sub(now)
+	if untilReset <= 0 {
+		return nil
+	}
+
+	if rate.Remaining == 0 {
+		logger.Info("rate-limit-exhausted", lager.Data{"until": rate.Reset})
+		return c.waitForRateLimit(ctx, rate.Reset)
+	}
+
+	if float64(rate.Remaining) < rateLimitReserve*float64(rate.Limit) {
+		delay := untilReset / time.Duration(rate.Remaining+1)
+		logger.Info("rate-limit-low", lager.Data{"remaining": rate.Remaining, "limit": rate.Limit, "delay": delay.String()})
+		return c.waitForRateLimit(ctx, now.Add(delay))
+	}
+
+	return nil
+}
+
+// waitForRateLimit blocks until the given time, and reports that the loader
+// is rate limited in the meantime. It returns the error of ctx if that is
+// done first.
+func (c *CacheLoader) waitForRateLimit(ctx context.Context, until time.Time) error {
+	delay := until.Sub(c.clock.Now())
+	if delay <= 0 {
+		return nil
+	}
+
+	c.setRateLimitedUntil(until)
+	defer c.setRateLimitedUntil(time.Time{})
+
+	timer := c.clock.NewTimer(delay)
+	defer timer.Stop()
+
+	select {
+	case <-timer.C():
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
@@ -0,0 +1,47 @@
+package cache
+
+import (
+	"net/url"
+	"strings"
+)
+
+// Forge is the kind of hosting service a repo lives on. It determines how
+// links to the source of a package are built.
+type Forge string
+
+const (
+	ForgeGitHub           Forge = "github"
+	ForgeGitHubEnterprise Forge = "github-enterprise"
+	ForgeGitLab           Forge = "gitlab"
+	ForgeGitea            Forge = "gitea"
+	ForgeGeneric          Forge = "generic"
+)
+
+// DetectForge guesses the forge of a location from its host name. Locations
+// on unknown hosts are treated as generic.
+func DetectForge(location string) Forge {
+	u, err := url.Parse(location)
+	if err != nil {
+		return ForgeGeneric
+	}
+
+	host := strings.ToLower(u.Hostname())
+	switch {
+	case host == "github.com":
+		return ForgeGitHub
+	case host == "gitlab.com" || strings.HasPrefix(host, "gitlab."):
+		return ForgeGitLab
+	case host == "gitea.com" || host == "codeberg.org" || strings.HasPrefix(host, "gitea."):
+		return ForgeGitea
+	}
+	return ForgeGeneric
+}
+
+// githubForge returns the forge of a repo reported by the GitHub API, which is
+// GitHub Enterprise unless the repo lives on github.com.
+func githubForge(location string) Forge {
+	if DetectForge(location) == ForgeGitHub {
+		return ForgeGitHub
+	}
+	return ForgeGitHubEnterprise
+}
@@ -0,0 +1,25 @@
+package cache_test
+
+import (
+	"github.com/cloudfoundry/go-fetcher/cache"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/ginkgo/extensions/table"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Forge", func() {
+	DescribeTable("DetectForge",
+		func(location string, expected cache.Forge) {
+			Expect(cache.DetectForge(location)).To(Equal(expected))
+		},
+		Entry("github.com", "https://github.com/org/repo", cache.ForgeGitHub),
+		Entry("github.com in upper case", "https://GitHub.com/org/repo", cache.ForgeGitHub),
+		Entry("gitlab.com", "https://gitlab.com/group/repo.git", cache.ForgeGitLab),
+		Entry("a self-hosted GitLab", "https://gitlab.example.com/group/repo", cache.ForgeGitLab),
+		Entry("gitea.com", "https://gitea.com/org/repo", cache.ForgeGitea),
+		Entry("codeberg.org", "https://codeberg.org/org/repo", cache.ForgeGitea),
+		Entry("an unknown host", "https://git.example.com/repo", cache.ForgeGeneric),
+		Entry("an invalid URL", "://nope", cache.ForgeGeneric),
+	)
+})
@@ -0,0 +1,149 @@
+package cache
+
+import (
+	"time"
+
+	"github.com/google/go-github/github"
+)
+
+// LoaderStatus describes the state of a CacheLoader.
+type LoaderStatus struct {
+	// RateLimit is the quota of the GitHub API as last reported by GitHub,
+	// or nil if it has not reported one yet.
+	RateLimit *RateLimit
+	// RateLimitedUntil is set while the loader waits for the quota to be
+	// reset.
+	RateLimitedUntil time.Time
+	// ConsecutiveFailures counts the refreshes that failed since the last
+	// successful one, and LastError is the error of the last of them.
+	ConsecutiveFailures int
+	LastError           string
+	// LastSuccess is when the last successful refresh finished.
+	LastSuccess time.Time
+	// NextRefresh is when the next refresh starts, which is sooner than
+	// usual while failing refreshes are retried.
+	NextRefresh time.Time
+	// Orgs is the state of each org that was fetched, in the order of the
+	// orgs.
+	Orgs []OrgStatus
+	// Blocked is the last refresh, if the DeletionGuard kept it from being
+	// stored.
+	Blocked *BlockedRefresh
+}
+
+// OrgStatus describes the state of the repos of an org.
+type OrgStatus struct {
+	Org string
+	// Repos is the number of repos found in the org by the last successful
+	// fetch.
+	Repos int
+	// LastSuccess is when the org was last fetched successfully.
+	LastSuccess time.Time
+	// ConsecutiveFailures counts the fetches of the org that failed since
+	// the last successful one, and LastError is the error of the last of
+	// them.
+	ConsecutiveFailures int
+	LastError           string
+}
+
+// RateLimit is the quota of the GitHub API.
+type RateLimit struct {
+	Limit     int
+	Remaining int
+	Reset     time.Time
+}
+
+// Status returns the current state of the loader.
+func (c *CacheLoader) Status() LoaderStatus {
+	c.statusLock.Lock()
+	defer c.statusLock.Unlock()
+
+	status := c.status
+	if status.RateLimit != nil {
+		rate := *status.RateLimit
+		status.RateLimit = &rate
+	}
+	if status.Blocked != nil {
+		blocked := *status.Blocked
+		status.Blocked = &blocked
+	}
+	status.Orgs = nil
+	for _, org := range c.orgs {
+		if orgStatus, ok := c.orgStatus[org]; ok {
+			status.Orgs = append(status.Orgs, orgStatus)
+		}
+	}
+	return status
+}
+
+// recordRate remembers the quota GitHub reported. Responses without a quota,
+// e.g. from GitHub Enterprise instances with rate limiting disabled, are
+// ignored.
+func (c *CacheLoader) recordRate(rate github.Rate) {
+	if rate.Limit == 0 {
+		return
+	}
+
+	c.statusLock.Lock()
+	defer c.statusLock.Unlock()
+	c.status.RateLimit = &RateLimit{
+		Limit:     rate.Limit,
+		Remaining: rate.Remaining,
+		Reset:     rate.Reset.Time,
+	}
+}
+
+func (c *CacheLoader) setRateLimitedUntil(until time.Time) {
+	c.statusLock.Lock()
+	defer c.statusLock.Unlock()
+	c.status.RateLimitedUntil = until
+}
+
+func (c *CacheLoader) recordSuccess() {
+	c.statusLock.Lock()
+	defer c.statusLock.Unlock()
+	c.status.ConsecutiveFailures = 0
+	c.status.LastError = ""
+	c.status.LastSuccess = c.clock.Now()
+}
+
+// recordFailure records a failed refresh, and returns the number of
+// consecutive failures.
+func (c *CacheLoader) recordFailure(err error) int {
+	c.statusLock.Lock()
+	defer c.statusLock.Unlock()
+	c.status.ConsecutiveFailures++
+	c.status.LastError = err.Error()
+	return c.status.ConsecutiveFailures
+}
+
+func (c *CacheLoader) setNextRefresh(next time.Time) {
+	c.statusLock.Lock()
+	defer c.statusLock.Unlock()
+	c.status.NextRefresh = next
+}
+
+func (c *CacheLoader) setBlocked(blocked *BlockedRefresh) {
+	c.statusLock.Lock()
+	defer c.statusLock.Unlock()
+	c.status.Blocked = blocked
+}
+
+func (c *CacheLoader) recordOrgSuccess(org string, repos int) {
+	c.statusLock.Lock()
+	defer c.statusLock.Unlock()
+	c.orgStatus[org] = OrgStatus{Org: org, Repos: repos, LastSuccess: c.clock.Now()}
+}
+
+// recordOrgFailure records a failed fetch of an org, and returns the number
+// of consecutive failures.
+func (c *CacheLoader) recordOrgFailure(org string, err error) int {
+	c.statusLock.Lock()
+	defer c.statusLock.Unlock()
+	orgStatus := c.orgStatus[org]
+	orgStatus.Org = org
+	orgStatus.ConsecutiveFailures++
+	orgStatus.LastError = err.Error()
+	c.orgStatus[org] = orgStatus
+	return orgStatus.ConsecutiveFailures
+}
@@ -0,0 +1,118 @@
+package cache
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+// snapshotFileVersion is increased whenever the format of the snapshot file
+// changes incompatibly.
+const snapshotFileVersion = 1
+
+type snapshotFile struct {
+	Version     int
+	RefreshedAt time.Time
+	// Repos are in the order they have to be added in to resolve names that
+	// only differ in case the same way again.
+	Repos []snapshotFileRepo
+}
+
+type snapshotFileRepo struct {
+	Name string
+	Entry
+}
+
+// Save writes the current contents of the cache to the file at path. The
+// file is replaced atomically, so a crash never leaves a partial snapshot
+// behind.
+func (l *LocationCache) Save(path string) error {
+	return l.snapshot().save(path)
+}
+
+// save writes the snapshot to the file at path, replacing it atomically.
+func (s *snapshot) save(path string) error {
+	file := snapshotFile{
+		Version:     snapshotFileVersion,
+		RefreshedAt: s.refreshedAt,
+	}
+	for _, name := range s.orderedNames() {
+		file.Repos = append(file.Repos, snapshotFileRepo{Name: name, Entry: s.items[name].entry()})
+	}
+
+	contents, err := json.Marshal(file)
+	if err != nil {
+		return err
+	}
+
+	tmp, err := ioutil.TempFile(filepath.Dir(path), filepath.Base(path)+".tmp")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err := tmp.Write(contents); err != nil {
+		tmp.Close()
+		return err
+	}
+	// the contents have to be on disk before the rename, or a power loss
+	// could keep the rename but not the contents
+	if err := tmp.Sync(); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), path)
+}
+
+// Load replaces the contents of the cache with the snapshot saved to the file
+// at path. The cache keeps the refresh time of the snapshot, so that its age
+// reflects when its contents were fetched.
+func (l *LocationCache) Load(path string) error {
+	contents, err := ioutil.ReadFile(path)
+	if err != nil {
+		return err
+	}
+
+	var file snapshotFile
+	if err := json.Unmarshal(contents, &file); err != nil {
+		return fmt.Errorf("invalid snapshot file %s: %s", path, err)
+	}
+	if file.Version != snapshotFileVersion {
+		return fmt.Errorf("invalid snapshot file %s: unsupported version %d", path, file.Version)
+	}
+
+	s := newSnapshot()
+	for _, repo := range file.Repos {
+		s.add(l.logger, repo.Name, newCacheEntry(repo.Entry, repo.UpdatedAt))
+	}
+	s.refreshedAt = file.RefreshedAt
+
+	old, _ := l.update(func(*snapshot) *snapshot {
+		return s
+	}, nil)
+	l.logger.Info("cache-items-load", l.swapData(old, s))
+	return nil
+}
+
+// orderedNames returns the sorted names of the repos, except that of the
+// names that only differ in case, the one case-insensitive lookups resolve to
+// comes last.
+func (s *snapshot) orderedNames() []string {
+	names := s.names()
+	sort.SliceStable(names, func(i, j int) bool {
+		fi, fj := strings.ToLower(names[i]), strings.ToLower(names[j])
+		if fi != fj {
+			return fi < fj
+		}
+		return s.folded[fj] == names[j] && s.folded[fi] != names[i]
+	})
+	return names
+}
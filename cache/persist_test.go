@@ -0,0 +1,89 @@
+package cache_test
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"time"
+
+	"code.cloudfoundry.org/clock/fakeclock"
+	"code.cloudfoundry.org/lager/lagertest"
+	"github.com/cloudfoundry/go-fetcher/cache"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Saving and loading", func() {
+	var (
+		tmpDir    string
+		cacheFile string
+		clock     *fakeclock.FakeClock
+	)
+
+	newCache := func() *cache.LocationCache {
+		return cache.NewLocationCache(lagertest.NewTestLogger("cache"), clock)
+	}
+
+	BeforeEach(func() {
+		var err error
+		tmpDir, err = ioutil.TempDir("", "cache")
+		Expect(err).NotTo(HaveOccurred())
+		cacheFile = filepath.Join(tmpDir, "cache.json")
+		clock = fakeclock.NewFakeClock(time.Date(2019, 6, 1, 12, 0, 0, 0, time.UTC))
+	})
+
+	AfterEach(func() {
+		Expect(os.RemoveAll(tmpDir)).To(Succeed())
+	})
+
+	It("restores the entries, the refresh time and the case resolution", func() {
+		original := newCache()
+		original.ReplaceAll([]cache.Repo{
+			{Name: "routing-api", Entry: cache.Entry{Location: "https://github.com/org2/routing-api", Org: "org2"}},
+			{Name: "Routing-API", Entry: cache.Entry{
+				Location:      "https://github.com/org1/Routing-API",
+				Forge:         cache.ForgeGitHub,
+				DefaultBranch: "main",
+				Description:   "The routing API",
+				Archived:      true,
+				Org:           "org1",
+			}},
+		})
+		Expect(original.Save(cacheFile)).To(Succeed())
+
+		clock.Increment(time.Hour)
+		loaded := newCache()
+		Expect(loaded.Load(cacheFile)).To(Succeed())
+
+		Expect(loaded.List()).To(Equal(original.List()))
+		stats := loaded.Stats()
+		Expect(stats.RefreshedAt).To(BeTemporally("==", original.Stats().RefreshedAt))
+		Expect(stats.Age).To(Equal(time.Hour))
+
+		name, _, ok := loaded.Lookup("ROUTING-API")
+		Expect(ok).To(BeTrue())
+		Expect(name).To(Equal("Routing-API"))
+		Expect(stats.CaseConflicts["routing-api"]).To(Equal([]string{"Routing-API", "routing-api"}))
+	})
+
+	It("replaces the file without leaving temporary files behind", func() {
+		Expect(ioutil.WriteFile(cacheFile, []byte("old contents"), 0644)).To(Succeed())
+		Expect(newCache().Save(cacheFile)).To(Succeed())
+
+		files, err := ioutil.ReadDir(tmpDir)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(files).To(HaveLen(1))
+		Expect(newCache().Load(cacheFile)).To(Succeed())
+	})
+
+	It("fails when the file does not exist", func() {
+		err := newCache().Load(cacheFile)
+		Expect(os.IsNotExist(err)).To(BeTrue())
+	})
+
+	It("fails when the file has another version", func() {
+		Expect(ioutil.WriteFile(cacheFile, []byte(`{"Version": 2}`), 0644)).To(Succeed())
+		Expect(newCache().Load(cacheFile)).To(MatchError(ContainSubstring("unsupported version 2")))
+	})
+})
@@ -0,0 +1,173 @@
+package cache
+
+import (
+	"sort"
+	"time"
+
+	"code.cloudfoundry.org/lager"
+)
+
+// DiffHistory is the number of refreshes with changes whose diffs are kept.
+const DiffHistory = 50
+
+// The sources of the changes of a RefreshDiff.
+const (
+	DiffSourceRefresh  = "refresh"
+	DiffSourceAccepted = "accepted-refresh"
+	DiffSourceWebhook  = "webhook"
+)
+
+// RefreshDiff is what a refresh, an accepted blocked refresh or a webhook
+// event changed about the stored repos.
+type RefreshDiff struct {
+	At time.Time
+	// Source is one of DiffSourceRefresh, DiffSourceAccepted and
+	// DiffSourceWebhook, and Action the action of the webhook event, e.g.
+	// renamed or transferred.
+	Source string
+	Action string
+	// Added and Removed are the repos that are new or gone.
+	Added   []RepoChange
+	Removed []RepoChange
+	// Relocated are the repos whose Location changed, and OrgChanged those
+	// that moved to another org, e.g. when a repo was transferred and
+	// another org now has one by the same name.
+	Relocated  []RepoChange
+	OrgChanged []RepoChange
+}
+
+// RepoChange is a repo as it is after a refresh, or before it if it was
+// removed, along with the Location and Org it had before if they changed.
+type RepoChange struct {
+	Name             string
+	Location         string
+	Org              string
+	PreviousLocation string
+	PreviousOrg      string
+}
+
+// Empty reports whether the refresh changed nothing.
+func (d RefreshDiff) Empty() bool {
+	return len(d.Added) == 0 && len(d.Removed) == 0 && len(d.Relocated) == 0 && len(d.OrgChanged) == 0
+}
+
+// Involves reports whether the refresh changed the repo with the given name.
+func (d RefreshDiff) Involves(name string) bool {
+	for _, changes := range [][]RepoChange{d.Added, d.Removed, d.Relocated, d.OrgChanged} {
+		for _, change := range changes {
+			if change.Name == name {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// diffRepos returns the changes from the repos in old to those in new. Of
+// several repos with the same name in new, the last one counts, as it does
+// for Store.ReplaceAll.
+func diffRepos(old, new []Repo, at time.Time, source string) RefreshDiff {
+	oldEntries := map[string]Entry{}
+	for _, repo := range old {
+		oldEntries[repo.Name] = repo.Entry
+	}
+	newEntries := map[string]Entry{}
+	for _, repo := range new {
+		newEntries[repo.Name] = repo.Entry
+	}
+
+	diff := RefreshDiff{At: at, Source: source}
+	for name, entry := range newEntries {
+		oldEntry, ok := oldEntries[name]
+		if !ok {
+			diff.Added = append(diff.Added, RepoChange{Name: name, Location: entry.Location, Org: entry.Org})
+			continue
+		}
+
+		change := RepoChange{
+			Name:             name,
+			Location:         entry.Location,
+			Org:              entry.Org,
+			PreviousLocation: oldEntry.Location,
+			PreviousOrg:      oldEntry.Org,
+		}
+		if entry.Location != oldEntry.Location {
+			diff.Relocated = append(diff.Relocated, change)
+		}
+		if entry.Org != oldEntry.Org {
+			diff.OrgChanged = append(diff.OrgChanged, change)
+		}
+	}
+	for name, entry := range oldEntries {
+		if _, ok := newEntries[name]; !ok {
+			diff.Removed = append(diff.Removed, RepoChange{Name: name, Location: entry.Location, Org: entry.Org})
+		}
+	}
+
+	for _, changes := range [][]RepoChange{diff.Added, diff.Removed, diff.Relocated, diff.OrgChanged} {
+		sort.Slice(changes, func(i, j int) bool { return changes[i].Name < changes[j].Name })
+	}
+	return diff
+}
+
+// names returns the names of the changed repos.
+func names(changes []RepoChange) []string {
+	var changed []string
+	for _, change := range changes {
+		changed = append(changed, change.Name)
+	}
+	return changed
+}
+
+// recordDiff logs the changes of a stored refresh, and keeps the diff if
+// there are any. When the store was empty, the added repos are only logged
+// at debug level, so that filling it does not flood the logs.
+func (c *CacheLoader) recordDiff(logger lager.Logger, diff RefreshDiff, stored int) {
+	logger = logger.WithData(lager.Data{"source": diff.Source})
+	logger.Info("refresh-diff", lager.Data{
+		"added":       len(diff.Added),
+		"removed":     len(diff.Removed),
+		"relocated":   len(diff.Relocated),
+		"org-changed": len(diff.OrgChanged),
+	})
+	if diff.Empty() {
+		return
+	}
+
+	logAdded := logger.Info
+	if stored == 0 {
+		logAdded = logger.Debug
+	}
+	for _, change := range diff.Added {
+		logAdded("repo-added", lager.Data{"repo": change.Name, "location": change.Location, "org": change.Org})
+	}
+	for _, change := range diff.Removed {
+		logger.Info("repo-removed", lager.Data{"repo": change.Name, "location": change.Location, "org": change.Org})
+	}
+	for _, change := range diff.Relocated {
+		logger.Info("repo-relocated", lager.Data{"repo": change.Name, "location": change.Location, "previous-location": change.PreviousLocation})
+	}
+	for _, change := range diff.OrgChanged {
+		logger.Info("repo-org-changed", lager.Data{"repo": change.Name, "org": change.Org, "previous-org": change.PreviousOrg})
+	}
+
+	c.diffsLock.Lock()
+	defer c.diffsLock.Unlock()
+	c.diffs = append(c.diffs, diff)
+	if len(c.diffs) > DiffHistory {
+		c.diffs = c.diffs[len(c.diffs)-DiffHistory:]
+	}
+}
+
+// Diffs returns the diffs of the last DiffHistory refreshes that changed the
+// stored repos, newest first.
+func (c *CacheLoader) Diffs() []RefreshDiff {
+	c.diffsLock.Lock()
+	defer c.diffsLock.Unlock()
+
+	diffs := make([]RefreshDiff, 0, len(c.diffs))
+	for i := len(c.diffs) - 1; i >= 0; i-- {
+		diffs = append(diffs, c.diffs[i])
+	}
+	return diffs
+}
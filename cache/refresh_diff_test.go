@@ -0,0 +1,241 @@
+package cache_test
+
+import (
+	"context"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"time"
+
+	"code.cloudfoundry.org/clock/fakeclock"
+	"code.cloudfoundry.org/lager/lagertest"
+	"github.com/cloudfoundry/go-fetcher/cache"
+	"github.com/cloudfoundry/go-fetcher/cache/fakes"
+	"github.com/google/go-github/github"
+	"github.com/onsi/gomega/gbytes"
+	"github.com/tedsuo/ifrit"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Refresh diffs", func() {
+	var (
+		fakeRepoService *fakes.FakeRepositoriesService
+		fakeClock       *fakeclock.FakeClock
+		locCache        *cache.LocationCache
+		guard           cache.DeletionGuard
+		cacheLoader     *cache.CacheLoader
+		logger          *lagertest.TestLogger
+		// orgRepos maps the orgs to the names and locations of their repos.
+		orgRepos map[string]map[string]string
+	)
+
+	BeforeEach(func() {
+		fakeRepoService = &fakes.FakeRepositoriesService{}
+		fakeRepoService.ListByOrgStub = func(_ context.Context, org string, _ *github.RepositoryListByOrgOptions) ([]*github.Repository, *github.Response, error) {
+			var repos []*github.Repository
+			for name, location := range orgRepos[org] {
+				repos = append(repos, &github.Repository{Name: github.String(name), HTMLURL: github.String(location)})
+			}
+			return repos, &github.Response{}, nil
+		}
+
+		fakeClock = fakeclock.NewFakeClock(time.Date(2019, 6, 1, 12, 0, 0, 0, time.UTC))
+		locCache = cache.NewLocationCache(lagertest.NewTestLogger("cache"), fakeClock)
+		Expect(locCache.ReplaceAll([]cache.Repo{
+			{Name: "repo1", Entry: cache.Entry{Location: "https://github.com/org1/repo1", Org: "org1"}},
+			{Name: "repo2", Entry: cache.Entry{Location: "https://github.com/org1/repo2", Org: "org1"}},
+			{Name: "repo3", Entry: cache.Entry{Location: "https://github.com/org1/repo3", Org: "org1"}},
+		})).To(Succeed())
+
+		guard = cache.DeletionGuard{}
+		logger = lagertest.NewTestLogger("cache-loader")
+		cacheLoader = nil
+	})
+
+	// refresh runs the loader for a single refresh, a minute after the last.
+	refresh := func() {
+		if cacheLoader == nil {
+			retryPolicy := cache.RetryPolicy{InitialBackoff: time.Second, MaxBackoff: time.Minute}
+			cacheLoader = cache.NewCacheLoader(logger, []string{"org1", "org2"}, locCache, fakeRepoService, fakeClock, retryPolicy, 1, guard)
+		}
+		fakeClock.Increment(time.Minute)
+		last := cacheLoader.Status().NextRefresh
+
+		process := ifrit.Background(cacheLoader)
+		Eventually(func() time.Time { return cacheLoader.Status().NextRefresh }).ShouldNot(Equal(last))
+		process.Signal(os.Interrupt)
+		Eventually(process.Wait()).Should(Receive())
+	}
+
+	It("records the repos that were added, removed, relocated or moved to another org", func() {
+		orgRepos = map[string]map[string]string{
+			"org1": {
+				"repo1": "https://github.com/org1/repo1",
+				"repo2": "https://github.com/org1/repo2-renamed",
+			},
+			"org2": {
+				"repo3": "https://github.com/org2/repo3",
+				"repo4": "https://github.com/org2/repo4",
+			},
+		}
+		refresh()
+
+		diffs := cacheLoader.Diffs()
+		Expect(diffs).To(HaveLen(1))
+		Expect(diffs[0].At).To(Equal(fakeClock.Now()))
+		Expect(diffs[0].Source).To(Equal(cache.DiffSourceRefresh))
+		Expect(diffs[0].Added).To(Equal([]cache.RepoChange{
+			{Name: "repo4", Location: "https://github.com/org2/repo4", Org: "org2"},
+		}))
+		Expect(diffs[0].Removed).To(BeEmpty())
+		Expect(diffs[0].Relocated).To(Equal([]cache.RepoChange{
+			{Name: "repo2", Location: "https://github.com/org1/repo2-renamed", Org: "org1", PreviousLocation: "https://github.com/org1/repo2", PreviousOrg: "org1"},
+			{Name: "repo3", Location: "https://github.com/org2/repo3", Org: "org2", PreviousLocation: "https://github.com/org1/repo3", PreviousOrg: "org1"},
+		}))
+		Expect(diffs[0].OrgChanged).To(Equal([]cache.RepoChange{
+			{Name: "repo3", Location: "https://github.com/org2/repo3", Org: "org2", PreviousLocation: "https://github.com/org1/repo3", PreviousOrg: "org1"},
+		}))
+		Expect(diffs[0].Involves("repo3")).To(BeTrue())
+		Expect(diffs[0].Involves("repo1")).To(BeFalse())
+
+		Expect(logger).To(gbytes.Say("repo-relocated"))
+	})
+
+	It("records the changes of repository events", func() {
+		retryPolicy := cache.RetryPolicy{InitialBackoff: time.Second, MaxBackoff: time.Minute}
+		cacheLoader = cache.NewCacheLoader(logger, []string{"org1", "org2"}, locCache, fakeRepoService, fakeClock, retryPolicy, 1, guard)
+
+		event := cache.RepositoryEvent{
+			Action: "renamed",
+			Repository: &github.Repository{
+				Name:    github.String("repo4"),
+				HTMLURL: github.String("https://github.com/org1/repo4"),
+				Owner:   &github.User{Login: github.String("org1")},
+			},
+		}
+		event.Changes.Repository.Name.From = "repo3"
+		changed, err := cacheLoader.ApplyRepositoryEvent(logger, event)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(changed).To(BeTrue())
+
+		diffs := cacheLoader.Diffs()
+		Expect(diffs).To(HaveLen(1))
+		Expect(diffs[0].Source).To(Equal(cache.DiffSourceWebhook))
+		Expect(diffs[0].Action).To(Equal("renamed"))
+		Expect(diffs[0].Added).To(Equal([]cache.RepoChange{{Name: "repo4", Location: "https://github.com/org1/repo4", Org: "org1"}}))
+		Expect(diffs[0].Removed).To(Equal([]cache.RepoChange{{Name: "repo3", Location: "https://github.com/org1/repo3", Org: "org1"}}))
+
+		orgRepos = map[string]map[string]string{
+			"org1": {
+				"repo1": "https://github.com/org1/repo1",
+				"repo2": "https://github.com/org1/repo2",
+				"repo4": "https://github.com/org1/repo4",
+			},
+		}
+		refresh()
+		Expect(cacheLoader.Diffs()).To(HaveLen(1))
+	})
+
+	It("records removed repos", func() {
+		orgRepos = map[string]map[string]string{
+			"org1": {"repo1": "https://github.com/org1/repo1"},
+		}
+		refresh()
+
+		diffs := cacheLoader.Diffs()
+		Expect(diffs).To(HaveLen(1))
+		Expect(diffs[0].Removed).To(Equal([]cache.RepoChange{
+			{Name: "repo2", Location: "https://github.com/org1/repo2", Org: "org1"},
+			{Name: "repo3", Location: "https://github.com/org1/repo3", Org: "org1"},
+		}))
+	})
+
+	It("only keeps refreshes that changed something, newest first", func() {
+		orgRepos = map[string]map[string]string{
+			"org1": {"repo1": "https://github.com/org1/repo1", "repo2": "https://github.com/org1/repo2"},
+		}
+		refresh()
+		refresh()
+
+		orgRepos["org1"]["repo5"] = "https://github.com/org1/repo5"
+		refresh()
+
+		diffs := cacheLoader.Diffs()
+		Expect(diffs).To(HaveLen(2))
+		Expect(diffs[0].Added).To(HaveLen(1))
+		Expect(diffs[0].Added[0].Name).To(Equal("repo5"))
+		Expect(diffs[1].Removed).To(HaveLen(1))
+		Expect(diffs[1].Removed[0].Name).To(Equal("repo3"))
+	})
+
+	It("keeps the last DiffHistory diffs", func() {
+		for i := 0; i < cache.DiffHistory+2; i++ {
+			orgRepos = map[string]map[string]string{
+				"org1": {fmt.Sprintf("repo-%d", i): "https://github.com/org1/repo"},
+			}
+			refresh()
+		}
+
+		diffs := cacheLoader.Diffs()
+		Expect(diffs).To(HaveLen(cache.DiffHistory))
+		Expect(diffs[0].Added[0].Name).To(Equal(fmt.Sprintf("repo-%d", cache.DiffHistory+1)))
+		Expect(diffs[cache.DiffHistory-1].Added[0].Name).To(Equal("repo-2"))
+	})
+
+	It("does not record refreshes that could not be stored", func() {
+		tmpDir, err := ioutil.TempDir("", "refresh-diff")
+		Expect(err).NotTo(HaveOccurred())
+		defer os.RemoveAll(tmpDir)
+		cacheFile := filepath.Join(tmpDir, "dir", "cache.json")
+		Expect(os.Mkdir(filepath.Dir(cacheFile), 0755)).To(Succeed())
+
+		store := cache.NewDiskStore(lagertest.NewTestLogger("disk-store"), fakeClock, cacheFile)
+		Expect(store.ReplaceAll(locCache.List())).To(Succeed())
+		retryPolicy := cache.RetryPolicy{InitialBackoff: time.Second, MaxBackoff: time.Minute}
+		cacheLoader = cache.NewCacheLoader(logger, []string{"org1", "org2"}, store, fakeRepoService, fakeClock, retryPolicy, 1, guard)
+
+		Expect(os.RemoveAll(filepath.Dir(cacheFile))).To(Succeed())
+		orgRepos = map[string]map[string]string{
+			"org1": {
+				"repo1": "https://github.com/org1/repo1",
+				"repo2": "https://github.com/org1/repo2",
+				"repo3": "https://github.com/org1/repo3",
+				"repo4": "https://github.com/org1/repo4",
+			},
+		}
+		refresh()
+		Expect(cacheLoader.Status().ConsecutiveFailures).To(Equal(1))
+		Expect(cacheLoader.Diffs()).To(BeEmpty())
+		Expect(store.List()).To(HaveLen(3))
+		_, _, ok := store.Lookup("repo4")
+		Expect(ok).To(BeFalse())
+
+		Expect(os.Mkdir(filepath.Dir(cacheFile), 0755)).To(Succeed())
+		refresh()
+		Expect(cacheLoader.Status().ConsecutiveFailures).To(BeZero())
+		diffs := cacheLoader.Diffs()
+		Expect(diffs).To(HaveLen(1))
+		Expect(diffs[0].Added).To(Equal([]cache.RepoChange{{Name: "repo4", Location: "https://github.com/org1/repo4", Org: "org1"}}))
+		Expect(store.List()).To(HaveLen(4))
+	})
+
+	It("does not record blocked refreshes until they are accepted", func() {
+		guard.MaxRemoved = 1
+		orgRepos = map[string]map[string]string{
+			"org1": {"repo1": "https://github.com/org1/repo1"},
+		}
+		refresh()
+		Expect(cacheLoader.Diffs()).To(BeEmpty())
+
+		_, err := cacheLoader.AcceptBlocked(cacheLoader.Status().Blocked.ID)
+		Expect(err).NotTo(HaveOccurred())
+
+		diffs := cacheLoader.Diffs()
+		Expect(diffs).To(HaveLen(1))
+		Expect(diffs[0].Source).To(Equal(cache.DiffSourceAccepted))
+		Expect(diffs[0].Removed).To(HaveLen(2))
+	})
+})
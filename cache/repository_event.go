@@ -0,0 +1,155 @@
+package cache
+
+import (
+	"errors"
+	"strings"
+
+	"code.cloudfoundry.org/lager"
+	"github.com/google/go-github/github"
+)
+
+// RepositoryEvent is the payload of a repository webhook event from GitHub.
+type RepositoryEvent struct {
+	// Action is one of created, deleted, renamed, transferred, archived,
+	// unarchived, edited, publicized and privatized.
+	Action     string             `json:"action"`
+	Repository *github.Repository `json:"repository"`
+	Changes    RepositoryChanges  `json:"changes"`
+}
+
+// RepositoryChanges are the previous values of a renamed or transferred
+// repository.
+type RepositoryChanges struct {
+	Repository struct {
+		Name struct {
+			From string `json:"from"`
+		} `json:"name"`
+	} `json:"repository"`
+	Owner struct {
+		From struct {
+			Organization *github.Organization `json:"organization"`
+			User         *github.User         `json:"user"`
+		} `json:"from"`
+	} `json:"owner"`
+}
+
+// ApplyRepositoryEvent updates store with an event about a repo of one of the
+// orgs, so that the change shows up before the next refresh. Like a refresh,
+// it only keeps public repos, and of repos with the same name in several orgs
+// the one of the first org. It reports whether the store changed.
+//
+// A repo that is removed is not replaced by a repo of the same name in
+// another org until the next refresh.
+func ApplyRepositoryEvent(logger lager.Logger, store Store, orgs []string, event RepositoryEvent) (bool, error) {
+	repo := event.Repository
+	if repo == nil || repo.GetName() == "" {
+		return false, errors.New("repository event without a repository")
+	}
+
+	logger = logger.Session("apply-repository-event", lager.Data{"action": event.Action, "repo": repo.GetName(), "owner": repo.GetOwner().GetLogin()})
+	org, orgIndex, ok := findOrg(orgs, repo.GetOwner().GetLogin())
+
+	switch event.Action {
+	case "created", "edited", "archived", "unarchived", "publicized", "privatized", "deleted":
+		if !ok {
+			return false, nil
+		}
+		if event.Action == "deleted" || repo.GetPrivate() {
+			return removeRepo(logger, store, org, repo.GetName())
+		}
+		return upsertRepo(logger, store, orgs, orgIndex, repo)
+
+	case "renamed":
+		if !ok {
+			return false, nil
+		}
+		removed, err := removeRepo(logger, store, org, event.Changes.Repository.Name.From)
+		if err != nil || repo.GetPrivate() {
+			return removed, err
+		}
+		added, err := upsertRepo(logger, store, orgs, orgIndex, repo)
+		return removed || added, err
+
+	case "transferred":
+		from := event.Changes.Owner.From.Organization.GetLogin()
+		if from == "" {
+			from = event.Changes.Owner.From.User.GetLogin()
+		}
+
+		var removed, added bool
+		var err error
+		if fromOrg, _, fromOk := findOrg(orgs, from); fromOk {
+			if removed, err = removeRepo(logger, store, fromOrg, repo.GetName()); err != nil {
+				return removed, err
+			}
+		}
+		if ok && !repo.GetPrivate() {
+			added, err = upsertRepo(logger, store, orgs, orgIndex, repo)
+		}
+		return removed || added, err
+	}
+
+	logger.Debug("ignored-action")
+	return false, nil
+}
+
+// ApplyRepositoryEvent updates the store of the loader with an event like the
+// function of the same name, in turn with refreshes, and records what it
+// changed as a diff.
+func (c *CacheLoader) ApplyRepositoryEvent(logger lager.Logger, event RepositoryEvent) (bool, error) {
+	c.storeLock.Lock()
+	defer c.storeLock.Unlock()
+
+	stored := c.store.List()
+	changed, err := ApplyRepositoryEvent(logger, c.store, c.orgs, event)
+	if changed {
+		diff := diffRepos(stored, c.store.List(), c.clock.Now(), DiffSourceWebhook)
+		diff.Action = event.Action
+		c.recordDiff(logger, diff, len(stored))
+	}
+	return changed, err
+}
+
+// upsertRepo stores a repo of orgs[orgIndex], unless a repo of the same name
+// from an earlier org is stored already.
+func upsertRepo(logger lager.Logger, store Store, orgs []string, orgIndex int, repo *github.Repository) (bool, error) {
+	if name, existing, ok := store.Lookup(repo.GetName()); ok && name == repo.GetName() {
+		if _, existingIndex, ok := findOrg(orgs, existing.Org); ok && existingIndex < orgIndex {
+			logger.Info("shadowed-by-earlier-org", lager.Data{"other-org": existing.Org})
+			return false, nil
+		}
+	}
+
+	if err := store.Upsert(repo.GetName(), githubEntry(repo, orgs[orgIndex])); err != nil {
+		logger.Error("failed-upserting-repo", err)
+		return false, err
+	}
+	logger.Info("upserted-repo")
+	return true, nil
+}
+
+// removeRepo deletes a repo if the one stored under its name is from org.
+func removeRepo(logger lager.Logger, store Store, org, repoName string) (bool, error) {
+	name, existing, ok := store.Lookup(repoName)
+	if !ok || name != repoName || !strings.EqualFold(existing.Org, org) {
+		return false, nil
+	}
+
+	if err := store.Delete(repoName); err != nil {
+		logger.Error("failed-deleting-repo", err, lager.Data{"deleted": repoName})
+		return false, err
+	}
+	logger.Info("deleted-repo", lager.Data{"deleted": repoName})
+	return true, nil
+}
+
+// findOrg returns the org as it is configured and its position in orgs,
+// ignoring case as GitHub does.
+func findOrg(orgs []string, login string) (string, int, bool) {
+	for i, org := range orgs {
+		if login != "" && strings.EqualFold(org, login) {
+			return org, i, true
+		}
+	}
+	return "", 0, false
+}
@@ -0,0 +1,112 @@
+package cache_test
+
+import (
+	"errors"
+	"time"
+
+	"code.cloudfoundry.org/clock/fakeclock"
+	"code.cloudfoundry.org/lager/lagertest"
+	"github.com/cloudfoundry/go-fetcher/cache"
+	"github.com/cloudfoundry/go-fetcher/cache/fakes"
+	"github.com/google/go-github/github"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("ApplyRepositoryEvent", func() {
+	var (
+		locCache *cache.LocationCache
+		orgs     []string
+	)
+
+	event := func(action, owner, name string) cache.RepositoryEvent {
+		url := "https://github.com/" + owner + "/" + name
+		return cache.RepositoryEvent{
+			Action: action,
+			Repository: &github.Repository{
+				Name:    github.String(name),
+				HTMLURL: github.String(url),
+				Owner:   &github.User{Login: github.String(owner)},
+			},
+		}
+	}
+
+	apply := func(e cache.RepositoryEvent) bool {
+		changed, err := cache.ApplyRepositoryEvent(lagertest.NewTestLogger("event"), locCache, orgs, e)
+		Expect(err).NotTo(HaveOccurred())
+		return changed
+	}
+
+	BeforeEach(func() {
+		orgs = []string{"org1", "org2"}
+		locCache = cache.NewLocationCache(lagertest.NewTestLogger("cache"), fakeclock.NewFakeClock(time.Now()))
+		locCache.ReplaceAll([]cache.Repo{
+			{Name: "repo1", Entry: cache.Entry{Location: "https://github.com/org1/repo1", Org: "org1"}},
+			{Name: "repo2", Entry: cache.Entry{Location: "https://github.com/org2/repo2", Org: "org2"}},
+		})
+	})
+
+	It("prefers the first org for each repo", func() {
+		Expect(apply(event("created", "org2", "repo1"))).To(BeFalse())
+		_, entry, _ := locCache.Lookup("repo1")
+		Expect(entry.Org).To(Equal("org1"))
+
+		Expect(apply(event("created", "org1", "repo2"))).To(BeTrue())
+		_, entry, _ = locCache.Lookup("repo2")
+		Expect(entry.Org).To(Equal("org1"))
+	})
+
+	It("does not remove a repo of the same name from another org", func() {
+		Expect(apply(event("deleted", "org2", "repo1"))).To(BeFalse())
+		_, _, ok := locCache.Lookup("repo1")
+		Expect(ok).To(BeTrue())
+	})
+
+	It("adds repos transferred into one of the orgs", func() {
+		e := event("transferred", "org2", "moved")
+		e.Changes.Owner.From.User = &github.User{Login: github.String("someone")}
+
+		Expect(apply(e)).To(BeTrue())
+		_, entry, ok := locCache.Lookup("moved")
+		Expect(ok).To(BeTrue())
+		Expect(entry.Org).To(Equal("org2"))
+	})
+
+	It("moves repos transferred between the orgs", func() {
+		e := event("transferred", "org2", "repo1")
+		e.Changes.Owner.From.Organization = &github.Organization{Login: github.String("org1")}
+
+		Expect(apply(e)).To(BeTrue())
+		_, entry, ok := locCache.Lookup("repo1")
+		Expect(ok).To(BeTrue())
+		Expect(entry.Org).To(Equal("org2"))
+	})
+
+	It("removes repos renamed while being made private", func() {
+		e := event("renamed", "org1", "new-name")
+		e.Repository.Private = github.Bool(true)
+		e.Changes.Repository.Name.From = "repo1"
+
+		Expect(apply(e)).To(BeTrue())
+		Expect(locCache.List()).To(HaveLen(1))
+	})
+
+	It("ignores other actions", func() {
+		Expect(apply(event("anonymous_access_enabled", "org1", "repo1"))).To(BeFalse())
+	})
+
+	It("fails without a repository", func() {
+		_, err := cache.ApplyRepositoryEvent(lagertest.NewTestLogger("event"), locCache, orgs, cache.RepositoryEvent{Action: "created"})
+		Expect(err).To(HaveOccurred())
+	})
+
+	It("returns the errors of the store", func() {
+		fakeStore := &fakes.FakeStore{}
+		fakeStore.UpsertReturns(errors.New("disk full"))
+
+		changed, err := cache.ApplyRepositoryEvent(lagertest.NewTestLogger("event"), fakeStore, orgs, event("created", "org1", "repo3"))
+		Expect(err).To(MatchError("disk full"))
+		Expect(changed).To(BeFalse())
+	})
+})
@@ -0,0 +1,46 @@
+package cache
+
+import (
+	"math/rand"
+	"time"
+)
+
+// RetryPolicy decides how a CacheLoader retries failing refreshes. The waits
+// between retries double from InitialBackoff up to MaxBackoff, and are
+// shortened by up to the Jitter fraction at random, so that go-fetchers
+// sharing a token do not retry in lockstep.
+type RetryPolicy struct {
+	InitialBackoff time.Duration
+	MaxBackoff     time.Duration
+	Jitter         float64
+	// StartupTimeout is how long the initial refresh is retried before the
+	// loader gives up, unless the store has the repos of an earlier refresh.
+	// Zero gives up after the first failure.
+	StartupTimeout time.Duration
+}
+
+// DefaultRetryPolicy retries a failing refresh after 5 seconds at first, and
+// at least every CacheUpdateInterval.
+var DefaultRetryPolicy = RetryPolicy{
+	InitialBackoff: 5 * time.Second,
+	MaxBackoff:     CacheUpdateInterval,
+	Jitter:         0.2,
+	StartupTimeout: 5 * time.Minute,
+}
+
+// backoff returns how long to wait after the given number of consecutive
+// failures.
+func (p RetryPolicy) backoff(failures int, random *rand.Rand) time.Duration {
+	backoff := p.InitialBackoff
+	for i := 1; i < failures && backoff < p.MaxBackoff; i++ {
+		backoff *= 2
+	}
+	if backoff > p.MaxBackoff {
+		backoff = p.MaxBackoff
+	}
+
+	if p.Jitter > 0 {
+		backoff -= time.Duration(random.Float64() * p.Jitter * float64(backoff))
+	}
+	return backoff
+}
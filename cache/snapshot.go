@@ -0,0 +1,143 @@
+package cache
+
+import (
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"code.cloudfoundry.org/lager"
+)
+
+// snapshot is the contents of a LocationCache at one point in time. Once it
+// has been published to readers it is never modified again; changes are
+// made to a clone that is published in its place.
+type snapshot struct {
+	items map[string]*cacheEntry
+	// folded maps the lower-case form of each repo name to the name that
+	// case-insensitive lookups resolve to.
+	folded map[string]string
+	// conflicts lists the names that only differ in case, by their
+	// lower-case form.
+	conflicts map[string][]string
+	// refreshedAt is the time of the last ReplaceAll.
+	refreshedAt time.Time
+
+	// oldest is the earliest update time of the repos, found on the first
+	// call of oldestUpdate rather than on every change.
+	oldest     time.Time
+	oldestOnce sync.Once
+}
+
+func newSnapshot() *snapshot {
+	return &snapshot{
+		items:     map[string]*cacheEntry{},
+		folded:    map[string]string{},
+		conflicts: map[string][]string{},
+	}
+}
+
+func (s *snapshot) clone() *snapshot {
+	c := &snapshot{
+		items:       make(map[string]*cacheEntry, len(s.items)),
+		folded:      make(map[string]string, len(s.folded)),
+		conflicts:   make(map[string][]string, len(s.conflicts)),
+		refreshedAt: s.refreshedAt,
+	}
+	for name, item := range s.items {
+		c.items[name] = item
+	}
+	for folded, name := range s.folded {
+		c.folded[folded] = name
+	}
+	for folded, names := range s.conflicts {
+		c.conflicts[folded] = names
+	}
+	return c
+}
+
+// add stores a repo. If a repo whose name only differs in case was added
+// before, case-insensitive lookups resolve to this one from now on, and the
+// conflict is recorded.
+func (s *snapshot) add(logger lager.Logger, repoName string, item *cacheEntry) {
+	folded := strings.ToLower(repoName)
+	if other, ok := s.folded[folded]; ok && other != repoName {
+		logger.Info("case-conflict", lager.Data{"repo": repoName, "org": item.org, "other-repo": other, "other-org": s.items[other].org})
+		s.conflicts[folded] = addName(addName(s.conflicts[folded], other), repoName)
+	}
+	s.folded[folded] = repoName
+	s.items[repoName] = item
+}
+
+// remove deletes a repo. If it was the one case-insensitive lookups resolved
+// to, they resolve to another repo whose name only differs in case, if there
+// is one.
+func (s *snapshot) remove(repoName string) {
+	delete(s.items, repoName)
+
+	folded := strings.ToLower(repoName)
+	var others []string
+	for _, name := range s.conflicts[folded] {
+		if name != repoName {
+			others = append(others, name)
+		}
+	}
+	if len(others) > 1 {
+		s.conflicts[folded] = others
+	} else {
+		delete(s.conflicts, folded)
+	}
+
+	if s.folded[folded] != repoName {
+		return
+	}
+	if len(others) == 0 {
+		delete(s.folded, folded)
+		return
+	}
+	s.folded[folded] = others[len(others)-1]
+}
+
+// oldestUpdate returns the earliest update time of the repos. It must not be
+// called before the snapshot is published, as it is only computed once.
+func (s *snapshot) oldestUpdate() time.Time {
+	s.oldestOnce.Do(func() {
+		for _, item := range s.items {
+			if !item.updatedAt.IsZero() && (s.oldest.IsZero() || item.updatedAt.Before(s.oldest)) {
+				s.oldest = item.updatedAt
+			}
+		}
+	})
+	return s.oldest
+}
+
+func (s *snapshot) lookup(repoName string) (Entry, bool) {
+	item, ok := s.items[repoName]
+	if !ok {
+		return Entry{}, false
+	}
+	return item.entry(), true
+}
+
+func (s *snapshot) names() []string {
+	names := make([]string, 0, len(s.items))
+	for name := range s.items {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// addName returns the sorted names with name inserted, unless it is already
+// there. names itself is not modified, as it may be shared with a published
+// snapshot.
+func addName(names []string, name string) []string {
+	i := sort.SearchStrings(names, name)
+	if i < len(names) && names[i] == name {
+		return names
+	}
+	result := make([]string, 0, len(names)+1)
+	result = append(result, names[:i]...)
+	result = append(result, name)
+	return append(result, names[i:]...)
+}
@@ -0,0 +1,48 @@
+package cache
+
+import "time"
+
+// Repo is a repo stored under its name.
+type Repo struct {
+	Name string
+	Entry
+}
+
+// Stats summarizes the contents of a Store.
+type Stats struct {
+	Repos int
+	// RefreshedAt is when the repos were last replaced as a whole, or the
+	// zero time if they never were.
+	RefreshedAt time.Time
+	// Age is how long ago RefreshedAt was.
+	Age time.Duration
+	// OldestEntryAge is the Age of the least recently updated repo, which
+	// keeps growing while refreshes fail.
+	OldestEntryAge time.Duration
+	// CaseConflicts lists the names of the repos that only differ in case,
+	// by their lower-case form.
+	CaseConflicts map[string][]string
+}
+
+// Store holds the repos found in the orgs. Implementations must be safe for
+// concurrent use, as the cache loader writes to a store while handlers read
+// from it. The methods that change the repos only return an error if the
+// change was not made.
+//
+//go:generate counterfeiter -o fakes/fake_store.go . Store
+type Store interface {
+	// Lookup returns the repo stored under repoName, or under a name that
+	// only differs in case if there is none, along with the name it was
+	// found under. Lookup and List set the Age of the entries.
+	Lookup(repoName string) (string, Entry, bool)
+	// List returns all repos, sorted by name.
+	List() []Repo
+	// ReplaceAll replaces all repos. Of the repos whose names only differ in
+	// case, the last one is the one Lookup resolves to.
+	ReplaceAll(repos []Repo) error
+	// Upsert adds or replaces a single repo.
+	Upsert(repoName string, entry Entry) error
+	// Delete removes a single repo, if it exists.
+	Delete(repoName string) error
+	Stats() Stats
+}
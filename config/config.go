@@ -1,9 +1,13 @@
 package config
 
 import (
+	"bytes"
 	"encoding/json"
 	"fmt"
 	"io/ioutil"
+	"net/url"
+	"strings"
+	"text/template"
 
 	"code.cloudfoundry.org/lager"
 )
@@ -15,16 +19,51 @@ const (
 	FATAL = "fatal"
 )
 
+// DefaultDocURLTemplate points documentation redirects at pkg.go.dev.
+const DefaultDocURLTemplate = "https://pkg.go.dev/{{.ImportPath}}{{if .Version}}@{{.Version}}{{end}}"
+
+// DefaultFetchConcurrency is how many requests for the repos of the orgs are
+// made at a time by default.
+const DefaultFetchConcurrency = 4
+
 type Config struct {
 	LogLevel             string
 	ImportPrefix         string
 	OrgList              []string
 	NoRedirectAgents     []string
-	Overrides            map[string]string
+	Overrides            map[string]Override
 	GithubAPIKey         string
+	GithubWebhookSecret  string
 	GithubStatusEndpoint string
 	GithubURL            string
 	IndexPath            string
+	ModuleCacheDir       string
+	DocURLTemplate       string
+	Tenants              []Tenant
+	LandingPages         bool
+	LandingPagePath      string
+	CacheFile            string
+	MaxAge               string
+	StaleWhileError      string
+	ExpiredRepos         string
+	StartupTimeout       string
+	RetryInterval        string
+	FetchConcurrency     int
+	MaxRemovedRepos      int
+	MaxRemovedPercent    float64
+	AdminToken           string
+}
+
+// DocURLVars are the variables available to the DocURLTemplate.
+type DocURLVars struct {
+	// ImportPath is the full import path of the requested package.
+	ImportPath string
+	// RepoName is the name of the repository the package belongs to.
+	RepoName string
+	// Subpath is the package directory inside the repository, if any.
+	Subpath string
+	// Version is the requested module version, if any.
+	Version string
 }
 
 func (c *Config) GetLogLevel() lager.LogLevel {
@@ -44,6 +83,123 @@ func (c *Config) GetLogLevel() lager.LogLevel {
 	return minLagerLogLevel
 }
 
+// GetDocURLTemplate returns the parsed DocURLTemplate, or the default one if
+// it is not set.
+func (c *Config) GetDocURLTemplate() *template.Template {
+	t, err := parseDocURLTemplate(c.DocURLTemplate)
+	if err != nil {
+		panic(err)
+	}
+	return t
+}
+
+// GetFetchConcurrency returns FetchConcurrency, or the default if it is not
+// set.
+func (c *Config) GetFetchConcurrency() int {
+	if c.FetchConcurrency == 0 {
+		return DefaultFetchConcurrency
+	}
+	return c.FetchConcurrency
+}
+
+func parseDocURLTemplate(text string) (*template.Template, error) {
+	if text == "" {
+		text = DefaultDocURLTemplate
+	}
+
+	t, err := template.New("doc-url").Option("missingkey=error").Parse(text)
+	if err != nil {
+		return nil, fmt.Errorf("invalid DocURLTemplate: %s", err)
+	}
+
+	var buf bytes.Buffer
+	err = t.Execute(&buf, DocURLVars{
+		ImportPath: "example.com/repo/subpath",
+		RepoName:   "repo",
+		Subpath:    "subpath",
+		Version:    "v1.0.0",
+	})
+	if err != nil {
+		return nil, fmt.Errorf("invalid DocURLTemplate: %s", err)
+	}
+
+	u, err := url.Parse(buf.String())
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return nil, fmt.Errorf("invalid DocURLTemplate: %q does not render an absolute http(s) URL", text)
+	}
+	return t, nil
+}
+
+// Validate checks the settings that would otherwise only fail when the first
+// request is served.
+func (c *Config) Validate() error {
+	if _, err := parseDocURLTemplate(c.DocURLTemplate); err != nil {
+		return err
+	}
+
+	if err := validateOverrides(c.Overrides); err != nil {
+		return err
+	}
+
+	if _, err := parseStalenessPolicy(c.MaxAge, c.StaleWhileError, c.ExpiredRepos); err != nil {
+		return err
+	}
+
+	if _, err := parseRetryPolicy(c.StartupTimeout, c.RetryInterval); err != nil {
+		return err
+	}
+
+	if c.FetchConcurrency < 0 {
+		return fmt.Errorf("invalid FetchConcurrency %d: must be positive", c.FetchConcurrency)
+	}
+
+	if c.MaxRemovedRepos < 0 {
+		return fmt.Errorf("invalid MaxRemovedRepos %d: must be positive", c.MaxRemovedRepos)
+	}
+	if c.MaxRemovedPercent < 0 || c.MaxRemovedPercent > 100 {
+		return fmt.Errorf("invalid MaxRemovedPercent %g: must be between 0 and 100", c.MaxRemovedPercent)
+	}
+
+	hosts := map[string]bool{}
+	cacheFiles := map[string]bool{}
+	for _, tenant := range c.Tenants {
+		host := strings.ToLower(tenant.Host)
+		if hosts[host] {
+			return fmt.Errorf("invalid tenant: duplicate Host %q", tenant.Host)
+		}
+		hosts[host] = true
+
+		if tenant.ImportPrefix == "" {
+			return fmt.Errorf("invalid tenant %q: ImportPrefix is required", tenant.Host)
+		}
+		if err := validateOverrides(tenant.Overrides); err != nil {
+			return fmt.Errorf("invalid tenant %q: %s", tenant.Host, err)
+		}
+
+		if tenant.CacheFile != "" {
+			if cacheFiles[tenant.CacheFile] {
+				return fmt.Errorf("invalid tenant %q: CacheFile %q is used by another tenant", tenant.Host, tenant.CacheFile)
+			}
+			cacheFiles[tenant.CacheFile] = true
+		}
+	}
+	return nil
+}
+
+// validRepoName reports whether name can be used as a vanity root below the
+// import prefix, e.g. "repo" or "tools/linter".
+func validRepoName(name string) bool {
+	if name == "" || strings.Contains(name, "@") {
+		return false
+	}
+	for _, segment := range strings.Split(name, "/") {
+		if segment == "" || segment == "." || segment == ".." {
+			return false
+		}
+	}
+	return true
+}
+
 func Parse(configPath string) (*Config, error) {
 	jsonBlob, err := ioutil.ReadFile(configPath)
 
@@ -57,5 +213,10 @@ func Parse(configPath string) (*Config, error) {
 		return nil, err
 	}
 
+	err = config.Validate()
+	if err != nil {
+		return nil, err
+	}
+
 	return &config, nil
 }
@@ -1,11 +1,15 @@
 package config_test
 
 import (
+	"bytes"
+	"encoding/json"
 	"fmt"
 	"io/ioutil"
 	"os"
+	"time"
 
 	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/ginkgo/extensions/table"
 	. "github.com/onsi/gomega"
 
 	"github.com/cloudfoundry/go-fetcher/config"
@@ -49,6 +53,314 @@ var _ = Describe("Load Configuration", func() {
 			Expect(c.NoRedirectAgents).To(Equal([]string{"test_agent"}))
 			Expect(c.IndexPath).To(Equal("some_relative/path"))
 		})
+
+		It("defaults the documentation site to pkg.go.dev", func() {
+			c, err := config.Parse(filePath)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(c.DocURLTemplate).To(BeEmpty())
+
+			var url bytes.Buffer
+			err = c.GetDocURLTemplate().Execute(&url, config.DocURLVars{ImportPath: "test/repo"})
+			Expect(err).NotTo(HaveOccurred())
+			Expect(url.String()).To(Equal("https://pkg.go.dev/test/repo"))
+		})
+
+		It("serves a single tenant for any host", func() {
+			c, err := config.Parse(filePath)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(c.GetTenants()).To(Equal([]config.Tenant{{
+				ImportPrefix: "test",
+				OrgList:      []string{"test_org"},
+				IndexPath:    "some_relative/path",
+			}}))
+		})
+	})
+
+	Context("when there are tenants", func() {
+		BeforeEach(func() {
+			jsonContent := []byte(`{
+				"IndexPath": "public/index.html",
+				"Tenants": [
+					{"Host": "code.example.com", "ImportPrefix": "code.example.com", "OrgList": ["org1"], "Overrides": {"tools": "https://example.com/tools"}},
+					{"Host": "go.example.org", "ImportPrefix": "go.example.org/x", "OrgList": ["org2"], "IndexPath": "public/org.html"}
+				]
+			}`)
+			Expect(ioutil.WriteFile(filePath, jsonContent, 0644)).To(Succeed())
+		})
+
+		It("returns the tenants with the default IndexPath", func() {
+			c, err := config.Parse(filePath)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(c.GetTenants()).To(Equal([]config.Tenant{
+				{
+					Host:         "code.example.com",
+					ImportPrefix: "code.example.com",
+					OrgList:      []string{"org1"},
+					Overrides:    map[string]config.Override{"tools": {Repo: "https://example.com/tools"}},
+					IndexPath:    "public/index.html",
+				},
+				{
+					Host:         "go.example.org",
+					ImportPrefix: "go.example.org/x",
+					OrgList:      []string{"org2"},
+					IndexPath:    "public/org.html",
+				},
+			}))
+		})
+	})
+
+	Context("when a tenant is invalid", func() {
+		DescribeTable("fails to parse the configuration",
+			func(tenants string, expectedError string) {
+				jsonContent := []byte(`{"Tenants": ` + tenants + `}`)
+				Expect(ioutil.WriteFile(filePath, jsonContent, 0644)).To(Succeed())
+
+				_, err := config.Parse(filePath)
+				Expect(err).To(MatchError(ContainSubstring(expectedError)))
+			},
+			Entry("with a duplicate Host", `[{"Host": "a.example.com", "ImportPrefix": "a"}, {"Host": "A.example.com", "ImportPrefix": "b"}]`, `duplicate Host "A.example.com"`),
+			Entry("with two default tenants", `[{"ImportPrefix": "a"}, {"ImportPrefix": "b"}]`, `duplicate Host ""`),
+			Entry("without an ImportPrefix", `[{"Host": "a.example.com"}]`, "ImportPrefix is required"),
+			Entry("with an invalid override", `[{"Host": "a.example.com", "ImportPrefix": "a", "Overrides": {"repo": {"Repo": "https://example.com/repo", "VCS": "cvs"}}}]`, `unsupported VCS "cvs"`),
+			Entry("with a shared CacheFile", `[{"Host": "a.example.com", "ImportPrefix": "a", "CacheFile": "cache.json"}, {"Host": "b.example.com", "ImportPrefix": "b", "CacheFile": "cache.json"}]`, `CacheFile "cache.json" is used by another tenant`),
+		)
+	})
+
+	Context("when the overrides contain nested vanity roots", func() {
+		BeforeEach(func() {
+			jsonContent := []byte(`{"Overrides": {"tools": "https://example.com/tools", "tools/linter": "https://example.com/linter"}}`)
+			Expect(ioutil.WriteFile(filePath, jsonContent, 0644)).To(Succeed())
+		})
+
+		It("returns the parsed configuration", func() {
+			c, err := config.Parse(filePath)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(c.Overrides).To(HaveKeyWithValue("tools/linter", config.Override{Repo: "https://example.com/linter"}))
+		})
+	})
+
+	Context("when the overrides use both the string and the structured form", func() {
+		BeforeEach(func() {
+			jsonContent := []byte(`{"Overrides": {
+				"stager": "https://github.com/cloudfoundry-incubator/stager",
+				"sdk": {"VCS": "git", "Repo": "https://github.com/org/sdk", "Subdir": "sdk/go", "Forge": "github", "DefaultBranch": "main"}
+			}}`)
+			Expect(ioutil.WriteFile(filePath, jsonContent, 0644)).To(Succeed())
+		})
+
+		It("returns the parsed configuration", func() {
+			c, err := config.Parse(filePath)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(c.Overrides).To(Equal(map[string]config.Override{
+				"stager": {Repo: "https://github.com/cloudfoundry-incubator/stager"},
+				"sdk": {
+					VCS:           "git",
+					Repo:          "https://github.com/org/sdk",
+					Subdir:        "sdk/go",
+					Forge:         "github",
+					DefaultBranch: "main",
+				},
+			}))
+		})
+	})
+
+	Context("when the overrides use each supported VCS", func() {
+		It("returns the parsed configuration", func() {
+			for _, vcs := range config.SupportedVCS {
+				jsonContent := []byte(`{"Overrides": {"repo": {"Repo": "https://example.com/repo", "VCS": "` + vcs + `"}}}`)
+				Expect(ioutil.WriteFile(filePath, jsonContent, 0644)).To(Succeed())
+
+				c, err := config.Parse(filePath)
+				Expect(err).NotTo(HaveOccurred())
+				Expect(c.Overrides["repo"].GetVCS()).To(Equal(vcs))
+			}
+		})
+	})
+
+	Context("when a structured override is invalid", func() {
+		DescribeTable("fails to parse the configuration",
+			func(override string, expectedError string) {
+				jsonContent := []byte(`{"Overrides": {"repo": ` + override + `}}`)
+				Expect(ioutil.WriteFile(filePath, jsonContent, 0644)).To(Succeed())
+
+				_, err := config.Parse(filePath)
+				Expect(err).To(MatchError(ContainSubstring(expectedError)))
+			},
+			Entry("with an absolute Subdir", `{"Repo": "https://example.com/repo", "Subdir": "/sdk/go"}`, "Subdir must be a relative path"),
+			Entry("with an unknown Forge", `{"Repo": "https://example.com/repo", "Forge": "sourceforge"}`, "unknown Forge"),
+			Entry("with the wrong type", `42`, "cannot unmarshal"),
+			Entry("with an unsupported VCS", `{"Repo": "https://example.com/repo", "VCS": "cvs"}`, `unsupported VCS "cvs"`),
+			Entry("with a Subdir for the mod VCS", `{"Repo": "https://proxy.example.com", "VCS": "mod", "Subdir": "sdk"}`, "Subdir cannot be used with the mod VCS"),
+		)
+	})
+
+	Context("when an override cannot be served", func() {
+		DescribeTable("skips it",
+			func(name string, override string, reason string) {
+				jsonContent, err := json.Marshal(map[string]map[string]json.RawMessage{"Overrides": {
+					name:    json.RawMessage(override),
+					"tools": json.RawMessage(`"https://example.com/tools"`),
+				}})
+				Expect(err).NotTo(HaveOccurred())
+				Expect(ioutil.WriteFile(filePath, jsonContent, 0644)).To(Succeed())
+
+				c, err := config.Parse(filePath)
+				Expect(err).NotTo(HaveOccurred())
+				Expect(c.GetTenants()[0].Overrides).To(Equal(map[string]config.Override{"tools": {Repo: "https://example.com/tools"}}))
+				Expect(c.SkippedOverrides()).To(Equal([]config.SkippedOverride{{Name: name, Reason: reason}}))
+			},
+			Entry("with an empty URL", "repo", `""`, "Repo is empty"),
+			Entry("without a Repo", "repo", `{"Subdir": "sdk/go"}`, "Repo is empty"),
+			Entry("with a leading slash", "/tools/linter", `"https://example.com/repo"`, "the name must be a relative path without empty segments or '@'"),
+			Entry("with a trailing slash", "tools/linter/", `"https://example.com/repo"`, "the name must be a relative path without empty segments or '@'"),
+			Entry("with an empty segment", "tools//linter", `"https://example.com/repo"`, "the name must be a relative path without empty segments or '@'"),
+			Entry("with a version", "tools@v1", `"https://example.com/repo"`, "the name must be a relative path without empty segments or '@'"),
+			Entry("below the path of the endpoints", "-/status", `"https://example.com/repo"`, `the name cannot start with "-", the path of the endpoints of go-fetcher`),
+		)
+
+		It("reports the host of the tenant", func() {
+			jsonContent := []byte(`{"Tenants": [
+				{"Host": "a.example.com", "ImportPrefix": "a", "Overrides": {"repo": ""}},
+				{"Host": "b.example.com", "ImportPrefix": "b", "Overrides": {"repo/": "https://example.com/repo"}}
+			]}`)
+			Expect(ioutil.WriteFile(filePath, jsonContent, 0644)).To(Succeed())
+
+			c, err := config.Parse(filePath)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(c.GetTenants()[0].Overrides).To(BeEmpty())
+			Expect(c.GetTenants()[1].Overrides).To(BeEmpty())
+			Expect(c.SkippedOverrides()).To(Equal([]config.SkippedOverride{
+				{Host: "a.example.com", Name: "repo", Reason: "Repo is empty"},
+				{Host: "b.example.com", Name: "repo/", Reason: "the name must be a relative path without empty segments or '@'"},
+			}))
+		})
+	})
+
+	Context("when the DocURLTemplate is invalid", func() {
+		DescribeTable("fails to parse the configuration",
+			func(docURLTemplate string) {
+				jsonContent, err := json.Marshal(map[string]string{"DocURLTemplate": docURLTemplate})
+				Expect(err).NotTo(HaveOccurred())
+				Expect(ioutil.WriteFile(filePath, jsonContent, 0644)).To(Succeed())
+
+				_, err = config.Parse(filePath)
+				Expect(err).To(MatchError(ContainSubstring("invalid DocURLTemplate")))
+			},
+			Entry("with a syntax error", "https://docs.example.com/{{.ImportPath"),
+			Entry("with an unknown variable", "https://docs.example.com/{{.Package}}"),
+			Entry("without a scheme", "docs.example.com/{{.ImportPath}}"),
+		)
+	})
+
+	Context("when there is a staleness policy", func() {
+		It("parses the durations and the action for expired repos", func() {
+			jsonContent := []byte(`{"MaxAge": "1h", "StaleWhileError": "24h", "ExpiredRepos": "drop"}`)
+			Expect(ioutil.WriteFile(filePath, jsonContent, 0644)).To(Succeed())
+
+			c, err := config.Parse(filePath)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(c.GetStalenessPolicy()).To(Equal(config.StalenessPolicy{
+				MaxAge:          time.Hour,
+				StaleWhileError: 24 * time.Hour,
+				DropExpired:     true,
+			}))
+		})
+
+		It("is disabled by default", func() {
+			c, err := config.Parse(filePath)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(c.GetStalenessPolicy()).To(Equal(config.StalenessPolicy{}))
+		})
+
+		DescribeTable("fails to parse an invalid policy",
+			func(policy map[string]string, expectedError string) {
+				jsonContent, err := json.Marshal(policy)
+				Expect(err).NotTo(HaveOccurred())
+				Expect(ioutil.WriteFile(filePath, jsonContent, 0644)).To(Succeed())
+
+				_, err = config.Parse(filePath)
+				Expect(err).To(MatchError(ContainSubstring(expectedError)))
+			},
+			Entry("with a MaxAge that is not a duration", map[string]string{"MaxAge": "1 day"}, "invalid MaxAge"),
+			Entry("with a negative MaxAge", map[string]string{"MaxAge": "-1h"}, "invalid MaxAge"),
+			Entry("with a StaleWhileError that is not a duration", map[string]string{"MaxAge": "1h", "StaleWhileError": "x"}, "invalid StaleWhileError"),
+			Entry("with a StaleWhileError but no MaxAge", map[string]string{"StaleWhileError": "1h"}, "requires MaxAge"),
+			Entry("with an unknown action for expired repos", map[string]string{"MaxAge": "1h", "ExpiredRepos": "delete"}, "invalid ExpiredRepos"),
+		)
+	})
+
+	Context("when there is a fetch concurrency", func() {
+		It("parses it", func() {
+			Expect(ioutil.WriteFile(filePath, []byte(`{"FetchConcurrency": 16}`), 0644)).To(Succeed())
+
+			c, err := config.Parse(filePath)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(c.GetFetchConcurrency()).To(Equal(16))
+		})
+
+		It("has a default", func() {
+			c, err := config.Parse(filePath)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(c.GetFetchConcurrency()).To(Equal(config.DefaultFetchConcurrency))
+		})
+
+		It("fails to parse a negative one", func() {
+			Expect(ioutil.WriteFile(filePath, []byte(`{"FetchConcurrency": -1}`), 0644)).To(Succeed())
+
+			_, err := config.Parse(filePath)
+			Expect(err).To(MatchError(ContainSubstring("invalid FetchConcurrency")))
+		})
+	})
+
+	DescribeTable("fails to parse an invalid deletion guard",
+		func(guard map[string]float64, expectedError string) {
+			jsonContent, err := json.Marshal(guard)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(ioutil.WriteFile(filePath, jsonContent, 0644)).To(Succeed())
+
+			_, err = config.Parse(filePath)
+			Expect(err).To(MatchError(ContainSubstring(expectedError)))
+		},
+		Entry("with a negative MaxRemovedRepos", map[string]float64{"MaxRemovedRepos": -1}, "invalid MaxRemovedRepos"),
+		Entry("with a negative MaxRemovedPercent", map[string]float64{"MaxRemovedPercent": -1}, "invalid MaxRemovedPercent"),
+		Entry("with a MaxRemovedPercent above 100", map[string]float64{"MaxRemovedPercent": 150}, "invalid MaxRemovedPercent"),
+	)
+
+	Context("when there is a retry policy", func() {
+		It("parses the durations", func() {
+			jsonContent := []byte(`{"StartupTimeout": "0s", "RetryInterval": "1m"}`)
+			Expect(ioutil.WriteFile(filePath, jsonContent, 0644)).To(Succeed())
+
+			c, err := config.Parse(filePath)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(c.GetRetryPolicy()).To(Equal(config.RetryPolicy{
+				StartupTimeout: 0,
+				RetryInterval:  time.Minute,
+			}))
+		})
+
+		It("has defaults", func() {
+			c, err := config.Parse(filePath)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(c.GetRetryPolicy()).To(Equal(config.RetryPolicy{
+				StartupTimeout: config.DefaultStartupTimeout,
+				RetryInterval:  config.DefaultRetryInterval,
+			}))
+		})
+
+		DescribeTable("fails to parse an invalid policy",
+			func(policy map[string]string, expectedError string) {
+				jsonContent, err := json.Marshal(policy)
+				Expect(err).NotTo(HaveOccurred())
+				Expect(ioutil.WriteFile(filePath, jsonContent, 0644)).To(Succeed())
+
+				_, err = config.Parse(filePath)
+				Expect(err).To(MatchError(ContainSubstring(expectedError)))
+			},
+			Entry("with a StartupTimeout that is not a duration", map[string]string{"StartupTimeout": "5 minutes"}, "invalid StartupTimeout"),
+			Entry("with a negative StartupTimeout", map[string]string{"StartupTimeout": "-1m"}, "invalid StartupTimeout"),
+			Entry("with a zero RetryInterval", map[string]string{"RetryInterval": "0s"}, "invalid RetryInterval"),
+		)
 	})
 
 })
@@ -0,0 +1,154 @@
+package config
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// Override points a vanity root at a repository that is not looked up in the
+// OrgList. In the config file it is either the repository URL as a plain
+// string, or an object such as
+//
+//	{"VCS": "git", "Repo": "https://github.com/org/repo", "Subdir": "sdk/go"}
+type Override struct {
+	// VCS is the version control system of the repository. Defaults to git.
+	VCS string `json:",omitempty"`
+	// Repo is the URL of the repository.
+	Repo string
+	// Subdir is the directory of the Go module inside the repository, if it
+	// is not at the root.
+	Subdir string `json:",omitempty"`
+	// Forge is the hosting service of the repository, used to link to the
+	// source. It is guessed from the Repo URL if empty.
+	Forge string `json:",omitempty"`
+	// DefaultBranch is the branch used in links to the source.
+	DefaultBranch string `json:",omitempty"`
+}
+
+// UnmarshalJSON accepts both the plain string and the object form.
+func (o *Override) UnmarshalJSON(data []byte) error {
+	var repo string
+	if err := json.Unmarshal(data, &repo); err == nil {
+		*o = Override{Repo: repo}
+		return nil
+	}
+
+	// the conversion drops the UnmarshalJSON method, so this does not recurse
+	type override Override
+	var obj override
+	if err := json.Unmarshal(data, &obj); err != nil {
+		return err
+	}
+	*o = Override(obj)
+	return nil
+}
+
+// GetVCS returns the version control system of the override.
+func (o Override) GetVCS() string {
+	if o.VCS == "" {
+		return "git"
+	}
+	return o.VCS
+}
+
+// SupportedVCS are the version control systems that the go command accepts
+// in a go-import meta tag. "mod" points at a module proxy rather than at a
+// repository.
+var SupportedVCS = []string{"bzr", "fossil", "git", "hg", "mod", "svn"}
+
+// ValidVCS reports whether vcs is one of the SupportedVCS.
+func ValidVCS(vcs string) bool {
+	for _, supported := range SupportedVCS {
+		if vcs == supported {
+			return true
+		}
+	}
+	return false
+}
+
+// validForge reports whether forge is empty or one of the forges go-fetcher
+// knows how to link to.
+func validForge(forge string) bool {
+	switch forge {
+	case "", "github", "github-enterprise", "gitlab", "gitea", "generic":
+		return true
+	}
+	return false
+}
+
+// SkippedOverride is an override that cannot be served, and is left out of
+// the tenants instead of failing to load the config.
+type SkippedOverride struct {
+	// Host is the Host of the tenant of the override.
+	Host   string
+	Name   string
+	Reason string
+}
+
+// skipReason returns why an override cannot be served, or "" if it can.
+// Configs of the plain string form always loaded with such overrides, which
+// were never matched, e.g. an empty URL or a name with a trailing slash.
+func skipReason(name string, override Override) string {
+	if !validRepoName(name) {
+		return "the name must be a relative path without empty segments or '@'"
+	}
+	if name == "-" || strings.HasPrefix(name, "-/") {
+		return `the name cannot start with "-", the path of the endpoints of go-fetcher`
+	}
+	if override.Repo == "" {
+		return "Repo is empty"
+	}
+	return ""
+}
+
+// servedOverrides returns the overrides without the skipped ones.
+func servedOverrides(overrides map[string]Override) map[string]Override {
+	if overrides == nil {
+		return nil
+	}
+	served := map[string]Override{}
+	for name, override := range overrides {
+		if skipReason(name, override) == "" {
+			served[name] = override
+		}
+	}
+	return served
+}
+
+// skippedOverrides returns the overrides of the tenant with host that are not
+// served, sorted by name.
+func skippedOverrides(host string, overrides map[string]Override) []SkippedOverride {
+	var skipped []SkippedOverride
+	for name, override := range overrides {
+		if reason := skipReason(name, override); reason != "" {
+			skipped = append(skipped, SkippedOverride{Host: host, Name: name, Reason: reason})
+		}
+	}
+	sort.Slice(skipped, func(i, j int) bool { return skipped[i].Name < skipped[j].Name })
+	return skipped
+}
+
+// validateOverrides checks the fields of a set of overrides. The overrides
+// that are skipped are not checked.
+func validateOverrides(overrides map[string]Override) error {
+	for name, override := range overrides {
+		if skipReason(name, override) != "" {
+			continue
+		}
+		if !ValidVCS(override.GetVCS()) {
+			return fmt.Errorf("invalid override %q: unsupported VCS %q, must be one of %s", name, override.VCS, strings.Join(SupportedVCS, ", "))
+		}
+		if override.GetVCS() == "mod" && override.Subdir != "" {
+			return fmt.Errorf("invalid override %q: Subdir cannot be used with the mod VCS", name)
+		}
+		if override.Subdir != "" && !validRepoName(override.Subdir) {
+			return fmt.Errorf("invalid override %q: Subdir must be a relative path", name)
+		}
+		if !validForge(override.Forge) {
+			return fmt.Errorf("invalid override %q: unknown Forge %q", name, override.Forge)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,56 @@
+package config
+
+import (
+	"fmt"
+	"time"
+)
+
+const (
+	// DefaultStartupTimeout is how long the initial refresh is retried by
+	// default before go-fetcher gives up.
+	DefaultStartupTimeout = 5 * time.Minute
+	// DefaultRetryInterval is the longest wait between retries of a failing
+	// refresh by default, which is the regular refresh interval.
+	DefaultRetryInterval = 10 * time.Minute
+)
+
+// RetryPolicy decides how failing refreshes of the repos of the orgs are
+// retried.
+type RetryPolicy struct {
+	// StartupTimeout is how long the initial refresh is retried before
+	// go-fetcher gives up. Zero gives up after the first failure.
+	StartupTimeout time.Duration
+	// RetryInterval is the longest wait between retries of a failing
+	// refresh, which back off exponentially up to it.
+	RetryInterval time.Duration
+}
+
+// GetRetryPolicy returns the policy given by StartupTimeout and
+// RetryInterval.
+func (c *Config) GetRetryPolicy() RetryPolicy {
+	policy, err := parseRetryPolicy(c.StartupTimeout, c.RetryInterval)
+	if err != nil {
+		panic(err)
+	}
+	return policy
+}
+
+func parseRetryPolicy(startupTimeout, retryInterval string) (RetryPolicy, error) {
+	policy := RetryPolicy{
+		StartupTimeout: DefaultStartupTimeout,
+		RetryInterval:  DefaultRetryInterval,
+	}
+	var err error
+
+	if startupTimeout != "" {
+		if policy.StartupTimeout, err = time.ParseDuration(startupTimeout); err != nil || policy.StartupTimeout < 0 {
+			return RetryPolicy{}, fmt.Errorf("invalid StartupTimeout %q: must be a positive duration such as 5m, or 0", startupTimeout)
+		}
+	}
+	if retryInterval != "" {
+		if policy.RetryInterval, err = time.ParseDuration(retryInterval); err != nil || policy.RetryInterval <= 0 {
+			return RetryPolicy{}, fmt.Errorf("invalid RetryInterval %q: must be a positive duration such as 10m", retryInterval)
+		}
+	}
+	return policy, nil
+}
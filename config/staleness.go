@@ -0,0 +1,64 @@
+package config
+
+import (
+	"fmt"
+	"time"
+)
+
+// What to do with repos that are older than MaxAge plus StaleWhileError.
+const (
+	ExpiredReposWarn = "warn"
+	ExpiredReposDrop = "drop"
+)
+
+// StalenessPolicy decides how repos found in the orgs are served once they
+// have not been updated for a while, e.g. because refreshes keep failing.
+type StalenessPolicy struct {
+	// MaxAge is how long a repo is fresh after it was last updated. Zero
+	// disables the policy.
+	MaxAge time.Duration
+	// StaleWhileError is how much longer a stale repo is served, with a
+	// warning, before it expires.
+	StaleWhileError time.Duration
+	// DropExpired makes expired repos unknown, instead of serving them with
+	// a warning.
+	DropExpired bool
+}
+
+// GetStalenessPolicy returns the policy given by MaxAge, StaleWhileError and
+// ExpiredRepos.
+func (c *Config) GetStalenessPolicy() StalenessPolicy {
+	policy, err := parseStalenessPolicy(c.MaxAge, c.StaleWhileError, c.ExpiredRepos)
+	if err != nil {
+		panic(err)
+	}
+	return policy
+}
+
+func parseStalenessPolicy(maxAge, staleWhileError, expiredRepos string) (StalenessPolicy, error) {
+	var policy StalenessPolicy
+	var err error
+
+	if maxAge != "" {
+		if policy.MaxAge, err = time.ParseDuration(maxAge); err != nil || policy.MaxAge < 0 {
+			return StalenessPolicy{}, fmt.Errorf("invalid MaxAge %q: must be a positive duration such as 24h", maxAge)
+		}
+	}
+	if staleWhileError != "" {
+		if policy.StaleWhileError, err = time.ParseDuration(staleWhileError); err != nil || policy.StaleWhileError < 0 {
+			return StalenessPolicy{}, fmt.Errorf("invalid StaleWhileError %q: must be a positive duration such as 24h", staleWhileError)
+		}
+		if policy.MaxAge == 0 {
+			return StalenessPolicy{}, fmt.Errorf("invalid StaleWhileError %q: requires MaxAge", staleWhileError)
+		}
+	}
+
+	switch expiredRepos {
+	case "", ExpiredReposWarn:
+	case ExpiredReposDrop:
+		policy.DropExpired = true
+	default:
+		return StalenessPolicy{}, fmt.Errorf("invalid ExpiredRepos %q: must be %q or %q", expiredRepos, ExpiredReposWarn, ExpiredReposDrop)
+	}
+	return policy, nil
+}
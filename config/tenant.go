@@ -0,0 +1,57 @@
+package config
+
+// Tenant is a vanity host served by a single go-fetcher, with its own import
+// prefix, GitHub orgs, overrides and index page.
+type Tenant struct {
+	// Host is matched case-insensitively against the Host header of a
+	// request, ignoring the port. The tenant with an empty Host serves
+	// requests for any host that no other tenant matches.
+	Host         string
+	ImportPrefix string
+	OrgList      []string
+	Overrides    map[string]Override
+	IndexPath    string
+	// CacheFile is where the repos found in the orgs are saved, so that they
+	// can be served right away after a restart.
+	CacheFile string
+}
+
+// GetTenants returns the configured tenants, or a single tenant for any host
+// built from the top-level settings if there are none. Tenants without an
+// IndexPath use the top-level one. The overrides that cannot be served are
+// left out, see SkippedOverrides.
+func (c *Config) GetTenants() []Tenant {
+	if len(c.Tenants) == 0 {
+		return []Tenant{{
+			ImportPrefix: c.ImportPrefix,
+			OrgList:      c.OrgList,
+			Overrides:    servedOverrides(c.Overrides),
+			IndexPath:    c.IndexPath,
+			CacheFile:    c.CacheFile,
+		}}
+	}
+
+	tenants := make([]Tenant, len(c.Tenants))
+	for i, tenant := range c.Tenants {
+		if tenant.IndexPath == "" {
+			tenant.IndexPath = c.IndexPath
+		}
+		tenant.Overrides = servedOverrides(tenant.Overrides)
+		tenants[i] = tenant
+	}
+	return tenants
+}
+
+// SkippedOverrides returns the overrides that GetTenants leaves out, so that
+// they can be logged.
+func (c *Config) SkippedOverrides() []SkippedOverride {
+	if len(c.Tenants) == 0 {
+		return skippedOverrides("", c.Overrides)
+	}
+
+	var skipped []SkippedOverride
+	for _, tenant := range c.Tenants {
+		skipped = append(skipped, skippedOverrides(tenant.Host, tenant.Overrides)...)
+	}
+	return skipped
+}
@@ -0,0 +1,93 @@
+package handlers
+
+import (
+	"crypto/subtle"
+	"encoding/json"
+	"net/http"
+	"strings"
+
+	"code.cloudfoundry.org/lager"
+	"github.com/cloudfoundry/go-fetcher/cache"
+)
+
+// maxAdminPayload is the largest request body the admin endpoints read.
+const maxAdminPayload = 1 << 20
+
+type acceptRefreshRequest struct {
+	ID string `json:"id"`
+}
+
+type acceptRefreshResult struct {
+	ID      string `json:"id"`
+	Removed int    `json:"removed"`
+	Added   int    `json:"added"`
+}
+
+// AcceptRefresh serves POST /-/admin/accept-refresh, which stores the
+// refresh of the tenant that was blocked because it would have removed too
+// many repos. The request body names the reviewed refresh by its id, as
+// reported by GET /-/status, so that different changes are not accepted by
+// accident.
+// Requests must carry the AdminToken as a bearer token, and the endpoint is
+// disabled if there is none.
+func (h *Handler) AcceptRefresh(writer http.ResponseWriter, request *http.Request) {
+	logger := h.logger.Session("handler.accept-refresh", lager.Data{"host": request.Host})
+
+	if h.config.AdminToken == "" {
+		writeJSON(logger, writer, http.StatusNotFound, apiError{Error: "admin endpoints are not configured"})
+		return
+	}
+	if request.Method != http.MethodPost {
+		writer.Header().Set("Allow", http.MethodPost)
+		writeJSON(logger, writer, http.StatusMethodNotAllowed, apiError{Error: "method not allowed"})
+		return
+	}
+	if !validToken(h.config.AdminToken, request.Header.Get("Authorization")) {
+		logger.Info("invalid-token")
+		writeJSON(logger, writer, http.StatusUnauthorized, apiError{Error: "invalid token"})
+		return
+	}
+
+	tenant, ok := h.tenant(request.Host)
+	if !ok || tenant.loader == nil {
+		writeJSON(logger, writer, http.StatusNotFound, apiError{Error: "unknown host"})
+		return
+	}
+
+	var body acceptRefreshRequest
+	if err := json.NewDecoder(http.MaxBytesReader(writer, request.Body, maxAdminPayload)).Decode(&body); err != nil || body.ID == "" {
+		writeJSON(logger, writer, http.StatusBadRequest, apiError{Error: "the id of the blocked refresh is required"})
+		return
+	}
+
+	accepted, err := tenant.loader.AcceptBlocked(body.ID)
+	switch err {
+	case nil:
+	case cache.ErrNoBlockedRefresh:
+		writeJSON(logger, writer, http.StatusNotFound, apiError{Error: err.Error()})
+		return
+	case cache.ErrBlockedRefreshChanged:
+		writeJSON(logger, writer, http.StatusConflict, apiError{Error: err.Error()})
+		return
+	default:
+		logger.Error("failed-accepting-refresh", err, lager.Data{"id": body.ID})
+		writeJSON(logger, writer, http.StatusInternalServerError, apiError{Error: err.Error()})
+		return
+	}
+
+	logger.Info("accepted-refresh", lager.Data{"id": accepted.ID, "removed": len(accepted.Removed), "added": len(accepted.Added)})
+	writeJSON(logger, writer, http.StatusOK, acceptRefreshResult{
+		ID:      accepted.ID,
+		Removed: len(accepted.Removed),
+		Added:   len(accepted.Added),
+	})
+}
+
+// validToken checks an Authorization header for the bearer token.
+func validToken(token, authorization string) bool {
+	if !strings.HasPrefix(authorization, "Bearer ") {
+		return false
+	}
+	given := strings.TrimPrefix(authorization, "Bearer ")
+	return subtle.ConstantTimeCompare([]byte(given), []byte(token)) == 1
+}
@@ -0,0 +1,114 @@
+package handlers_test
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+
+	"code.cloudfoundry.org/lager/lagertest"
+	"github.com/cloudfoundry/go-fetcher/cache"
+	"github.com/cloudfoundry/go-fetcher/cache/fakes"
+	"github.com/cloudfoundry/go-fetcher/config"
+	"github.com/cloudfoundry/go-fetcher/handlers"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Accepting a blocked refresh", func() {
+	const token = "admin-token"
+
+	var (
+		cfg     config.Config
+		loader  *fakeLoader
+		handler *handlers.Handler
+	)
+
+	accept := func(method, authorization, body string) *httptest.ResponseRecorder {
+		req, err := http.NewRequest(method, "/-/admin/accept-refresh", strings.NewReader(body))
+		Expect(err).NotTo(HaveOccurred())
+		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set("Authorization", authorization)
+		res := httptest.NewRecorder()
+		handler.AcceptRefresh(res, req)
+		return res
+	}
+
+	BeforeEach(func() {
+		cfg = config.Config{ImportPrefix: "import-prefix", AdminToken: token}
+		loader = &fakeLoader{accepted: cache.BlockedRefresh{
+			ID:      "0123456789ab",
+			Removed: []string{"repo2", "repo3"},
+			Added:   []string{"repo4"},
+		}}
+	})
+
+	JustBeforeEach(func() {
+		handler = handlers.NewHandler(lagertest.NewTestLogger("test"), cfg, map[string]cache.Store{"": &fakes.FakeStore{}}, map[string]handlers.Loader{"": loader}, nil)
+	})
+
+	It("accepts the blocked refresh with the given id", func() {
+		res := accept("POST", "Bearer "+token, `{"id": "0123456789ab"}`)
+
+		Expect(res.Code).To(Equal(http.StatusOK))
+		Expect(res.Body.String()).To(MatchJSON(`{"id": "0123456789ab", "removed": 2, "added": 1}`))
+		Expect(loader.acceptedID).To(Equal("0123456789ab"))
+	})
+
+	It("returns a 409 Conflict if the blocked refresh has changed", func() {
+		loader.acceptErr = cache.ErrBlockedRefreshChanged
+
+		res := accept("POST", "Bearer "+token, `{"id": "0123456789ab"}`)
+		Expect(res.Code).To(Equal(http.StatusConflict))
+		Expect(res.Body.String()).To(MatchJSON(`{"error": "the blocked refresh has changed"}`))
+	})
+
+	It("returns a 404 Not Found if no refresh is blocked", func() {
+		loader.acceptErr = cache.ErrNoBlockedRefresh
+
+		res := accept("POST", "Bearer "+token, `{"id": "0123456789ab"}`)
+		Expect(res.Code).To(Equal(http.StatusNotFound))
+	})
+
+	It("returns a 500 if the refresh cannot be stored", func() {
+		loader.acceptErr = errors.New("disk full")
+
+		res := accept("POST", "Bearer "+token, `{"id": "0123456789ab"}`)
+		Expect(res.Code).To(Equal(http.StatusInternalServerError))
+		Expect(res.Body.String()).To(MatchJSON(`{"error": "disk full"}`))
+	})
+
+	It("requires the id", func() {
+		res := accept("POST", "Bearer "+token, `{}`)
+		Expect(res.Code).To(Equal(http.StatusBadRequest))
+		Expect(loader.acceptedID).To(BeEmpty())
+	})
+
+	It("rejects requests without the admin token", func() {
+		res := accept("POST", "Bearer wrong-token", `{"id": "0123456789ab"}`)
+		Expect(res.Code).To(Equal(http.StatusUnauthorized))
+		Expect(res.Body.String()).To(MatchJSON(`{"error": "invalid token"}`))
+
+		res = accept("POST", "", `{"id": "0123456789ab"}`)
+		Expect(res.Code).To(Equal(http.StatusUnauthorized))
+		Expect(loader.acceptedID).To(BeEmpty())
+	})
+
+	It("only allows POST", func() {
+		res := accept("GET", "Bearer "+token, "")
+		Expect(res.Code).To(Equal(http.StatusMethodNotAllowed))
+		Expect(res.Header().Get("Allow")).To(Equal("POST"))
+	})
+
+	Context("when there is no admin token", func() {
+		BeforeEach(func() {
+			cfg.AdminToken = ""
+		})
+
+		It("is disabled", func() {
+			res := accept("POST", "Bearer ", `{"id": "0123456789ab"}`)
+			Expect(res.Code).To(Equal(http.StatusNotFound))
+			Expect(loader.acceptedID).To(BeEmpty())
+		})
+	})
+})
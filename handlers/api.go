@@ -0,0 +1,165 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"code.cloudfoundry.org/lager"
+)
+
+const (
+	apiReposPath = "/-/api/v1/repos"
+
+	defaultPerPage = 100
+	maxPerPage     = 1000
+)
+
+// apiRepo is how a repo is resolved, as served by the JSON API.
+type apiRepo struct {
+	Name       string `json:"name"`
+	ImportPath string `json:"import_path"`
+	Location   string `json:"location"`
+	VCS        string `json:"vcs"`
+	Subdir     string `json:"subdir,omitempty"`
+	// Source is "override" for repos from the config and "org" for repos
+	// found in one of the orgs, which is then given by Org.
+	Source     string     `json:"source"`
+	Org        string     `json:"org,omitempty"`
+	UpdatedAt  *time.Time `json:"updated_at,omitempty"`
+	AgeSeconds *float64   `json:"age_seconds,omitempty"`
+	// Freshness is "fresh", "stale" or "expired" under the staleness policy.
+	Freshness string `json:"freshness"`
+	// CaseConflicts lists the repos found in the orgs whose names only
+	// differ in case, if there are several.
+	CaseConflicts []string `json:"case_conflicts,omitempty"`
+}
+
+type apiRepoList struct {
+	Repos   []apiRepo `json:"repos"`
+	Page    int       `json:"page"`
+	PerPage int       `json:"per_page"`
+	Total   int       `json:"total"`
+}
+
+type apiError struct {
+	Error string `json:"error"`
+}
+
+// ListRepos serves GET /-/api/v1/repos. The results can be filtered by the
+// source, org, vcs, freshness and name prefix query parameters, and are paged
+// by page and per_page.
+func (h *Handler) ListRepos(writer http.ResponseWriter, request *http.Request) {
+	logger := h.logger.Session("handler.listrepos", lager.Data{"host": request.Host, "query": request.URL.RawQuery})
+
+	tenant, ok := h.tenant(request.Host)
+	if !ok {
+		writeJSON(logger, writer, http.StatusNotFound, apiError{Error: "unknown host"})
+		return
+	}
+
+	query := request.URL.Query()
+	page, err := queryInt(query.Get("page"), 1)
+	if err != nil || page < 1 {
+		writeJSON(logger, writer, http.StatusBadRequest, apiError{Error: "page must be a positive integer"})
+		return
+	}
+	perPage, err := queryInt(query.Get("per_page"), defaultPerPage)
+	if err != nil || perPage < 1 || perPage > maxPerPage {
+		writeJSON(logger, writer, http.StatusBadRequest, apiError{Error: "per_page must be between 1 and " + strconv.Itoa(maxPerPage)})
+		return
+	}
+
+	var repos []apiRepo
+	for _, p := range tenant.packages() {
+		repo := newAPIRepo(tenant, p)
+		if filter := query.Get("source"); filter != "" && repo.Source != filter {
+			continue
+		}
+		if filter := query.Get("org"); filter != "" && repo.Org != filter {
+			continue
+		}
+		if filter := query.Get("vcs"); filter != "" && repo.VCS != filter {
+			continue
+		}
+		if filter := query.Get("freshness"); filter != "" && repo.Freshness != filter {
+			continue
+		}
+		if filter := query.Get("prefix"); filter != "" && !strings.HasPrefix(repo.Name, filter) {
+			continue
+		}
+		repos = append(repos, repo)
+	}
+
+	list := apiRepoList{Repos: []apiRepo{}, Page: page, PerPage: perPage, Total: len(repos)}
+	if start := (page - 1) * perPage; start < len(repos) {
+		end := start + perPage
+		if end > len(repos) {
+			end = len(repos)
+		}
+		list.Repos = repos[start:end]
+	}
+
+	writeJSON(logger, writer, http.StatusOK, list)
+}
+
+// GetRepo serves GET /-/api/v1/repos/{name}, where name may span several
+// path segments and is matched ignoring case if there is no exact match.
+func (h *Handler) GetRepo(writer http.ResponseWriter, request *http.Request) {
+	name := strings.Trim(strings.TrimPrefix(request.URL.Path, apiReposPath), "/")
+	logger := h.logger.Session("handler.getrepo", lager.Data{"host": request.Host, "repo-name": name})
+
+	tenant, ok := h.tenant(request.Host)
+	if !ok {
+		writeJSON(logger, writer, http.StatusNotFound, apiError{Error: "unknown host"})
+		return
+	}
+
+	if repoName, entry, ok := tenant.lookup(logger, name); ok {
+		_, override := tenant.config.Overrides[repoName]
+		writeJSON(logger, writer, http.StatusOK, newAPIRepo(tenant, pkg{Name: repoName, Entry: entry, Override: override}))
+		return
+	}
+	writeJSON(logger, writer, http.StatusNotFound, apiError{Error: "unknown repo " + name})
+}
+
+func newAPIRepo(tenant *tenant, p pkg) apiRepo {
+	repo := apiRepo{
+		Name:       p.Name,
+		ImportPath: tenant.config.ImportPrefix + "/" + p.Name,
+		Location:   p.Entry.Location,
+		VCS:        p.Entry.GetVCS(),
+		Subdir:     p.Entry.Subdir,
+		Source:     "override",
+		Freshness:  tenant.freshness(p.Entry).String(),
+	}
+	if !p.Override {
+		updatedAt := p.Entry.UpdatedAt.UTC()
+		ageSeconds := p.Entry.Age.Seconds()
+		repo.Source = "org"
+		repo.Org = p.Entry.Org
+		repo.UpdatedAt = &updatedAt
+		repo.AgeSeconds = &ageSeconds
+	}
+	if tenant.store != nil {
+		repo.CaseConflicts = tenant.store.Stats().CaseConflicts[strings.ToLower(p.Name)]
+	}
+	return repo
+}
+
+func queryInt(value string, fallback int) (int, error) {
+	if value == "" {
+		return fallback, nil
+	}
+	return strconv.Atoi(value)
+}
+
+func writeJSON(logger lager.Logger, writer http.ResponseWriter, status int, body interface{}) {
+	writer.Header().Set("Content-Type", "application/json")
+	writer.WriteHeader(status)
+	if err := json.NewEncoder(writer).Encode(body); err != nil {
+		logger.Error("failed-writing-json", err)
+	}
+}
@@ -0,0 +1,193 @@
+package handlers_test
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"time"
+
+	"code.cloudfoundry.org/clock/fakeclock"
+	"code.cloudfoundry.org/lager/lagertest"
+	"github.com/cloudfoundry/go-fetcher/cache"
+	"github.com/cloudfoundry/go-fetcher/config"
+	"github.com/cloudfoundry/go-fetcher/handlers"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/ginkgo/extensions/table"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("JSON API", func() {
+	var (
+		handler       *handlers.Handler
+		locationCache *cache.LocationCache
+		now           time.Time
+	)
+
+	type repo struct {
+		Name       string     `json:"name"`
+		ImportPath string     `json:"import_path"`
+		Location   string     `json:"location"`
+		VCS        string     `json:"vcs"`
+		Subdir     string     `json:"subdir"`
+		Source     string     `json:"source"`
+		Org        string     `json:"org"`
+		UpdatedAt  *time.Time `json:"updated_at"`
+	}
+
+	type repoList struct {
+		Repos   []repo `json:"repos"`
+		Page    int    `json:"page"`
+		PerPage int    `json:"per_page"`
+		Total   int    `json:"total"`
+	}
+
+	get := func(path string, serve func(http.ResponseWriter, *http.Request)) *httptest.ResponseRecorder {
+		req, err := http.NewRequest("GET", path, nil)
+		Expect(err).NotTo(HaveOccurred())
+		res := httptest.NewRecorder()
+		serve(res, req)
+		return res
+	}
+
+	list := func(query string) repoList {
+		res := get("/-/api/v1/repos"+query, handler.ListRepos)
+		Expect(res.Code).To(Equal(http.StatusOK))
+		Expect(res.Header().Get("Content-Type")).To(Equal("application/json"))
+
+		var repos repoList
+		Expect(json.Unmarshal(res.Body.Bytes(), &repos)).To(Succeed())
+		return repos
+	}
+
+	names := func(repos []repo) []string {
+		names := []string{}
+		for _, r := range repos {
+			names = append(names, r.Name)
+		}
+		return names
+	}
+
+	BeforeEach(func() {
+		now = time.Date(2019, 6, 1, 12, 0, 0, 0, time.UTC)
+		cfg := config.Config{
+			ImportPrefix: "import-prefix",
+			Overrides: map[string]config.Override{
+				"tools/linter": {Repo: "https://hg.example.com/linter", VCS: "hg"},
+				"repo2":        {Repo: "https://example.com/repo2", Subdir: "sdk/go"},
+			},
+		}
+
+		locationCache = cache.NewLocationCache(lagertest.NewTestLogger("cache"), fakeclock.NewFakeClock(now))
+		for i, org := range []string{"org1", "org1", "org2"} {
+			name := fmt.Sprintf("repo%d", i+1)
+			locationCache.Upsert(name, cache.Entry{Location: "https://github.com/" + org + "/" + name, Org: org})
+		}
+
+		handler = handlers.NewHandler(lagertest.NewTestLogger("test"), cfg, map[string]cache.Store{"": locationCache}, nil, nil)
+	})
+
+	Describe("GET /-/api/v1/repos", func() {
+		It("lists the repos from the cache and the overrides", func() {
+			repos := list("")
+			Expect(repos.Total).To(Equal(4))
+			Expect(repos.Page).To(Equal(1))
+			Expect(repos.PerPage).To(Equal(100))
+			Expect(repos.Repos).To(Equal([]repo{
+				{Name: "repo1", ImportPath: "import-prefix/repo1", Location: "https://github.com/org1/repo1", VCS: "git", Source: "org", Org: "org1", UpdatedAt: &now},
+				{Name: "repo2", ImportPath: "import-prefix/repo2", Location: "https://example.com/repo2", VCS: "git", Subdir: "sdk/go", Source: "override"},
+				{Name: "repo3", ImportPath: "import-prefix/repo3", Location: "https://github.com/org2/repo3", VCS: "git", Source: "org", Org: "org2", UpdatedAt: &now},
+				{Name: "tools/linter", ImportPath: "import-prefix/tools/linter", Location: "https://hg.example.com/linter", VCS: "hg", Source: "override"},
+			}))
+		})
+
+		DescribeTable("filters the repos",
+			func(query string, expectedNames ...string) {
+				repos := list(query)
+				Expect(names(repos.Repos)).To(Equal(expectedNames))
+				Expect(repos.Total).To(Equal(len(expectedNames)))
+			},
+			Entry("by source", "?source=override", "repo2", "tools/linter"),
+			Entry("by org", "?org=org1", "repo1"),
+			Entry("by VCS", "?vcs=hg", "tools/linter"),
+			Entry("by name prefix", "?prefix=tools/", "tools/linter"),
+			Entry("by several filters", "?source=org&prefix=repo", "repo1", "repo3"),
+		)
+
+		It("pages the repos", func() {
+			repos := list("?per_page=3&page=2")
+			Expect(names(repos.Repos)).To(Equal([]string{"tools/linter"}))
+			Expect(repos.Total).To(Equal(4))
+
+			repos = list("?per_page=3&page=3")
+			Expect(repos.Repos).To(BeEmpty())
+		})
+
+		DescribeTable("rejects invalid paging",
+			func(query string) {
+				res := get("/-/api/v1/repos"+query, handler.ListRepos)
+				Expect(res.Code).To(Equal(http.StatusBadRequest))
+				Expect(res.Body.String()).To(ContainSubstring(`"error"`))
+			},
+			Entry("a page that is not a number", "?page=first"),
+			Entry("a page of zero", "?page=0"),
+			Entry("too many repos per page", "?per_page=1001"),
+		)
+	})
+
+	Describe("GET /-/api/v1/repos/{name}", func() {
+		It("returns a repo from the cache", func() {
+			res := get("/-/api/v1/repos/repo1", handler.GetRepo)
+			Expect(res.Code).To(Equal(http.StatusOK))
+			Expect(res.Body.String()).To(MatchJSON(`{
+				"name": "repo1",
+				"import_path": "import-prefix/repo1",
+				"location": "https://github.com/org1/repo1",
+				"vcs": "git",
+				"source": "org",
+				"org": "org1",
+				"updated_at": "2019-06-01T12:00:00Z",
+				"age_seconds": 0,
+				"freshness": "fresh"
+			}`))
+		})
+
+		It("returns an override with a nested name", func() {
+			res := get("/-/api/v1/repos/tools/linter", handler.GetRepo)
+			Expect(res.Code).To(Equal(http.StatusOK))
+			Expect(res.Body.String()).To(MatchJSON(`{
+				"name": "tools/linter",
+				"import_path": "import-prefix/tools/linter",
+				"location": "https://hg.example.com/linter",
+				"vcs": "hg",
+				"source": "override",
+				"freshness": "fresh"
+			}`))
+		})
+
+		It("prefers an override over the cache", func() {
+			res := get("/-/api/v1/repos/repo2", handler.GetRepo)
+			Expect(res.Body.String()).To(ContainSubstring(`"source":"override"`))
+		})
+
+		It("resolves names ignoring case", func() {
+			res := get("/-/api/v1/repos/REPO1", handler.GetRepo)
+			Expect(res.Code).To(Equal(http.StatusOK))
+			Expect(res.Body.String()).To(ContainSubstring(`"name":"repo1"`))
+		})
+
+		It("reports repos whose names only differ in case", func() {
+			locationCache.Upsert("Repo1", cache.Entry{Location: "https://github.com/org2/Repo1", Org: "org2"})
+
+			res := get("/-/api/v1/repos/repo1", handler.GetRepo)
+			Expect(res.Code).To(Equal(http.StatusOK))
+			Expect(res.Body.String()).To(ContainSubstring(`"case_conflicts":["Repo1","repo1"]`))
+		})
+
+		It("returns a 404 Not Found for unknown repos", func() {
+			res := get("/-/api/v1/repos/repo9", handler.GetRepo)
+			Expect(res.Code).To(Equal(http.StatusNotFound))
+			Expect(res.Body.String()).To(MatchJSON(`{"error": "unknown repo repo9"}`))
+		})
+	})
+})
@@ -1,36 +1,70 @@
 package handlers
 
 import (
+	"bytes"
 	"fmt"
+	htmltemplate "html/template"
 	"net/http"
+	"path"
 	"strings"
+	"text/template"
 	"time"
-	"path/filepath"
 
+	"code.cloudfoundry.org/lager"
 	"github.com/cloudfoundry/go-fetcher/cache"
 	"github.com/cloudfoundry/go-fetcher/config"
-	"code.cloudfoundry.org/lager"
+	"github.com/cloudfoundry/go-fetcher/proxy"
 )
 
 type Handler struct {
-	config        config.Config
-	logger        lager.Logger
-	locationCache *cache.LocationCache
+	config  config.Config
+	logger  lager.Logger
+	tenants map[string]*tenant
+	proxy   *proxy.Proxy
+	docURL  *template.Template
+	landing *htmltemplate.Template
 }
 
-func NewHandler(logger lager.Logger, config config.Config, locationCache *cache.LocationCache) *Handler {
+// Loader is the part of a cache.CacheLoader that is reported on and
+// administered.
+type Loader interface {
+	Status() cache.LoaderStatus
+	AcceptBlocked(id string) (cache.BlockedRefresh, error)
+	Diffs() []cache.RefreshDiff
+	ApplyRepositoryEvent(logger lager.Logger, event cache.RepositoryEvent) (bool, error)
+}
+
+// NewHandler returns a Handler serving the repos from the overrides and the
+// store of each tenant, with stores and the loaders keeping them up to date
+// keyed by the tenant Host. The module proxy endpoints are disabled if
+// moduleProxy is nil.
+func NewHandler(logger lager.Logger, config config.Config, stores map[string]cache.Store, loaders map[string]Loader, moduleProxy *proxy.Proxy) *Handler {
 	return &Handler{
-		config:        config,
-		logger:        logger,
-		locationCache: locationCache,
+		config:  config,
+		logger:  logger,
+		tenants: newTenants(logger, config, stores, loaders),
+		proxy:   moduleProxy,
+		docURL:  config.GetDocURLTemplate(),
+		landing: loadTemplate(logger, config.LandingPagePath, landingTemplate),
 	}
 }
 
 func (h *Handler) GetMeta(writer http.ResponseWriter, request *http.Request) {
 	start := time.Now()
 
-	repoName := strings.Split(request.URL.Path, "/")[1]
-	logger := h.logger.Session("handler.getmeta", lager.Data{"repo-name": repoName})
+	if isModuleRequest(request.URL.Path) {
+		h.GetModule(writer, request)
+		return
+	}
+
+	logger := h.logger.Session("handler.getmeta", lager.Data{"host": request.Host, "path": request.URL.Path})
+
+	tenant, ok := h.tenant(request.Host)
+	if !ok {
+		logger.Info("unknown-host")
+		http.Error(writer, "", http.StatusNotFound)
+		return
+	}
 
 	writer.Header().Set("Content-Type", "text/html; charset=utf-8")
 
@@ -38,67 +72,122 @@ func (h *Handler) GetMeta(writer http.ResponseWriter, request *http.Request) {
 	for _, path := range []string{"/", "/index.htm", "/index.html"} {
 		if request.URL.Path == path {
 			logger.Debug("index-page", lager.Data{"location": request.URL.Path})
-			indexHtmlPath, err := filepath.Abs(h.config.IndexPath)
-			
-			if err != nil {
-		      logger.Error("index-page", fmt.Errorf("could not get absolute path of IndexPath"))		
-			}
-			http.ServeFile(writer, request, indexHtmlPath)
-		    return
+			h.serveIndex(logger, writer, tenant)
+			return
 		}
 	}
 
-	location := ""
-	for k := range h.config.Overrides {
-		if k == repoName {
-			location = h.config.Overrides[k]
-			logger.Debug("override", lager.Data{"location": location})
-		}
+	match, ok := tenant.route(logger, request.URL.Path)
+	if !ok {
+		logger.Error("not-found", fmt.Errorf("repo not in cache or override list"))
+		h.serveNotFound(logger, writer, request, tenant)
+		return
 	}
 
-	if location == "" {
-		if loc, ok := h.locationCache.Lookup(repoName); ok {
-			location = loc
-			logger.Debug("cache-hit", lager.Data{"location": location})
-		}
-	}
+	logger = logger.WithData(lager.Data{"repo-name": match.RepoName})
 
-	if location == "" {
-		logger.Error("not-found", fmt.Errorf("repo not in cache or override list"))
-		http.Error(writer, "", http.StatusNotFound)
+	// the case of the import path matters to go, so requests that only match
+	// a repo when ignoring case are sent to the canonical import path
+	if match.RepoName != match.Requested {
+		target := canonicalPath(match, request.URL.RawQuery)
+		logger.Info("redirect.canonical", lager.Data{"requested": match.Requested, "location": target})
+		http.Redirect(writer, request, target, http.StatusMovedPermanently)
 		return
 	}
 
+	entry, location := match.Entry, match.Entry.Location
+	tenant.warnIfStale(logger, writer, entry)
 	defer func() {
 		logger.Info("served", lager.Data{"duration": fmt.Sprint(time.Since(start)), "location": location})
 	}()
 
-	// do not redirect if the agent is known from the NoRedirect list
-	if !contains(h.config.NoRedirectAgents, request.Header.Get("User-Agent")) {
-		repoPath := strings.TrimLeft(request.URL.Path, "/")
-		// if go-get=1 redirect to godoc.org using an HTML redirect, as expected by go get
-		if request.URL.Query().Get("go-get") == "1" {
-			logger.Debug("redirect.meta", lager.Data{"path": repoPath})
-			fmt.Fprintf(writer,
-				"<meta http-equiv=\"refresh\" content=\"0; url=https://godoc.org/%s/%s\">",
-				h.config.ImportPrefix, repoPath)
-		} else {
-			logger.Debug("redirect.http", lager.Data{"location": location})
-			http.Redirect(writer, request, location, http.StatusFound)
+	goGet := request.URL.Query().Get("go-get") == "1"
+	knownAgent := contains(h.config.NoRedirectAgents, request.Header.Get("User-Agent"))
+
+	importPath := tenant.config.ImportPrefix + "/" + match.RepoName
+	docURL, err := h.renderDocURL(config.DocURLVars{
+		ImportPath: path.Join(importPath, match.Subpath),
+		RepoName:   match.RepoName,
+		Subpath:    match.Subpath,
+		Version:    match.Version,
+	})
+	if err != nil {
+		logger.Error("failed-rendering-doc-url", err)
+	}
+
+	// browsers get a landing page about the package if that is enabled
+	if !goGet && !knownAgent && h.config.LandingPages {
+		page := landingPage{
+			ImportPath:  path.Join(importPath, match.Subpath),
+			RepoPath:    importPath,
+			Location:    location,
+			VCS:         entry.GetVCS(),
+			Description: entry.Description,
+			Archived:    entry.Archived,
+			DocURL:      docURL,
+		}
+		logger.Debug("landing-page")
+		if err := h.landing.Execute(writer, page); err != nil {
+			logger.Error("failed-rendering-landing-page", err)
 		}
+		return
+	}
 
+	// otherwise browsers are sent straight to the source, unless the agent is
+	// known from the NoRedirect list. Modules served by another module proxy
+	// have no source to show, so browsers get their documentation instead.
+	if !goGet && !knownAgent {
+		target := location
+		if entry.GetVCS() == "mod" && docURL != "" {
+			target = docURL
+		}
+		logger.Debug("redirect.http", lager.Data{"location": target})
+		http.Redirect(writer, request, target, http.StatusFound)
 		return
 	}
 
-	goImportContent := fmt.Sprintf("%s git %s", h.config.ImportPrefix+"/"+repoName, location)
-	goImport := fmt.Sprintf("<meta name=\"go-import\" content=\"%s\">", goImportContent)
-	logger.Debug("meta.go-import", lager.Data{"content": goImportContent})
-	fmt.Fprintf(writer, goImport)
+	page := metaPage{
+		ImportPath: importPath,
+		GoImport:   goImport(importPath, entry),
+		GoSource:   goSource(importPath, entry),
+	}
+	logger.Debug("meta.go-import", lager.Data{"content": page.GoImport})
+	logger.Debug("meta.go-source", lager.Data{"content": page.GoSource})
+
+	// everything but the agents from the NoRedirect list also gets a refresh
+	// to the documentation, so the page is useful when opened in a browser
+	if !knownAgent {
+		page.DocURL = docURL
+		logger.Debug("redirect.meta", lager.Data{"url": docURL})
+	}
 
-	goSourceContent := fmt.Sprintf("%s _ %s", h.config.ImportPrefix+"/"+repoName, location)
-	goSource := fmt.Sprintf("<meta name=\"go-source\" content=\"%s\">", goSourceContent)
-	logger.Debug("meta.go-source", lager.Data{"content": goSourceContent})
-	fmt.Fprintf(writer, goSource)
+	if err := metaTemplate.Execute(writer, page); err != nil {
+		logger.Error("failed-rendering-meta", err)
+	}
+}
+
+// canonicalPath returns the request path for match with the repo name in its
+// canonical case.
+func canonicalPath(match match, rawQuery string) string {
+	target := "/" + match.RepoName
+	if match.Version != "" {
+		target += "@" + match.Version
+	}
+	if match.Subpath != "" {
+		target += "/" + match.Subpath
+	}
+	if rawQuery != "" {
+		target += "?" + rawQuery
+	}
+	return target
+}
+
+func (h *Handler) renderDocURL(vars config.DocURLVars) (string, error) {
+	var buf bytes.Buffer
+	if err := h.docURL.Execute(&buf, vars); err != nil {
+		return "", err
+	}
+	return buf.String(), nil
 }
 
 func contains(slice []string, object string) bool {
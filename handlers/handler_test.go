@@ -2,7 +2,6 @@ package handlers_test
 
 import (
 	"fmt"
-	"io/ioutil"
 	"net/http"
 	"net/http/httptest"
 
@@ -10,7 +9,9 @@ import (
 	"github.com/cloudfoundry/go-fetcher/config"
 	"github.com/cloudfoundry/go-fetcher/handlers"
 	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/ginkgo/extensions/table"
 	. "github.com/onsi/gomega"
+	"github.com/onsi/gomega/gbytes"
 	"code.cloudfoundry.org/clock"
 	"code.cloudfoundry.org/lager/lagertest"
 )
@@ -31,8 +32,8 @@ var _ = Describe("Handler", func() {
 			OrgList:          []string{"org1", "org2"},
 			ImportPrefix:     "import-prefix",
 			NoRedirectAgents: []string{"NoRedirect"},
-			Overrides: map[string]string{
-				"overridden": "http://override.org/other-org/overridden"},
+			Overrides: map[string]config.Override{
+				"overridden": {Repo: "http://override.org/other-org/overridden"}},
 			GithubURL:    "http://example.com",
 			GithubAPIKey: "somekey-somekey",
 			IndexPath:    "../public/index.html",
@@ -42,45 +43,86 @@ var _ = Describe("Handler", func() {
 		cacheLogger := lagertest.NewTestLogger("cache")
 		clock := clock.NewClock()
 		locationCache = cache.NewLocationCache(cacheLogger, clock)
-		handler = handlers.NewHandler(logger, cfg, locationCache)
+		handler = handlers.NewHandler(logger, cfg, map[string]cache.Store{"": locationCache}, nil, nil)
 	})
 
-    Describe("Index", func() {
-        var indexHtml []byte
+	Describe("Index", func() {
+		var path string
 
-    	JustBeforeEach(func() {
+		JustBeforeEach(func() {
+			locationCache.Add("repo2", fmt.Sprintf("%s/org2/repo2", cfg.GithubURL))
+			locationCache.Add("repo1", fmt.Sprintf("%s/org1/repo1", cfg.GithubURL))
+			handler = handlers.NewHandler(logger, cfg, map[string]cache.Store{"": locationCache}, nil, nil)
+
+			var err error
+			req, err = http.NewRequest("GET", path, nil)
+			Expect(err).NotTo(HaveOccurred())
 			res = httptest.NewRecorder()
 			handler.GetMeta(res, req)
-			indexHtml, _ = ioutil.ReadFile(cfg.IndexPath)
 		})
 
-		Context("when a default URL is requested", func() {
+		for _, indexPath := range []string{"/", "/index.htm", "/index.html"} {
+			indexPath := indexPath
+
+			Context("when "+indexPath+" is requested", func() {
+				BeforeEach(func() {
+					path = indexPath
+				})
+
+				It("renders the index template with the tracked orgs and packages", func() {
+					Expect(res.Code).To(Equal(http.StatusOK))
+					body := res.Body.String()
+					Expect(body).To(ContainSubstring("Welcome to import-prefix!"))
+					Expect(body).To(ContainSubstring("<li>org1</li>"))
+					Expect(body).To(ContainSubstring("<li>org2</li>"))
+					Expect(body).To(ContainSubstring("3 packages, last refreshed never."))
+					Expect(body).To(MatchRegexp(`(?s)import-prefix/overridden</a>.*import-prefix/repo1</a>.*import-prefix/repo2</a>`))
+					Expect(body).To(ContainSubstring(fmt.Sprintf(`<li data-name="repo1"><a href="%s/org1/repo1">import-prefix/repo1</a></li>`, cfg.GithubURL)))
+					Expect(body).To(ContainSubstring(`<a href="/-/search">`))
+				})
+			})
+		}
+
+		Context("when the cache has been refreshed", func() {
 			BeforeEach(func() {
-				var err error
-				req, err = http.NewRequest("GET", "/", nil)
-				Expect(err).NotTo(HaveOccurred())
+				path = "/"
+				locationCache.ReplaceAll(nil)
 			})
 
-			It("/ serves the static index page", func() {
-				Expect(res.Code).To(Equal(http.StatusOK))
-				body := res.Body.String()
-				Expect(body).To(Equal(string(indexHtml)))
+			It("shows the time of the last refresh", func() {
+				Expect(res.Body.String()).To(MatchRegexp(`last refreshed \d{4}-\d{2}-\d{2} \d{2}:\d{2}:\d{2} UTC\.`))
+			})
+		})
+
+		Context("when there is no IndexPath", func() {
+			BeforeEach(func() {
+				path = "/"
+				cfg.IndexPath = ""
 			})
 
-			It("/index.htm serves the static index page", func() {
+			It("renders the built-in index template", func() {
 				Expect(res.Code).To(Equal(http.StatusOK))
 				body := res.Body.String()
-				Expect(body).To(Equal(string(indexHtml)))
+				Expect(body).To(ContainSubstring("<h1>import-prefix</h1>"))
+				Expect(body).To(ContainSubstring("<li>org1</li>"))
+				Expect(body).To(ContainSubstring("import-prefix/repo2</a>"))
+				Expect(body).To(ContainSubstring(`<a href="/-/search">`))
+			})
+		})
+
+		Context("when the IndexPath cannot be parsed", func() {
+			BeforeEach(func() {
+				path = "/"
+				cfg.IndexPath = "../public/does-not-exist.html"
 			})
 
-			It("/index.html serves the static index page", func() {
+			It("renders the built-in index template", func() {
 				Expect(res.Code).To(Equal(http.StatusOK))
-				body := res.Body.String()
-				Expect(body).To(Equal(string(indexHtml)))
+				Expect(res.Body.String()).To(ContainSubstring("<h1>import-prefix</h1>"))
+				Expect(logger).To(gbytes.Say("failed-loading-template"))
 			})
 		})
 	})
-	
 
 	Describe("GetMeta", func() {
 		JustBeforeEach(func() {
@@ -113,7 +155,179 @@ var _ = Describe("Handler", func() {
 
 					resBody := res.Body.String()
 					Expect(resBody).To(ContainSubstring(fmt.Sprintf("<meta name=\"go-import\" content=\"import-prefix/repo1 git %s/org1/repo1\">", cfg.GithubURL)))
-					Expect(resBody).To(ContainSubstring(fmt.Sprintf("<meta name=\"go-source\" content=\"import-prefix/repo1 _ %s/org1/repo1\">", cfg.GithubURL)))
+					Expect(resBody).To(ContainSubstring(fmt.Sprintf("<meta name=\"go-source\" content=\"import-prefix/repo1 %[1]s/org1/repo1 _ _\">", cfg.GithubURL)))
+				})
+			})
+		})
+
+		Context("when the request sets go-get=1", func() {
+			BeforeEach(func() {
+				var err error
+				locationCache.Add("repo1", fmt.Sprintf("%s/org1/repo1", cfg.GithubURL))
+				req, err = http.NewRequest("GET", "/repo1/subpackage?go-get=1", nil)
+				Expect(err).NotTo(HaveOccurred())
+				req.Header.Add("User-Agent", "Some-Proxy-Rewritten-Agent")
+			})
+
+			It("returns an HTML document with the go-import and go-source meta tags", func() {
+				Expect(res.Code).To(Equal(http.StatusOK))
+				Expect(res.Header().Get("Content-Type")).To(Equal("text/html; charset=utf-8"))
+
+				resBody := res.Body.String()
+				Expect(resBody).To(HavePrefix("<!DOCTYPE html>"))
+				Expect(resBody).To(ContainSubstring(fmt.Sprintf("<meta name=\"go-import\" content=\"import-prefix/repo1 git %s/org1/repo1\">", cfg.GithubURL)))
+				Expect(resBody).To(ContainSubstring(fmt.Sprintf("<meta name=\"go-source\" content=\"import-prefix/repo1 %[1]s/org1/repo1 _ _\">", cfg.GithubURL)))
+			})
+
+			It("refreshes browsers to the documentation", func() {
+				resBody := res.Body.String()
+				Expect(resBody).To(ContainSubstring("<meta http-equiv=\"refresh\" content=\"0; url=https://pkg.go.dev/import-prefix/repo1/subpackage\">"))
+				Expect(resBody).To(ContainSubstring("<a href=\"https://pkg.go.dev/import-prefix/repo1/subpackage\">"))
+			})
+
+			Context("when a DocURLTemplate is configured", func() {
+				BeforeEach(func() {
+					req.Header.Set("User-Agent", "Mozilla/5.0")
+				})
+
+				DescribeTable("renders the template variables",
+					func(docURLTemplate, path, expectedURL string) {
+						cfg.DocURLTemplate = docURLTemplate
+						handler = handlers.NewHandler(logger, cfg, map[string]cache.Store{"": locationCache}, nil, nil)
+
+						var err error
+						req, err = http.NewRequest("GET", path, nil)
+						Expect(err).NotTo(HaveOccurred())
+						res = httptest.NewRecorder()
+						handler.GetMeta(res, req)
+
+						Expect(res.Code).To(Equal(http.StatusOK))
+						Expect(res.Body.String()).To(ContainSubstring(fmt.Sprintf("<meta http-equiv=\"refresh\" content=\"0; url=%s\">", expectedURL)))
+					},
+					Entry("ImportPath", "https://docs.example.com/{{.ImportPath}}", "/repo1/sub/pkg?go-get=1", "https://docs.example.com/import-prefix/repo1/sub/pkg"),
+					Entry("RepoName", "https://docs.example.com/repos/{{.RepoName}}", "/repo1/sub/pkg?go-get=1", "https://docs.example.com/repos/repo1"),
+					Entry("Subpath", "https://docs.example.com/{{.RepoName}}/-/{{.Subpath}}", "/repo1/sub/pkg?go-get=1", "https://docs.example.com/repo1/-/sub/pkg"),
+					Entry("Version", "https://docs.example.com/{{.ImportPath}}?v={{.Version}}", "/repo1@v1.2.3/sub?go-get=1", "https://docs.example.com/import-prefix/repo1/sub?v=v1.2.3"),
+					Entry("the default template with a version", "", "/repo1@v1.2.3/sub?go-get=1", "https://pkg.go.dev/import-prefix/repo1/sub@v1.2.3"),
+				)
+			})
+
+			Context("when the user agent is in the NoRedirectAgents list", func() {
+				BeforeEach(func() {
+					req.Header.Set("User-Agent", "NoRedirect")
+				})
+
+				It("returns the meta tags without a refresh", func() {
+					Expect(res.Code).To(Equal(http.StatusOK))
+
+					resBody := res.Body.String()
+					Expect(resBody).To(ContainSubstring("<meta name=\"go-import\""))
+					Expect(resBody).NotTo(ContainSubstring("http-equiv=\"refresh\""))
+				})
+			})
+		})
+
+		Context("when the forge of the repo is known", func() {
+			DescribeTable("returns directory and file templates for the forge",
+				func(entry cache.Entry, expectedGoSource string) {
+					locationCache.Upsert("repo1", entry)
+
+					var err error
+					req, err = http.NewRequest("GET", "/repo1?go-get=1", nil)
+					Expect(err).NotTo(HaveOccurred())
+					res = httptest.NewRecorder()
+					handler.GetMeta(res, req)
+
+					Expect(res.Code).To(Equal(http.StatusOK))
+					Expect(res.Body.String()).To(ContainSubstring(fmt.Sprintf("<meta name=\"go-source\" content=\"%s\">", expectedGoSource)))
+				},
+				Entry("GitHub",
+					cache.Entry{Location: "https://github.com/org1/repo1", Forge: cache.ForgeGitHub, DefaultBranch: "main"},
+					"import-prefix/repo1 https://github.com/org1/repo1 https://github.com/org1/repo1/tree/main{/dir} https://github.com/org1/repo1/blob/main{/dir}/{file}#L{line}"),
+				Entry("GitHub Enterprise",
+					cache.Entry{Location: "https://github.example.com/org1/repo1", Forge: cache.ForgeGitHubEnterprise, DefaultBranch: "develop"},
+					"import-prefix/repo1 https://github.example.com/org1/repo1 https://github.example.com/org1/repo1/tree/develop{/dir} https://github.example.com/org1/repo1/blob/develop{/dir}/{file}#L{line}"),
+				Entry("GitLab",
+					cache.Entry{Location: "https://gitlab.com/org1/repo1.git", Forge: cache.ForgeGitLab, DefaultBranch: "main"},
+					"import-prefix/repo1 https://gitlab.com/org1/repo1 https://gitlab.com/org1/repo1/-/tree/main{/dir} https://gitlab.com/org1/repo1/-/blob/main{/dir}/{file}#L{line}"),
+				Entry("Gitea",
+					cache.Entry{Location: "https://gitea.com/org1/repo1", Forge: cache.ForgeGitea, DefaultBranch: "main"},
+					"import-prefix/repo1 https://gitea.com/org1/repo1 https://gitea.com/org1/repo1/src/branch/main{/dir} https://gitea.com/org1/repo1/src/branch/main{/dir}/{file}#L{line}"),
+				Entry("a forge without a known default branch",
+					cache.Entry{Location: "https://github.com/org1/repo1", Forge: cache.ForgeGitHub},
+					"import-prefix/repo1 https://github.com/org1/repo1 https://github.com/org1/repo1/tree/master{/dir} https://github.com/org1/repo1/blob/master{/dir}/{file}#L{line}"),
+				Entry("a generic forge",
+					cache.Entry{Location: "https://git.example.com/repo1", Forge: cache.ForgeGeneric},
+					"import-prefix/repo1 https://git.example.com/repo1 _ _"),
+			)
+		})
+
+		Context("when the override points at a module in a subdirectory", func() {
+			BeforeEach(func() {
+				cfg.Overrides["sdk"] = config.Override{
+					Repo:          "https://github.com/org1/sdk",
+					Subdir:        "sdk/go",
+					DefaultBranch: "main",
+				}
+				handler = handlers.NewHandler(logger, cfg, map[string]cache.Store{"": locationCache}, nil, nil)
+
+				var err error
+				req, err = http.NewRequest("GET", "/sdk/client?go-get=1", nil)
+				Expect(err).NotTo(HaveOccurred())
+			})
+
+			It("returns the subdirectory in the go-import meta tag", func() {
+				Expect(res.Code).To(Equal(http.StatusOK))
+				Expect(res.Body.String()).To(ContainSubstring("<meta name=\"go-import\" content=\"import-prefix/sdk git https://github.com/org1/sdk sdk/go\">"))
+			})
+
+			It("links to the source relative to the subdirectory", func() {
+				Expect(res.Body.String()).To(ContainSubstring("<meta name=\"go-source\" content=\"import-prefix/sdk https://github.com/org1/sdk https://github.com/org1/sdk/tree/main/sdk/go{/dir} https://github.com/org1/sdk/blob/main/sdk/go{/dir}/{file}#L{line}\">"))
+			})
+		})
+
+		Context("when the override uses another version control system", func() {
+			DescribeTable("returns the VCS in the go-import meta tag",
+				func(vcs, repo, expectedGoImport string, expectGoSource bool) {
+					cfg.Overrides["legacy"] = config.Override{VCS: vcs, Repo: repo}
+					handler = handlers.NewHandler(logger, cfg, map[string]cache.Store{"": locationCache}, nil, nil)
+
+					var err error
+					req, err = http.NewRequest("GET", "/legacy/pkg?go-get=1", nil)
+					Expect(err).NotTo(HaveOccurred())
+					res = httptest.NewRecorder()
+					handler.GetMeta(res, req)
+
+					Expect(res.Code).To(Equal(http.StatusOK))
+					Expect(res.Body.String()).To(ContainSubstring(fmt.Sprintf("<meta name=\"go-import\" content=\"%s\">", expectedGoImport)))
+					if expectGoSource {
+						Expect(res.Body.String()).To(ContainSubstring("<meta name=\"go-source\""))
+					} else {
+						Expect(res.Body.String()).NotTo(ContainSubstring("<meta name=\"go-source\""))
+					}
+				},
+				Entry("git", "git", "https://example.com/legacy.git", "import-prefix/legacy git https://example.com/legacy.git", true),
+				Entry("the default", "", "https://example.com/legacy.git", "import-prefix/legacy git https://example.com/legacy.git", true),
+				Entry("Mercurial", "hg", "https://hg.example.com/legacy", "import-prefix/legacy hg https://hg.example.com/legacy", true),
+				Entry("Subversion", "svn", "https://svn.example.com/legacy", "import-prefix/legacy svn https://svn.example.com/legacy", true),
+				Entry("Bazaar", "bzr", "https://bzr.example.com/legacy", "import-prefix/legacy bzr https://bzr.example.com/legacy", true),
+				Entry("Fossil", "fossil", "https://fossil.example.com/legacy", "import-prefix/legacy fossil https://fossil.example.com/legacy", true),
+				Entry("a module proxy", "mod", "https://proxy.example.com", "import-prefix/legacy mod https://proxy.example.com", false),
+			)
+
+			Context("when a browser requests a module served by another module proxy", func() {
+				BeforeEach(func() {
+					cfg.Overrides["legacy"] = config.Override{VCS: "mod", Repo: "https://proxy.example.com"}
+					handler = handlers.NewHandler(logger, cfg, map[string]cache.Store{"": locationCache}, nil, nil)
+
+					var err error
+					req, err = http.NewRequest("GET", "/legacy/pkg", nil)
+					Expect(err).NotTo(HaveOccurred())
+				})
+
+				It("redirects to the documentation", func() {
+					Expect(res.Code).To(Equal(http.StatusFound))
+					Expect(res.Header().Get("Location")).To(Equal("https://pkg.go.dev/import-prefix/legacy/pkg"))
 				})
 			})
 		})
@@ -0,0 +1,105 @@
+package handlers
+
+import (
+	"html/template"
+	"io/ioutil"
+	"net/http"
+	"path/filepath"
+	"time"
+
+	"code.cloudfoundry.org/lager"
+)
+
+// indexPage is the data available to the index template.
+type indexPage struct {
+	Host         string
+	ImportPrefix string
+	Orgs         []string
+	RepoCount    int
+	// RefreshedAt is when the repos of the orgs were last fetched, or the
+	// zero time if they have not been yet.
+	RefreshedAt time.Time
+	Packages    []indexPackage
+}
+
+type indexPackage struct {
+	Name       string
+	ImportPath string
+	Location   string
+}
+
+var indexTemplate = template.Must(template.New("index").Parse(`<!DOCTYPE html>
+<html>
+<head>
+<meta http-equiv="Content-Type" content="text/html; charset=utf-8">
+<title>{{.ImportPrefix}}</title>
+</head>
+<body>
+<h1>{{.ImportPrefix}}</h1>
+<p>This is a <i>go get</i> routing service for the packages of the following organizations:</p>
+<ul>
+{{- range .Orgs}}
+<li>{{.}}</li>
+{{- end}}
+</ul>
+<p>{{.RepoCount}} packages, last refreshed {{if .RefreshedAt.IsZero}}never{{else}}{{.RefreshedAt.UTC.Format "2006-01-02 15:04:05 MST"}}{{end}}.</p>
+<input id="filter" type="search" placeholder="Filter packages" autofocus>
+<a href="/-/search">Search by name or description</a>
+<ul id="packages">
+{{- range .Packages}}
+<li data-name="{{.Name}}"><a href="{{.Location}}">{{.ImportPath}}</a></li>
+{{- end}}
+</ul>
+<script>
+document.getElementById("filter").addEventListener("input", function() {
+	var query = this.value.toLowerCase();
+	document.querySelectorAll("#packages li").forEach(function(li) {
+		li.style.display = li.dataset.name.toLowerCase().indexOf(query) >= 0 ? "" : "none";
+	});
+});
+</script>
+</body>
+</html>
+`))
+
+// loadTemplate parses the template at path, falling back to the built-in
+// one if path is empty or cannot be parsed.
+func loadTemplate(logger lager.Logger, path string, builtin *template.Template) *template.Template {
+	if path == "" {
+		return builtin
+	}
+
+	contents, err := ioutil.ReadFile(filepath.Clean(path))
+	if err == nil {
+		var t *template.Template
+		if t, err = template.New(filepath.Base(path)).Parse(string(contents)); err == nil {
+			return t
+		}
+	}
+	logger.Error("failed-loading-template", err, lager.Data{"path": path, "template": builtin.Name()})
+	return builtin
+}
+
+func (h *Handler) serveIndex(logger lager.Logger, writer http.ResponseWriter, tenant *tenant) {
+	page := indexPage{
+		Host:         tenant.config.Host,
+		ImportPrefix: tenant.config.ImportPrefix,
+		Orgs:         tenant.config.OrgList,
+	}
+
+	if tenant.store != nil {
+		page.RefreshedAt = tenant.store.Stats().RefreshedAt
+	}
+	for _, p := range tenant.packages() {
+		page.Packages = append(page.Packages, indexPackage{
+			Name:       p.Name,
+			ImportPath: tenant.config.ImportPrefix + "/" + p.Name,
+			Location:   p.Entry.Location,
+		})
+	}
+	page.RepoCount = len(page.Packages)
+
+	if err := tenant.index.Execute(writer, page); err != nil {
+		logger.Error("failed-rendering-index", err)
+	}
+}
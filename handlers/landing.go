@@ -0,0 +1,42 @@
+package handlers
+
+import "html/template"
+
+// landingPage is the data available to the landing page template, shown to
+// browsers when LandingPages is enabled.
+type landingPage struct {
+	// ImportPath is the import path that was requested, including the path
+	// of the package below the repo.
+	ImportPath  string
+	RepoPath    string
+	Location    string
+	VCS         string
+	Description string
+	Archived    bool
+	DocURL      string
+}
+
+var landingTemplate = template.Must(template.New("landing").Parse(`<!DOCTYPE html>
+<html>
+<head>
+<meta http-equiv="Content-Type" content="text/html; charset=utf-8">
+<title>{{.ImportPath}}</title>
+</head>
+<body>
+<h1>{{.ImportPath}}</h1>
+{{- if .Archived}}
+<p><strong>This repository is archived and no longer maintained.</strong></p>
+{{- end}}
+{{- if .Description}}
+<p>{{.Description}}</p>
+{{- end}}
+<pre>go get {{.ImportPath}}</pre>
+<ul>
+<li>Source: <a href="{{.Location}}">{{.Location}}</a></li>
+{{- if .DocURL}}
+<li>Documentation: <a href="{{.DocURL}}">{{.DocURL}}</a></li>
+{{- end}}
+</ul>
+</body>
+</html>
+`))
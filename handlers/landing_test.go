@@ -0,0 +1,129 @@
+package handlers_test
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+
+	"code.cloudfoundry.org/clock"
+	"code.cloudfoundry.org/lager/lagertest"
+	"github.com/cloudfoundry/go-fetcher/cache"
+	"github.com/cloudfoundry/go-fetcher/config"
+	"github.com/cloudfoundry/go-fetcher/handlers"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Landing pages", func() {
+	var (
+		cfg           config.Config
+		locationCache *cache.LocationCache
+		res           *httptest.ResponseRecorder
+		path          string
+		userAgent     string
+	)
+
+	BeforeEach(func() {
+		cfg = config.Config{
+			ImportPrefix:     "import-prefix",
+			NoRedirectAgents: []string{"NoRedirect"},
+			LandingPages:     true,
+		}
+
+		locationCache = cache.NewLocationCache(lagertest.NewTestLogger("cache"), clock.NewClock())
+		locationCache.Upsert("repo1", cache.Entry{
+			Location:    "https://github.com/org1/repo1",
+			Description: "The first <repo>",
+			Archived:    true,
+		})
+		path = "/repo1/pkg"
+		userAgent = "Mozilla/5.0"
+	})
+
+	JustBeforeEach(func() {
+		handler := handlers.NewHandler(lagertest.NewTestLogger("test"), cfg, map[string]cache.Store{"": locationCache}, nil, nil)
+		req, err := http.NewRequest("GET", path, nil)
+		Expect(err).NotTo(HaveOccurred())
+		req.Header.Set("User-Agent", userAgent)
+		res = httptest.NewRecorder()
+		handler.GetMeta(res, req)
+	})
+
+	It("shows browsers a page about the package", func() {
+		Expect(res.Code).To(Equal(http.StatusOK))
+		body := res.Body.String()
+		Expect(body).To(ContainSubstring("<h1>import-prefix/repo1/pkg</h1>"))
+		Expect(body).To(ContainSubstring("<pre>go get import-prefix/repo1/pkg</pre>"))
+		Expect(body).To(ContainSubstring(`<a href="https://github.com/org1/repo1">`))
+		Expect(body).To(ContainSubstring("<p>The first &lt;repo&gt;</p>"))
+		Expect(body).To(ContainSubstring("This repository is archived"))
+		Expect(body).To(ContainSubstring(`<a href="https://pkg.go.dev/import-prefix/repo1/pkg">`))
+	})
+
+	Context("when the repo is not archived", func() {
+		BeforeEach(func() {
+			locationCache.Add("repo1", "https://github.com/org1/repo1")
+		})
+
+		It("does not say it is", func() {
+			Expect(res.Code).To(Equal(http.StatusOK))
+			Expect(res.Body.String()).NotTo(ContainSubstring("archived"))
+		})
+	})
+
+	Context("when go get requests the package", func() {
+		BeforeEach(func() {
+			path = "/repo1/pkg?go-get=1"
+		})
+
+		It("serves the meta tags", func() {
+			Expect(res.Code).To(Equal(http.StatusOK))
+			Expect(res.Body.String()).To(ContainSubstring(`<meta name="go-import" content="import-prefix/repo1 git https://github.com/org1/repo1">`))
+		})
+	})
+
+	Context("when the agent is in the NoRedirectAgents list", func() {
+		BeforeEach(func() {
+			userAgent = "NoRedirect"
+		})
+
+		It("serves the meta tags", func() {
+			Expect(res.Body.String()).To(ContainSubstring(`<meta name="go-import"`))
+		})
+	})
+
+	Context("when landing pages are disabled", func() {
+		BeforeEach(func() {
+			cfg.LandingPages = false
+		})
+
+		It("redirects to the source", func() {
+			Expect(res.Code).To(Equal(http.StatusFound))
+			Expect(res.Header().Get("Location")).To(Equal("https://github.com/org1/repo1"))
+		})
+	})
+
+	Context("when there is a custom template", func() {
+		var tmpDir string
+
+		BeforeEach(func() {
+			var err error
+			tmpDir, err = ioutil.TempDir("", "landing")
+			Expect(err).NotTo(HaveOccurred())
+
+			cfg.LandingPagePath = filepath.Join(tmpDir, "landing.html")
+			Expect(ioutil.WriteFile(cfg.LandingPagePath, []byte(`{{.RepoPath}} at {{.Location}} ({{.VCS}})`), 0644)).To(Succeed())
+		})
+
+		AfterEach(func() {
+			Expect(os.RemoveAll(tmpDir)).To(Succeed())
+		})
+
+		It("renders it", func() {
+			Expect(res.Code).To(Equal(http.StatusOK))
+			Expect(res.Body.String()).To(Equal("import-prefix/repo1 at https://github.com/org1/repo1 (git)"))
+		})
+	})
+})
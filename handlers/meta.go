@@ -0,0 +1,92 @@
+package handlers
+
+import (
+	"fmt"
+	"html/template"
+	"strings"
+
+	"github.com/cloudfoundry/go-fetcher/cache"
+)
+
+// defaultBranch is used in source links when the default branch of a repo
+// is not known.
+const defaultBranch = "master"
+
+// metaPage is the document served to go get and other tools that discover
+// the repository of an import path through HTML meta tags.
+type metaPage struct {
+	ImportPath string
+	GoImport   string
+	GoSource   string
+	DocURL     string
+}
+
+var metaTemplate = template.Must(template.New("meta").Parse(`<!DOCTYPE html>
+<html>
+<head>
+<meta http-equiv="Content-Type" content="text/html; charset=utf-8">
+<meta name="go-import" content="{{.GoImport}}">
+{{- if .GoSource}}
+<meta name="go-source" content="{{.GoSource}}">
+{{- end}}
+{{- if .DocURL}}
+<meta http-equiv="refresh" content="0; url={{.DocURL}}">
+{{- end}}
+</head>
+<body>
+{{- if .DocURL}}
+Redirecting to <a href="{{.DocURL}}">{{.DocURL}}</a>...
+{{- else}}
+go get {{.ImportPath}}
+{{- end}}
+</body>
+</html>
+`))
+
+// goImport returns the content of the go-import meta tag for a repo. The
+// subdirectory of the module, if any, is the optional fourth field understood
+// by newer go commands.
+func goImport(importPath string, entry cache.Entry) string {
+	content := fmt.Sprintf("%s %s %s", importPath, entry.GetVCS(), entry.Location)
+	if entry.Subdir != "" {
+		content += " " + entry.Subdir
+	}
+	return content
+}
+
+// goSource returns the content of the go-source meta tag for a repo, i.e. the
+// import prefix followed by the home page, directory and file templates.
+// Only forges whose URL layout is known get directory and file templates,
+// and modules served by another module proxy get no go-source at all.
+func goSource(importPath string, entry cache.Entry) string {
+	if entry.GetVCS() == "mod" {
+		return ""
+	}
+
+	home := strings.TrimSuffix(entry.Location, ".git")
+	branch := entry.DefaultBranch
+	if branch == "" {
+		branch = defaultBranch
+	}
+	// packages are relative to the module, not to the root of the repo
+	if entry.Subdir != "" {
+		branch += "/" + entry.Subdir
+	}
+
+	var dir, file string
+	switch entry.Forge {
+	case cache.ForgeGitHub, cache.ForgeGitHubEnterprise:
+		dir = home + "/tree/" + branch + "{/dir}"
+		file = home + "/blob/" + branch + "{/dir}/{file}#L{line}"
+	case cache.ForgeGitLab:
+		dir = home + "/-/tree/" + branch + "{/dir}"
+		file = home + "/-/blob/" + branch + "{/dir}/{file}#L{line}"
+	case cache.ForgeGitea:
+		dir = home + "/src/branch/" + branch + "{/dir}"
+		file = home + "/src/branch/" + branch + "{/dir}/{file}#L{line}"
+	default:
+		dir, file = "_", "_"
+	}
+
+	return fmt.Sprintf("%s %s %s %s", importPath, home, dir, file)
+}
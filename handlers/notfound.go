@@ -0,0 +1,111 @@
+package handlers
+
+import (
+	"html/template"
+	"net/http"
+	"sort"
+	"strings"
+
+	"code.cloudfoundry.org/lager"
+)
+
+const maxSuggestions = 5
+
+type suggestion struct {
+	Name       string `json:"name"`
+	ImportPath string `json:"import_path"`
+
+	distance int
+}
+
+type notFoundPage struct {
+	Error       string       `json:"error"`
+	ImportPath  string       `json:"-"`
+	Suggestions []suggestion `json:"suggestions"`
+}
+
+var notFoundTemplate = template.Must(template.New("not-found").Parse(`<!DOCTYPE html>
+<html>
+<head>
+<meta http-equiv="Content-Type" content="text/html; charset=utf-8">
+<title>Not found: {{.ImportPath}}</title>
+</head>
+<body>
+<h1>{{.ImportPath}} not found</h1>
+{{- if .Suggestions}}
+<p>Did you mean:</p>
+<ul>
+{{- range .Suggestions}}
+<li><a href="/{{.Name}}">{{.ImportPath}}</a></li>
+{{- end}}
+</ul>
+{{- end}}
+<p><a href="/-/search">Search packages</a> or see <a href="/">all packages</a>.</p>
+</body>
+</html>
+`))
+
+// serveNotFound answers a request for an unknown repo with the nearest
+// matches, as HTML or as JSON if the client accepts it.
+func (h *Handler) serveNotFound(logger lager.Logger, writer http.ResponseWriter, request *http.Request, tenant *tenant) {
+	requestPath := strings.Trim(request.URL.Path, "/")
+	page := notFoundPage{
+		Error:       "unknown repo " + requestPath,
+		ImportPath:  tenant.config.ImportPrefix + "/" + requestPath,
+		Suggestions: suggest(tenant, requestPath),
+	}
+	logger.Debug("suggestions", lager.Data{"count": len(page.Suggestions)})
+
+	if wantsJSON(request) {
+		writeJSON(logger, writer, http.StatusNotFound, page)
+		return
+	}
+
+	writer.Header().Set("Content-Type", "text/html; charset=utf-8")
+	writer.WriteHeader(http.StatusNotFound)
+	if err := notFoundTemplate.Execute(writer, page); err != nil {
+		logger.Error("failed-rendering-not-found", err)
+	}
+}
+
+// suggest returns the repos of the tenant closest to the start of
+// requestPath, comparing each repo name with as many segments of the path as
+// it has, ignoring case. Only the first maxQueryLength bytes of the path are
+// compared.
+func suggest(tenant *tenant, requestPath string) []suggestion {
+	if len(requestPath) > maxQueryLength {
+		requestPath = strings.ToValidUTF8(requestPath[:maxQueryLength], "")
+	}
+	segments := strings.Split(strings.ToLower(requestPath), "/")
+	if i := strings.Index(segments[len(segments)-1], "@"); i >= 0 {
+		segments[len(segments)-1] = segments[len(segments)-1][:i]
+	}
+
+	suggestions := []suggestion{}
+	for _, p := range tenant.packages() {
+		name := strings.ToLower(p.Name)
+		n := strings.Count(name, "/") + 1
+		if n > len(segments) {
+			n = len(segments)
+		}
+		prefix := strings.Join(segments[:n], "/")
+
+		distance, ok := editDistance(name, prefix, len(prefix)/4+1)
+		if !ok {
+			continue
+		}
+		suggestions = append(suggestions, suggestion{
+			Name:       p.Name,
+			ImportPath: tenant.config.ImportPrefix + "/" + p.Name,
+			distance:   distance,
+		})
+	}
+
+	sort.SliceStable(suggestions, func(i, j int) bool {
+		return suggestions[i].distance < suggestions[j].distance
+	})
+	if len(suggestions) > maxSuggestions {
+		suggestions = suggestions[:maxSuggestions]
+	}
+	return suggestions
+}
@@ -0,0 +1,130 @@
+package handlers_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+
+	"code.cloudfoundry.org/clock"
+	"code.cloudfoundry.org/lager/lagertest"
+	"github.com/cloudfoundry/go-fetcher/cache"
+	"github.com/cloudfoundry/go-fetcher/config"
+	"github.com/cloudfoundry/go-fetcher/handlers"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Not found", func() {
+	var (
+		handler *handlers.Handler
+		req     *http.Request
+		res     *httptest.ResponseRecorder
+	)
+
+	BeforeEach(func() {
+		cfg := config.Config{
+			ImportPrefix: "import-prefix",
+			Overrides: map[string]config.Override{
+				"tools/linter": {Repo: "https://example.com/linter"},
+			},
+		}
+
+		locationCache := cache.NewLocationCache(lagertest.NewTestLogger("cache"), clock.NewClock())
+		locationCache.Add("lager", "https://github.com/org/lager")
+		locationCache.Add("bbs", "https://github.com/org/bbs")
+		locationCache.Add("GoRouter", "https://github.com/org/gorouter")
+
+		handler = handlers.NewHandler(lagertest.NewTestLogger("test"), cfg, map[string]cache.Store{"": locationCache}, nil, nil)
+	})
+
+	JustBeforeEach(func() {
+		res = httptest.NewRecorder()
+		handler.GetMeta(res, req)
+	})
+
+	Context("when a browser requests an unknown repo", func() {
+		BeforeEach(func() {
+			var err error
+			req, err = http.NewRequest("GET", "/lagr/chug", nil)
+			Expect(err).NotTo(HaveOccurred())
+		})
+
+		It("renders a page with the nearest matches", func() {
+			Expect(res.Code).To(Equal(http.StatusNotFound))
+			Expect(res.Header().Get("Content-Type")).To(Equal("text/html; charset=utf-8"))
+			body := res.Body.String()
+			Expect(body).To(ContainSubstring("<h1>import-prefix/lagr/chug not found</h1>"))
+			Expect(body).To(ContainSubstring(`<li><a href="/lager">import-prefix/lager</a></li>`))
+			Expect(body).NotTo(ContainSubstring("import-prefix/bbs"))
+		})
+	})
+
+	Context("when an API client requests an unknown repo", func() {
+		BeforeEach(func() {
+			var err error
+			req, err = http.NewRequest("GET", "/go-router?go-get=1", nil)
+			Expect(err).NotTo(HaveOccurred())
+			req.Header.Set("Accept", "application/json")
+		})
+
+		It("returns the nearest matches ignoring case", func() {
+			Expect(res.Code).To(Equal(http.StatusNotFound))
+			Expect(res.Body.String()).To(MatchJSON(`{
+				"error": "unknown repo go-router",
+				"suggestions": [{"name": "GoRouter", "import_path": "import-prefix/GoRouter"}]
+			}`))
+		})
+	})
+
+	Context("when a nested repo is misspelled", func() {
+		BeforeEach(func() {
+			var err error
+			req, err = http.NewRequest("GET", "/tools/lintr/cmd?format=json", nil)
+			Expect(err).NotTo(HaveOccurred())
+		})
+
+		It("compares it with as many segments as the repo has", func() {
+			Expect(res.Code).To(Equal(http.StatusNotFound))
+			Expect(res.Body.String()).To(ContainSubstring(`"name":"tools/linter"`))
+		})
+	})
+
+	Context("when the path is very long", func() {
+		BeforeEach(func() {
+			var err error
+			req, err = http.NewRequest("GET", "/lagr"+strings.Repeat("x", 512*1024)+"?format=json", nil)
+			Expect(err).NotTo(HaveOccurred())
+		})
+
+		It("returns no suggestions", func() {
+			Expect(res.Code).To(Equal(http.StatusNotFound))
+			Expect(res.Body.String()).To(ContainSubstring(`"suggestions":[]`))
+		})
+	})
+
+	Context("when a long path starts with a misspelled repo", func() {
+		BeforeEach(func() {
+			var err error
+			req, err = http.NewRequest("GET", "/lagr"+strings.Repeat("/x", 256*1024)+"?format=json", nil)
+			Expect(err).NotTo(HaveOccurred())
+		})
+
+		It("suggests the repo", func() {
+			Expect(res.Code).To(Equal(http.StatusNotFound))
+			Expect(res.Body.String()).To(ContainSubstring(`"name":"lager"`))
+		})
+	})
+
+	Context("when nothing is close", func() {
+		BeforeEach(func() {
+			var err error
+			req, err = http.NewRequest("GET", "/something-else?format=json", nil)
+			Expect(err).NotTo(HaveOccurred())
+		})
+
+		It("returns no suggestions", func() {
+			Expect(res.Code).To(Equal(http.StatusNotFound))
+			Expect(res.Body.String()).To(MatchJSON(`{"error": "unknown repo something-else", "suggestions": []}`))
+		})
+	})
+})
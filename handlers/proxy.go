@@ -0,0 +1,229 @@
+package handlers
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"strings"
+
+	"code.cloudfoundry.org/lager"
+	"github.com/cloudfoundry/go-fetcher/proxy"
+)
+
+// isModuleRequest reports whether path is part of the module proxy protocol,
+// i.e. /<module>/@v/<file> or /<module>/@latest.
+func isModuleRequest(path string) bool {
+	return strings.Contains(path, "/@v/") || strings.HasSuffix(path, "/@latest")
+}
+
+// GetModule serves the GOPROXY protocol for modules hosted under the import
+// prefix, i.e. /<import prefix>/<repo>/@v/<file> as requested by the go
+// command.
+func (h *Handler) GetModule(writer http.ResponseWriter, request *http.Request) {
+	escapedPath, file := splitModuleRequest(request.URL.Path)
+	logger := h.logger.Session("handler.getmodule", lager.Data{"host": request.Host, "module": escapedPath, "file": file})
+
+	tenant, ok := h.tenant(request.Host)
+	if !ok {
+		logger.Info("unknown-host")
+		http.Error(writer, "not found: unknown host", http.StatusNotFound)
+		return
+	}
+
+	if h.proxy == nil {
+		logger.Debug("proxy-disabled")
+		http.Error(writer, "module proxy is not enabled", http.StatusNotFound)
+		return
+	}
+
+	fullPath, ok := unescape(escapedPath)
+	if !ok || fullPath == "" {
+		http.Error(writer, "invalid module path", http.StatusBadRequest)
+		return
+	}
+
+	modulePath := strings.TrimPrefix(fullPath, tenant.config.ImportPrefix+"/")
+	if modulePath == fullPath || modulePath == "" {
+		logger.Info("not-found.import-prefix")
+		http.Error(writer, "not found: unknown module "+fullPath, http.StatusNotFound)
+		return
+	}
+
+	// the module path is the vanity root, optionally followed by a major
+	// version suffix. Module paths are case-sensitive, so it has to match
+	// exactly.
+	match, ok := tenant.route(logger, modulePath)
+	if !ok || match.RepoName != match.Requested || match.Version != "" || !isMajorSuffix(match.Subpath) {
+		logger.Info("not-found")
+		http.Error(writer, "not found: unknown module "+fullPath, http.StatusNotFound)
+		return
+	}
+	entry := match.Entry
+
+	switch entry.GetVCS() {
+	case "git":
+	case "mod":
+		// the module is already served by another module proxy
+		target := strings.TrimSuffix(entry.Location, "/") + "/" + request.URL.Path[1:]
+		logger.Debug("redirect.proxy", lager.Data{"location": target})
+		http.Redirect(writer, request, target, http.StatusFound)
+		return
+	default:
+		logger.Info("unsupported-vcs", lager.Data{"vcs": entry.GetVCS()})
+		http.Error(writer, "not found: the module proxy only serves git repositories", http.StatusNotFound)
+		return
+	}
+
+	module := h.proxy.Module(fullPath, entry.Location, entry.Subdir)
+
+	var (
+		body        []byte
+		contentType string
+		err         error
+	)
+	switch {
+	case file == "@latest":
+		contentType = "application/json"
+		body, err = infoJSON(module.Latest())
+	case file == "list":
+		contentType = "text/plain; charset=utf-8"
+		var versions []string
+		versions, err = module.List()
+		if len(versions) > 0 {
+			body = []byte(strings.Join(versions, "\n") + "\n")
+		}
+	case strings.HasSuffix(file, ".info"):
+		contentType = "application/json"
+		body, err = withVersion(file, ".info", func(v string) ([]byte, error) {
+			return infoJSON(module.Info(v))
+		})
+	case strings.HasSuffix(file, ".mod"):
+		contentType = "text/plain; charset=utf-8"
+		body, err = withVersion(file, ".mod", module.Mod)
+	case strings.HasSuffix(file, ".zip"):
+		// zips are streamed, so errors can only be served until the first
+		// write
+		contentType = "application/zip"
+		stream := &streamWriter{writer: writer, contentType: contentType}
+		_, err = withVersion(file, ".zip", func(v string) ([]byte, error) {
+			return nil, module.Zip(v, stream)
+		})
+		if stream.written > 0 {
+			if err != nil {
+				logger.Error("failed-streaming-zip", err, lager.Data{"location": entry.Location, "bytes": stream.written})
+				return
+			}
+			logger.Debug("served", lager.Data{"location": entry.Location, "bytes": stream.written})
+			return
+		}
+	default:
+		err = proxy.ErrNotFound
+	}
+
+	if err == proxy.ErrNotFound {
+		logger.Info("version-not-found")
+		http.Error(writer, "not found: "+fullPath+" "+file, http.StatusNotFound)
+		return
+	}
+	if zipErr, ok := err.(*proxy.InvalidZipError); ok {
+		logger.Info("invalid-zip", lager.Data{"reason": zipErr.Reason})
+		http.Error(writer, "not found: "+fullPath+" "+file+": "+zipErr.Error(), http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		// the error may carry the output of git, naming the local mirror and
+		// the upstream, so it is only logged
+		logger.Error("failed-serving-module", err, lager.Data{"location": entry.Location})
+		http.Error(writer, "failed fetching the module from upstream", http.StatusBadGateway)
+		return
+	}
+
+	logger.Debug("served", lager.Data{"location": entry.Location, "bytes": len(body)})
+	writer.Header().Set("Content-Type", contentType)
+	writer.Write(body)
+}
+
+// streamWriter writes a response body as it is produced, setting its content
+// type on the first write.
+type streamWriter struct {
+	writer      http.ResponseWriter
+	contentType string
+	written     int
+}
+
+func (s *streamWriter) Write(p []byte) (int, error) {
+	if s.written == 0 {
+		s.writer.Header().Set("Content-Type", s.contentType)
+	}
+	n, err := s.writer.Write(p)
+	s.written += n
+	return n, err
+}
+
+// isMajorSuffix reports whether the part of a module path below the vanity
+// root is empty or a major version suffix such as v2.
+func isMajorSuffix(subpath string) bool {
+	if subpath == "" {
+		return true
+	}
+	if len(subpath) < 2 || subpath[0] != 'v' || subpath[1] < '1' || subpath[1] > '9' || subpath == "v1" {
+		return false
+	}
+	for _, r := range subpath[2:] {
+		if r < '0' || r > '9' {
+			return false
+		}
+	}
+	return true
+}
+
+func splitModuleRequest(path string) (string, string) {
+	path = strings.TrimPrefix(path, "/")
+	if i := strings.Index(path, "/@v/"); i >= 0 {
+		return path[:i], path[i+len("/@v/"):]
+	}
+	return strings.TrimSuffix(path, "/@latest"), "@latest"
+}
+
+func withVersion(file, ext string, serve func(string) ([]byte, error)) ([]byte, error) {
+	version, ok := unescape(strings.TrimSuffix(file, ext))
+	if !ok || version == "" {
+		return nil, proxy.ErrNotFound
+	}
+	return serve(version)
+}
+
+func infoJSON(info proxy.Info, err error) ([]byte, error) {
+	if err != nil {
+		return nil, err
+	}
+	return json.Marshal(info)
+}
+
+// unescape decodes the case encoding of module paths and versions used by
+// the proxy protocol, where an upper-case letter is written as '!' followed
+// by its lower-case form.
+func unescape(escaped string) (string, bool) {
+	var buf bytes.Buffer
+	bang := false
+	for _, r := range escaped {
+		switch {
+		case bang:
+			if r < 'a' || r > 'z' {
+				return "", false
+			}
+			buf.WriteRune(r - 'a' + 'A')
+			bang = false
+		case r == '!':
+			bang = true
+		case r >= 'A' && r <= 'Z':
+			return "", false
+		default:
+			buf.WriteRune(r)
+		}
+	}
+	if bang {
+		return "", false
+	}
+	return buf.String(), true
+}
@@ -0,0 +1,303 @@
+package handlers_test
+
+import (
+	"archive/zip"
+	"bytes"
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"os/exec"
+	"path/filepath"
+
+	"code.cloudfoundry.org/clock"
+	"code.cloudfoundry.org/lager/lagertest"
+	"github.com/cloudfoundry/go-fetcher/cache"
+	"github.com/cloudfoundry/go-fetcher/config"
+	"github.com/cloudfoundry/go-fetcher/handlers"
+	"github.com/cloudfoundry/go-fetcher/proxy"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Module proxy", func() {
+	var (
+		tmpDir        string
+		upstream      string
+		handler       *handlers.Handler
+		locationCache *cache.LocationCache
+		moduleProxy   *proxy.Proxy
+		cfg           config.Config
+		res           *httptest.ResponseRecorder
+		path          string
+	)
+
+	git := func(args ...string) {
+		cmd := exec.Command("git", args...)
+		cmd.Dir = upstream
+		cmd.Env = append(os.Environ(),
+			"GIT_AUTHOR_NAME=test",
+			"GIT_AUTHOR_EMAIL=test@example.com",
+			"GIT_COMMITTER_NAME=test",
+			"GIT_COMMITTER_EMAIL=test@example.com",
+			"GIT_COMMITTER_DATE=2019-06-01T12:00:00Z",
+		)
+		out, err := cmd.CombinedOutput()
+		Expect(err).NotTo(HaveOccurred(), string(out))
+	}
+
+	BeforeEach(func() {
+		var err error
+		tmpDir, err = ioutil.TempDir("", "handlers")
+		Expect(err).NotTo(HaveOccurred())
+
+		upstream = filepath.Join(tmpDir, "Repo1")
+		Expect(os.MkdirAll(upstream, 0755)).To(Succeed())
+		Expect(ioutil.WriteFile(filepath.Join(upstream, "go.mod"), []byte("module example.test/Repo1\n"), 0644)).To(Succeed())
+		Expect(ioutil.WriteFile(filepath.Join(upstream, "repo.go"), []byte("package repo\n"), 0644)).To(Succeed())
+		git("init", "--quiet")
+		git("add", "-A")
+		git("commit", "--quiet", "-m", "initial")
+		git("tag", "v1.0.0")
+		git("tag", "v1.1.0")
+
+		cfg = config.Config{
+			ImportPrefix: "example.test",
+			Overrides:    map[string]config.Override{},
+		}
+
+		locationCache = cache.NewLocationCache(lagertest.NewTestLogger("cache"), clock.NewClock())
+		locationCache.Add("Repo1", upstream)
+		moduleProxy = proxy.NewProxy(lagertest.NewTestLogger("proxy"), filepath.Join(tmpDir, "mirrors"), clock.NewClock())
+	})
+
+	AfterEach(func() {
+		Expect(os.RemoveAll(tmpDir)).To(Succeed())
+	})
+
+	JustBeforeEach(func() {
+		handler = handlers.NewHandler(lagertest.NewTestLogger("test"), cfg, map[string]cache.Store{"": locationCache}, nil, moduleProxy)
+		req, err := http.NewRequest("GET", path, nil)
+		Expect(err).NotTo(HaveOccurred())
+		res = httptest.NewRecorder()
+		handler.GetMeta(res, req)
+	})
+
+	Context("when listing versions", func() {
+		BeforeEach(func() {
+			path = "/example.test/!repo1/@v/list"
+		})
+
+		It("returns the tagged versions", func() {
+			Expect(res.Code).To(Equal(http.StatusOK))
+			Expect(res.Body.String()).To(Equal("v1.0.0\nv1.1.0\n"))
+		})
+	})
+
+	Context("when requesting the latest version", func() {
+		BeforeEach(func() {
+			path = "/example.test/!repo1/@latest"
+		})
+
+		It("returns the info of the highest version", func() {
+			Expect(res.Code).To(Equal(http.StatusOK))
+			Expect(res.Header().Get("Content-Type")).To(Equal("application/json"))
+
+			var info proxy.Info
+			Expect(json.Unmarshal(res.Body.Bytes(), &info)).To(Succeed())
+			Expect(info.Version).To(Equal("v1.1.0"))
+		})
+	})
+
+	Context("when requesting the info of a version", func() {
+		BeforeEach(func() {
+			path = "/example.test/!repo1/@v/v1.0.0.info"
+		})
+
+		It("returns the version and its time", func() {
+			Expect(res.Code).To(Equal(http.StatusOK))
+			Expect(res.Body.String()).To(MatchJSON(`{"Version": "v1.0.0", "Time": "2019-06-01T12:00:00Z"}`))
+		})
+	})
+
+	Context("when requesting the go.mod of a version", func() {
+		BeforeEach(func() {
+			path = "/example.test/!repo1/@v/v1.0.0.mod"
+		})
+
+		It("returns the go.mod file", func() {
+			Expect(res.Code).To(Equal(http.StatusOK))
+			Expect(res.Body.String()).To(Equal("module example.test/Repo1\n"))
+		})
+	})
+
+	Context("when requesting the zip of a version", func() {
+		BeforeEach(func() {
+			path = "/example.test/!repo1/@v/v1.0.0.zip"
+		})
+
+		It("returns the module zip", func() {
+			Expect(res.Code).To(Equal(http.StatusOK))
+			Expect(res.Header().Get("Content-Type")).To(Equal("application/zip"))
+
+			body := res.Body.Bytes()
+			reader, err := zip.NewReader(bytes.NewReader(body), int64(len(body)))
+			Expect(err).NotTo(HaveOccurred())
+
+			var names []string
+			for _, f := range reader.File {
+				names = append(names, f.Name)
+			}
+			Expect(names).To(ConsistOf("example.test/Repo1@v1.0.0/go.mod", "example.test/Repo1@v1.0.0/repo.go"))
+		})
+	})
+
+	Context("when the files of the version are not a valid module zip", func() {
+		BeforeEach(func() {
+			Expect(ioutil.WriteFile(filepath.Join(upstream, "REPO.go"), []byte("package repo\n"), 0644)).To(Succeed())
+			git("add", "-A")
+			git("commit", "--quiet", "-m", "collision")
+			git("tag", "v1.2.0")
+			path = "/example.test/!repo1/@v/v1.2.0.zip"
+		})
+
+		It("returns a 404 Not Found with the reason", func() {
+			Expect(res.Code).To(Equal(http.StatusNotFound))
+			Expect(res.Header().Get("Content-Type")).NotTo(Equal("application/zip"))
+			Expect(res.Body.String()).To(ContainSubstring("case-insensitive file name collision"))
+		})
+	})
+
+	Context("when requesting a version that does not exist", func() {
+		BeforeEach(func() {
+			path = "/example.test/!repo1/@v/v9.9.9.info"
+		})
+
+		It("returns a 404 Not Found", func() {
+			Expect(res.Code).To(Equal(http.StatusNotFound))
+		})
+	})
+
+	Context("when the upstream repository cannot be fetched", func() {
+		BeforeEach(func() {
+			locationCache.Add("Broken", filepath.Join(tmpDir, "missing"))
+			path = "/example.test/!broken/@v/list"
+		})
+
+		It("returns a 502 Bad Gateway without the details of the failure", func() {
+			Expect(res.Code).To(Equal(http.StatusBadGateway))
+			Expect(res.Body.String()).To(Equal("failed fetching the module from upstream\n"))
+		})
+	})
+
+	Context("when the module path is not escaped correctly", func() {
+		BeforeEach(func() {
+			path = "/example.test/Repo1/@v/list"
+		})
+
+		It("returns a 400 Bad Request", func() {
+			Expect(res.Code).To(Equal(http.StatusBadRequest))
+		})
+	})
+
+	Context("when the repo does not exist", func() {
+		BeforeEach(func() {
+			path = "/example.test/repo3/@v/list"
+		})
+
+		It("returns a 404 Not Found", func() {
+			Expect(res.Code).To(Equal(http.StatusNotFound))
+		})
+	})
+
+	Context("when the module path is not under the import prefix", func() {
+		BeforeEach(func() {
+			path = "/!repo1/@v/list"
+		})
+
+		It("returns a 404 Not Found", func() {
+			Expect(res.Code).To(Equal(http.StatusNotFound))
+		})
+	})
+
+	Context("when the case of the module path differs from the repo", func() {
+		BeforeEach(func() {
+			path = "/example.test/repo1/@v/list"
+		})
+
+		It("returns a 404 Not Found", func() {
+			Expect(res.Code).To(Equal(http.StatusNotFound))
+		})
+	})
+
+	Context("when the go command uses it as GOPROXY", func() {
+		BeforeEach(func() {
+			path = "/example.test/!repo1/@v/list"
+		})
+
+		It("resolves the module", func() {
+			goBin, err := exec.LookPath("go")
+			if err != nil {
+				Skip("the go command is not installed")
+			}
+
+			server := httptest.NewServer(http.HandlerFunc(handler.GetMeta))
+			defer server.Close()
+
+			gopath := filepath.Join(tmpDir, "gopath")
+			cmd := exec.Command(goBin, "list", "-m", "example.test/Repo1@latest")
+			cmd.Dir = tmpDir
+			cmd.Env = append(os.Environ(),
+				"GO111MODULE=on",
+				"GOENV=off",
+				"GOFLAGS=-modcacherw",
+				"GOPATH="+gopath,
+				"GOMODCACHE="+filepath.Join(gopath, "pkg", "mod"),
+				"GOPROXY="+server.URL,
+				"GONOPROXY=",
+				"GOPRIVATE=",
+				"GOSUMDB=off",
+				"GOTOOLCHAIN=local",
+			)
+			out, err := cmd.CombinedOutput()
+			Expect(err).NotTo(HaveOccurred(), string(out))
+			Expect(string(out)).To(Equal("example.test/Repo1 v1.1.0\n"))
+		})
+	})
+
+	Context("when the module is served by another module proxy", func() {
+		BeforeEach(func() {
+			cfg.ImportPrefix = "Example.test"
+			cfg.Overrides["legacy"] = config.Override{VCS: "mod", Repo: "https://proxy.example.com/"}
+			path = "/!example.test/legacy/v2/@v/v2.0.0.info"
+		})
+
+		It("redirects to the other module proxy", func() {
+			Expect(res.Code).To(Equal(http.StatusFound))
+			Expect(res.Header().Get("Location")).To(Equal("https://proxy.example.com/!example.test/legacy/v2/@v/v2.0.0.info"))
+		})
+	})
+
+	Context("when the module is in a repository that is not git", func() {
+		BeforeEach(func() {
+			cfg.Overrides["legacy"] = config.Override{VCS: "hg", Repo: "https://hg.example.com/legacy"}
+			path = "/example.test/legacy/@v/list"
+		})
+
+		It("returns a 404 Not Found", func() {
+			Expect(res.Code).To(Equal(http.StatusNotFound))
+		})
+	})
+
+	Context("when the module proxy is disabled", func() {
+		BeforeEach(func() {
+			moduleProxy = nil
+			path = "/example.test/!repo1/@v/list"
+		})
+
+		It("returns a 404 Not Found", func() {
+			Expect(res.Code).To(Equal(http.StatusNotFound))
+		})
+	})
+})
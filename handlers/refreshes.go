@@ -0,0 +1,84 @@
+package handlers
+
+import (
+	"net/http"
+	"time"
+
+	"code.cloudfoundry.org/lager"
+	"github.com/cloudfoundry/go-fetcher/cache"
+)
+
+type apiRefreshList struct {
+	Refreshes []apiRefresh `json:"refreshes"`
+}
+
+// apiRefresh is what a refresh changed about the repos of a tenant.
+type apiRefresh struct {
+	At time.Time `json:"at"`
+	// Source is "refresh", "accepted-refresh" or "webhook", in which case
+	// Action is the action of the repository event.
+	Source  string          `json:"source"`
+	Action  string          `json:"action,omitempty"`
+	Added   []apiRepoChange `json:"added"`
+	Removed []apiRepoChange `json:"removed"`
+	// Relocated lists the repos whose location changed, and OrgChanged those
+	// now found in another org.
+	Relocated  []apiRepoChange `json:"relocated"`
+	OrgChanged []apiRepoChange `json:"org_changed"`
+}
+
+type apiRepoChange struct {
+	Name             string `json:"name"`
+	Location         string `json:"location,omitempty"`
+	Org              string `json:"org,omitempty"`
+	PreviousLocation string `json:"previous_location,omitempty"`
+	PreviousOrg      string `json:"previous_org,omitempty"`
+}
+
+// ListRefreshes serves GET /-/api/v1/refreshes, the changes of the last
+// refreshes and webhook events of the tenant that changed its repos, newest
+// first. The repo query parameter limits them to the refreshes that changed
+// that repo.
+func (h *Handler) ListRefreshes(writer http.ResponseWriter, request *http.Request) {
+	logger := h.logger.Session("handler.listrefreshes", lager.Data{"host": request.Host, "query": request.URL.RawQuery})
+
+	tenant, ok := h.tenant(request.Host)
+	if !ok || tenant.loader == nil {
+		writeJSON(logger, writer, http.StatusNotFound, apiError{Error: "unknown host"})
+		return
+	}
+
+	repo := request.URL.Query().Get("repo")
+	list := apiRefreshList{Refreshes: []apiRefresh{}}
+	for _, diff := range tenant.loader.Diffs() {
+		if repo != "" && !diff.Involves(repo) {
+			continue
+		}
+		list.Refreshes = append(list.Refreshes, apiRefresh{
+			At:         diff.At.UTC(),
+			Source:     diff.Source,
+			Action:     diff.Action,
+			Added:      newAPIRepoChanges(diff.Added, false),
+			Removed:    newAPIRepoChanges(diff.Removed, false),
+			Relocated:  newAPIRepoChanges(diff.Relocated, true),
+			OrgChanged: newAPIRepoChanges(diff.OrgChanged, true),
+		})
+	}
+
+	writeJSON(logger, writer, http.StatusOK, list)
+}
+
+// newAPIRepoChanges converts the changes, with where the repos were before
+// if previous is set.
+func newAPIRepoChanges(changes []cache.RepoChange, previous bool) []apiRepoChange {
+	converted := []apiRepoChange{}
+	for _, change := range changes {
+		c := apiRepoChange{Name: change.Name, Location: change.Location, Org: change.Org}
+		if previous {
+			c.PreviousLocation = change.PreviousLocation
+			c.PreviousOrg = change.PreviousOrg
+		}
+		converted = append(converted, c)
+	}
+	return converted
+}
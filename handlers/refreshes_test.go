@@ -0,0 +1,97 @@
+package handlers_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"time"
+
+	"code.cloudfoundry.org/lager/lagertest"
+	"github.com/cloudfoundry/go-fetcher/cache"
+	"github.com/cloudfoundry/go-fetcher/cache/fakes"
+	"github.com/cloudfoundry/go-fetcher/config"
+	"github.com/cloudfoundry/go-fetcher/handlers"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("GET /-/api/v1/refreshes", func() {
+	var (
+		loader  *fakeLoader
+		handler *handlers.Handler
+	)
+
+	list := func(url string) *httptest.ResponseRecorder {
+		req, err := http.NewRequest("GET", url, nil)
+		Expect(err).NotTo(HaveOccurred())
+		res := httptest.NewRecorder()
+		handler.ListRefreshes(res, req)
+		return res
+	}
+
+	BeforeEach(func() {
+		at := time.Date(2019, 6, 1, 12, 0, 0, 0, time.UTC)
+		loader = &fakeLoader{diffs: []cache.RefreshDiff{
+			{
+				At:     at.Add(10 * time.Minute),
+				Source: cache.DiffSourceWebhook,
+				Action: "transferred",
+				Relocated: []cache.RepoChange{
+					{Name: "repo1", Location: "https://github.com/org2/repo1", Org: "org2", PreviousLocation: "https://github.com/org1/repo1", PreviousOrg: "org1"},
+				},
+				OrgChanged: []cache.RepoChange{
+					{Name: "repo1", Location: "https://github.com/org2/repo1", Org: "org2", PreviousLocation: "https://github.com/org1/repo1", PreviousOrg: "org1"},
+				},
+			},
+			{
+				At:      at,
+				Source:  cache.DiffSourceRefresh,
+				Added:   []cache.RepoChange{{Name: "repo2", Location: "https://github.com/org1/repo2", Org: "org1"}},
+				Removed: []cache.RepoChange{{Name: "repo3", Location: "https://github.com/org1/repo3", Org: "org1"}},
+			},
+		}}
+		handler = handlers.NewHandler(lagertest.NewTestLogger("test"), config.Config{ImportPrefix: "import-prefix"}, map[string]cache.Store{"": &fakes.FakeStore{}}, map[string]handlers.Loader{"": loader}, nil)
+	})
+
+	It("lists the changes of the last refreshes and webhook events", func() {
+		res := list("/-/api/v1/refreshes")
+
+		Expect(res.Code).To(Equal(http.StatusOK))
+		Expect(res.Body.String()).To(MatchJSON(`{"refreshes": [
+			{
+				"at": "2019-06-01T12:10:00Z",
+				"source": "webhook",
+				"action": "transferred",
+				"added": [],
+				"removed": [],
+				"relocated": [{"name": "repo1", "location": "https://github.com/org2/repo1", "org": "org2", "previous_location": "https://github.com/org1/repo1", "previous_org": "org1"}],
+				"org_changed": [{"name": "repo1", "location": "https://github.com/org2/repo1", "org": "org2", "previous_location": "https://github.com/org1/repo1", "previous_org": "org1"}]
+			},
+			{
+				"at": "2019-06-01T12:00:00Z",
+				"source": "refresh",
+				"added": [{"name": "repo2", "location": "https://github.com/org1/repo2", "org": "org1"}],
+				"removed": [{"name": "repo3", "location": "https://github.com/org1/repo3", "org": "org1"}],
+				"relocated": [],
+				"org_changed": []
+			}
+		]}`))
+	})
+
+	It("filters the refreshes by repo", func() {
+		res := list("/-/api/v1/refreshes?repo=repo3")
+		Expect(res.Code).To(Equal(http.StatusOK))
+		Expect(res.Body.String()).To(ContainSubstring(`"at":"2019-06-01T12:00:00Z"`))
+		Expect(res.Body.String()).NotTo(ContainSubstring("12:10:00"))
+
+		res = list("/-/api/v1/refreshes?repo=unknown")
+		Expect(res.Body.String()).To(MatchJSON(`{"refreshes": []}`))
+	})
+
+	It("returns a 404 Not Found for hosts without a loader", func() {
+		handler = handlers.NewHandler(lagertest.NewTestLogger("test"), config.Config{ImportPrefix: "import-prefix"}, map[string]cache.Store{"": &fakes.FakeStore{}}, nil, nil)
+
+		res := list("/-/api/v1/refreshes")
+		Expect(res.Code).To(Equal(http.StatusNotFound))
+		Expect(res.Body.String()).To(MatchJSON(`{"error": "unknown host"}`))
+	})
+})
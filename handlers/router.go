@@ -0,0 +1,125 @@
+package handlers
+
+import (
+	"strings"
+
+	"code.cloudfoundry.org/lager"
+	"github.com/cloudfoundry/go-fetcher/cache"
+	"github.com/cloudfoundry/go-fetcher/config"
+)
+
+// maxRootSegments is how many segments of a request path are tried as a
+// vanity root, unless an override is nested deeper, so that routing does not
+// get slower with the length of the path.
+const maxRootSegments = 8
+
+// match is the result of routing a request path to a repo.
+type match struct {
+	// RepoName is the matched vanity root below the import prefix, which may
+	// span several path segments.
+	RepoName string
+	// Requested is the vanity root as it was requested, which may differ in
+	// case from RepoName.
+	Requested string
+	// Subpath is the rest of the request path below the vanity root.
+	Subpath string
+	// Version is taken from an @<version> suffix of the vanity root.
+	Version string
+	Entry   cache.Entry
+}
+
+// route finds the longest prefix of a request path of the form
+// /<repo>[@<version>][/<subpath>] that is a known repo, so that nested vanity
+// roots such as tools/linter and tools can coexist. Overrides win over cache
+// entries for the same prefix, and exact matches over ones that differ in
+// case. Expired repos are unknown if the staleness policy drops them. Only
+// the first rootSegments segments are tried.
+func (t *tenant) route(logger lager.Logger, requestPath string) (match, bool) {
+	segments := strings.Split(strings.Trim(requestPath, "/"), "/")
+
+	depth := len(segments)
+	if depth > t.rootSegments {
+		depth = t.rootSegments
+	}
+	for i := depth; i > 0; i-- {
+		root := make([]string, i)
+		copy(root, segments[:i])
+
+		version := ""
+		if j := strings.Index(root[i-1], "@"); j >= 0 {
+			root[i-1], version = root[i-1][:j], root[i-1][j+1:]
+		}
+
+		repoName := strings.Join(root, "/")
+		if repoName == "" || strings.Contains(repoName, "//") {
+			continue
+		}
+
+		if name, entry, ok := t.lookup(logger, repoName); ok {
+			if t.staleness.DropExpired && t.freshness(entry) == expired {
+				logger.Info("dropping-expired-repo", lager.Data{"repo": name, "age": entry.Age.String()})
+				continue
+			}
+			return match{
+				RepoName:  name,
+				Requested: repoName,
+				Subpath:   strings.Trim(strings.Join(segments[i:], "/"), "/"),
+				Version:   version,
+				Entry:     entry,
+			}, true
+		}
+	}
+
+	return match{}, false
+}
+
+// lookup resolves a repo name through the overrides first and then the store
+// of the tenant, falling back to case-insensitive matches. It returns the
+// name the repo is known under.
+func (t *tenant) lookup(logger lager.Logger, repoName string) (string, cache.Entry, bool) {
+	if override, ok := t.config.Overrides[repoName]; ok && override.Repo != "" {
+		logger.Debug("override", lager.Data{"location": override.Repo, "subdir": override.Subdir})
+		return repoName, overrideEntry(override), true
+	}
+
+	var (
+		stored      string
+		storedEntry cache.Entry
+		found       bool
+	)
+	if t.store != nil {
+		stored, storedEntry, found = t.store.Lookup(repoName)
+	}
+	if found && stored == repoName {
+		logger.Debug("cache-hit", lager.Data{"location": storedEntry.Location})
+		return repoName, storedEntry, true
+	}
+
+	if name, ok := t.foldedOverrides[strings.ToLower(repoName)]; ok {
+		override := t.config.Overrides[name]
+		logger.Debug("override-case-mismatch", lager.Data{"location": override.Repo, "canonical": name})
+		return name, overrideEntry(override), true
+	}
+
+	if found {
+		logger.Debug("cache-hit-case-mismatch", lager.Data{"location": storedEntry.Location, "canonical": stored})
+		return stored, storedEntry, true
+	}
+
+	return "", cache.Entry{}, false
+}
+
+func overrideEntry(override config.Override) cache.Entry {
+	forge := cache.Forge(override.Forge)
+	if forge == "" {
+		forge = cache.DetectForge(override.Repo)
+	}
+
+	return cache.Entry{
+		Location:      override.Repo,
+		VCS:           override.GetVCS(),
+		Subdir:        override.Subdir,
+		Forge:         forge,
+		DefaultBranch: override.DefaultBranch,
+	}
+}
@@ -0,0 +1,136 @@
+package handlers_test
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+
+	"code.cloudfoundry.org/clock"
+	"code.cloudfoundry.org/lager/lagertest"
+	"github.com/cloudfoundry/go-fetcher/cache"
+	"github.com/cloudfoundry/go-fetcher/cache/fakes"
+	"github.com/cloudfoundry/go-fetcher/config"
+	"github.com/cloudfoundry/go-fetcher/handlers"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/ginkgo/extensions/table"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Routing", func() {
+	var handler *handlers.Handler
+
+	BeforeEach(func() {
+		cfg := config.Config{
+			ImportPrefix: "import-prefix",
+			Overrides: map[string]config.Override{
+				"tools":                {Repo: "https://example.com/tools"},
+				"tools/linter":         {Repo: "https://example.com/tools-linter"},
+				"services/linter":      {Repo: "https://example.com/services-linter"},
+				"services/linter/v2":   {Repo: "https://example.com/services-linter-v2"},
+				"shadowed":             {Repo: "https://example.com/shadowed-override"},
+				"deep/1/2/3/4/5/6/7/8": {Repo: "https://example.com/deep"},
+			},
+		}
+
+		locationCache := cache.NewLocationCache(lagertest.NewTestLogger("cache"), clock.NewClock())
+		locationCache.Add("repo1", "https://example.com/repo1")
+		locationCache.Add("shadowed", "https://example.com/shadowed-cache")
+		locationCache.Add("services", "https://example.com/services")
+		locationCache.Add("Routing-API", "https://example.com/Routing-API")
+
+		handler = handlers.NewHandler(lagertest.NewTestLogger("test"), cfg, map[string]cache.Store{"": locationCache}, nil, nil)
+	})
+
+	DescribeTable("sets the go-import root to the longest matching vanity root",
+		func(path, expectedRoot, expectedLocation string) {
+			req, err := http.NewRequest("GET", path+"?go-get=1", nil)
+			Expect(err).NotTo(HaveOccurred())
+			res := httptest.NewRecorder()
+			handler.GetMeta(res, req)
+
+			Expect(res.Code).To(Equal(http.StatusOK))
+			Expect(res.Body.String()).To(ContainSubstring(fmt.Sprintf(
+				`<meta name="go-import" content="import-prefix/%s git %s">`, expectedRoot, expectedLocation)))
+		},
+		Entry("a single segment root from the cache", "/repo1", "repo1", "https://example.com/repo1"),
+		Entry("a package below a single segment root", "/repo1/pkg/sub", "repo1", "https://example.com/repo1"),
+		Entry("a multi-segment root", "/tools/linter", "tools/linter", "https://example.com/tools-linter"),
+		Entry("a package below a multi-segment root", "/tools/linter/cmd/lint", "tools/linter", "https://example.com/tools-linter"),
+		Entry("the shorter of two nested roots", "/tools/formatter", "tools", "https://example.com/tools"),
+		Entry("a root with the same last segment under another parent", "/services/linter", "services/linter", "https://example.com/services-linter"),
+		Entry("a nested override below a cache entry", "/services/linter/v2/pkg", "services/linter/v2", "https://example.com/services-linter-v2"),
+		Entry("a cache entry that is a prefix of overrides", "/services/other", "services", "https://example.com/services"),
+		Entry("an override and a cache entry for the same root", "/shadowed/pkg", "shadowed", "https://example.com/shadowed-override"),
+		Entry("a root with a version", "/tools/linter@v1.0.0/cmd", "tools/linter", "https://example.com/tools-linter"),
+		Entry("a deeply nested override", "/deep/1/2/3/4/5/6/7/8/pkg", "deep/1/2/3/4/5/6/7/8", "https://example.com/deep"),
+	)
+
+	DescribeTable("returns a 404 Not Found when no prefix matches",
+		func(path string) {
+			req, err := http.NewRequest("GET", path+"?go-get=1", nil)
+			Expect(err).NotTo(HaveOccurred())
+			res := httptest.NewRecorder()
+			handler.GetMeta(res, req)
+
+			Expect(res.Code).To(Equal(http.StatusNotFound))
+		},
+		Entry("an unknown root", "/unknown/linter"),
+		Entry("the nested part of a root on its own", "/linter"),
+	)
+
+	DescribeTable("redirects to the canonical path when the case of the root differs",
+		func(path, expectedLocation string) {
+			req, err := http.NewRequest("GET", path, nil)
+			Expect(err).NotTo(HaveOccurred())
+			res := httptest.NewRecorder()
+			handler.GetMeta(res, req)
+
+			Expect(res.Code).To(Equal(http.StatusMovedPermanently))
+			Expect(res.Header().Get("Location")).To(Equal(expectedLocation))
+		},
+		Entry("a cache entry", "/routing-api", "/Routing-API"),
+		Entry("a package below a cache entry", "/routing-api/cmd/Routing-API?go-get=1", "/Routing-API/cmd/Routing-API?go-get=1"),
+		Entry("a multi-segment override", "/Tools/Linter@v1.0.0/cmd", "/tools/linter@v1.0.0/cmd"),
+	)
+
+	Context("with another store", func() {
+		var fakeStore *fakes.FakeStore
+
+		BeforeEach(func() {
+			fakeStore = &fakes.FakeStore{}
+			fakeStore.LookupStub = func(repoName string) (string, cache.Entry, bool) {
+				if repoName == "other/repo" {
+					return "Other/Repo", cache.Entry{Location: "https://example.com/Other/Repo"}, true
+				}
+				return "", cache.Entry{}, false
+			}
+
+			cfg := config.Config{ImportPrefix: "import-prefix"}
+			handler = handlers.NewHandler(lagertest.NewTestLogger("test"), cfg, map[string]cache.Store{"": fakeStore}, nil, nil)
+		})
+
+		It("looks up the longest root in the store", func() {
+			req, err := http.NewRequest("GET", "/other/repo/pkg", nil)
+			Expect(err).NotTo(HaveOccurred())
+			res := httptest.NewRecorder()
+			handler.GetMeta(res, req)
+
+			Expect(res.Code).To(Equal(http.StatusMovedPermanently))
+			Expect(res.Header().Get("Location")).To(Equal("/Other/Repo/pkg"))
+			Expect(fakeStore.LookupCallCount()).To(Equal(2))
+			Expect(fakeStore.LookupArgsForCall(0)).To(Equal("other/repo/pkg"))
+		})
+
+		It("only tries the first few segments of long paths", func() {
+			req, err := http.NewRequest("GET", strings.Repeat("/a", 50000), nil)
+			Expect(err).NotTo(HaveOccurred())
+			res := httptest.NewRecorder()
+			handler.GetMeta(res, req)
+
+			Expect(res.Code).To(Equal(http.StatusNotFound))
+			Expect(fakeStore.LookupCallCount()).To(BeNumerically("<=", 8))
+			Expect(fakeStore.LookupArgsForCall(0)).To(Equal(strings.TrimPrefix(strings.Repeat("/a", 8), "/")))
+		})
+	})
+})
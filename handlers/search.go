@@ -0,0 +1,224 @@
+package handlers
+
+import (
+	"html/template"
+	"net/http"
+	"sort"
+	"strings"
+
+	"code.cloudfoundry.org/lager"
+)
+
+const (
+	defaultSearchLimit = 20
+	maxSearchLimit     = 100
+	// maxQueryLength is the number of bytes of a query, or of the path of an
+	// unknown repo, that are compared with the repo names, which is more than
+	// the longest repo name GitHub allows.
+	maxQueryLength = 128
+)
+
+// The kinds of match of a search result, from the best to the worst.
+const (
+	matchExact = iota
+	matchPrefix
+	matchSubstring
+	matchDescription
+	matchFuzzy
+)
+
+var matchNames = []string{"exact", "prefix", "substring", "description", "fuzzy"}
+
+type searchResult struct {
+	Name        string `json:"name"`
+	ImportPath  string `json:"import_path"`
+	Location    string `json:"location"`
+	Description string `json:"description,omitempty"`
+	Match       string `json:"match"`
+
+	kind     int
+	distance int
+}
+
+type searchPage struct {
+	Query   string         `json:"query"`
+	Results []searchResult `json:"results"`
+}
+
+var searchTemplate = template.Must(template.New("search").Parse(`<!DOCTYPE html>
+<html>
+<head>
+<meta http-equiv="Content-Type" content="text/html; charset=utf-8">
+<title>Search{{if .Query}}: {{.Query}}{{end}}</title>
+</head>
+<body>
+<form action="/-/search">
+<input name="q" type="search" value="{{.Query}}" placeholder="Search packages" autofocus>
+<input type="submit" value="Search">
+</form>
+{{- if .Query}}
+{{- if .Results}}
+<ul>
+{{- range .Results}}
+<li><a href="{{.Location}}">{{.ImportPath}}</a>{{if .Description}} - {{.Description}}{{end}}</li>
+{{- end}}
+</ul>
+{{- else}}
+<p>No packages match {{.Query}}.</p>
+{{- end}}
+{{- end}}
+<p><a href="/">All packages</a></p>
+</body>
+</html>
+`))
+
+// Search serves GET /-/search?q=, as HTML or as JSON if the client accepts
+// it or asks for format=json. Results are ranked by whether the query is the
+// name, a prefix or a part of it, is part of the description, or is close to
+// the name by edit distance.
+func (h *Handler) Search(writer http.ResponseWriter, request *http.Request) {
+	query := request.URL.Query()
+	q := strings.TrimSpace(query.Get("q"))
+	if len(q) > maxQueryLength {
+		q = strings.ToValidUTF8(q[:maxQueryLength], "")
+	}
+	logger := h.logger.Session("handler.search", lager.Data{"host": request.Host, "query": q})
+
+	tenant, ok := h.tenant(request.Host)
+	if !ok {
+		writeJSON(logger, writer, http.StatusNotFound, apiError{Error: "unknown host"})
+		return
+	}
+
+	limit, err := queryInt(query.Get("limit"), defaultSearchLimit)
+	if err != nil || limit < 1 || limit > maxSearchLimit {
+		limit = defaultSearchLimit
+	}
+
+	page := searchPage{Query: q, Results: []searchResult{}}
+	if q != "" {
+		page.Results = search(tenant, q, limit)
+	}
+	logger.Debug("searched", lager.Data{"results": len(page.Results)})
+
+	if wantsJSON(request) {
+		writeJSON(logger, writer, http.StatusOK, page)
+		return
+	}
+
+	writer.Header().Set("Content-Type", "text/html; charset=utf-8")
+	if err := searchTemplate.Execute(writer, page); err != nil {
+		logger.Error("failed-rendering-search", err)
+	}
+}
+
+// search returns at most limit packages of the tenant matching q, best
+// matches first.
+func search(tenant *tenant, q string, limit int) []searchResult {
+	q = strings.ToLower(q)
+	maxDistance := len(q)/4 + 1
+
+	var results []searchResult
+	for _, p := range tenant.packages() {
+		name := strings.ToLower(p.Name)
+		result := searchResult{
+			Name:        p.Name,
+			ImportPath:  tenant.config.ImportPrefix + "/" + p.Name,
+			Location:    p.Entry.Location,
+			Description: p.Entry.Description,
+		}
+
+		switch {
+		case name == q:
+			result.kind = matchExact
+		case strings.HasPrefix(name, q):
+			result.kind = matchPrefix
+		case strings.Contains(name, q):
+			result.kind = matchSubstring
+		case strings.Contains(strings.ToLower(p.Entry.Description), q):
+			result.kind = matchDescription
+		default:
+			distance, ok := editDistance(name, q, maxDistance)
+			if !ok {
+				continue
+			}
+			result.kind = matchFuzzy
+			result.distance = distance
+		}
+		result.Match = matchNames[result.kind]
+		results = append(results, result)
+	}
+
+	sort.SliceStable(results, func(i, j int) bool {
+		if results[i].kind != results[j].kind {
+			return results[i].kind < results[j].kind
+		}
+		if results[i].distance != results[j].distance {
+			return results[i].distance < results[j].distance
+		}
+		return len(results[i].Name) < len(results[j].Name)
+	})
+
+	if len(results) > limit {
+		results = results[:limit]
+	}
+	return results
+}
+
+// editDistance returns the number of insertions, deletions, substitutions
+// and transpositions of adjacent characters needed to turn a into b, and
+// false if that is more than max. It gives up as soon as every way of
+// turning the start of a into b takes more than max edits, and it only keeps
+// the last three rows of the matrix, which the transpositions need.
+func editDistance(a, b string, max int) (int, bool) {
+	ra, rb := []rune(a), []rune(b)
+	if diff := len(ra) - len(rb); diff > max || -diff > max {
+		return 0, false
+	}
+
+	prev2 := make([]int, len(rb)+1)
+	prev := make([]int, len(rb)+1)
+	cur := make([]int, len(rb)+1)
+	for j := range prev {
+		prev[j] = j
+	}
+
+	for i := 1; i <= len(ra); i++ {
+		cur[0] = i
+		rowMin := cur[0]
+		for j := 1; j <= len(rb); j++ {
+			cost := 1
+			if ra[i-1] == rb[j-1] {
+				cost = 0
+			}
+			cur[j] = minInt(prev[j]+1, cur[j-1]+1, prev[j-1]+cost)
+			if i > 1 && j > 1 && ra[i-1] == rb[j-2] && ra[i-2] == rb[j-1] {
+				cur[j] = minInt(cur[j], prev2[j-2]+1)
+			}
+			rowMin = minInt(rowMin, cur[j])
+		}
+		if rowMin > max {
+			return 0, false
+		}
+		prev2, prev, cur = prev, cur, prev2
+	}
+
+	distance := prev[len(rb)]
+	return distance, distance <= max
+}
+
+func minInt(values ...int) int {
+	min := values[0]
+	for _, v := range values[1:] {
+		if v < min {
+			min = v
+		}
+	}
+	return min
+}
+
+// wantsJSON reports whether the client asked for a JSON response.
+func wantsJSON(request *http.Request) bool {
+	return request.URL.Query().Get("format") == "json" ||
+		strings.Contains(request.Header.Get("Accept"), "application/json")
+}
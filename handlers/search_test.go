@@ -0,0 +1,119 @@
+package handlers_test
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+
+	"code.cloudfoundry.org/clock"
+	"code.cloudfoundry.org/lager/lagertest"
+	"github.com/cloudfoundry/go-fetcher/cache"
+	"github.com/cloudfoundry/go-fetcher/config"
+	"github.com/cloudfoundry/go-fetcher/handlers"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/ginkgo/extensions/table"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Search", func() {
+	var handler *handlers.Handler
+
+	type result struct {
+		Name  string `json:"name"`
+		Match string `json:"match"`
+	}
+
+	search := func(query string) []result {
+		req, err := http.NewRequest("GET", "/-/search"+query, nil)
+		Expect(err).NotTo(HaveOccurred())
+		req.Header.Set("Accept", "application/json")
+		res := httptest.NewRecorder()
+		handler.Search(res, req)
+		Expect(res.Code).To(Equal(http.StatusOK))
+		Expect(res.Header().Get("Content-Type")).To(Equal("application/json"))
+
+		var page struct {
+			Results []result `json:"results"`
+		}
+		Expect(json.Unmarshal(res.Body.Bytes(), &page)).To(Succeed())
+		return page.Results
+	}
+
+	BeforeEach(func() {
+		cfg := config.Config{
+			ImportPrefix: "import-prefix",
+			Overrides: map[string]config.Override{
+				"tools/linter": {Repo: "https://example.com/linter"},
+			},
+		}
+
+		locationCache := cache.NewLocationCache(lagertest.NewTestLogger("cache"), clock.NewClock())
+		for name, description := range map[string]string{
+			"bbs":           "The Diego bulletin board",
+			"bbs-client":    "",
+			"diego-release": "BOSH release for Diego",
+			"lager":         "A structured logger",
+			"clock":         "",
+		} {
+			locationCache.Upsert(name, cache.Entry{Location: "https://github.com/org/" + name, Description: description})
+		}
+
+		handler = handlers.NewHandler(lagertest.NewTestLogger("test"), cfg, map[string]cache.Store{"": locationCache}, nil, nil)
+	})
+
+	It("ranks exact, prefix, substring, description and fuzzy matches in that order", func() {
+		Expect(search("?q=bbs")).To(Equal([]result{
+			{Name: "bbs", Match: "exact"},
+			{Name: "bbs-client", Match: "prefix"},
+		}))
+		Expect(search("?q=DIEGO")).To(Equal([]result{
+			{Name: "diego-release", Match: "prefix"},
+			{Name: "bbs", Match: "description"},
+		}))
+	})
+
+	DescribeTable("finds packages",
+		func(query string, expected ...result) {
+			Expect(search(query)).To(Equal(expected))
+		},
+		Entry("by part of a nested name", "?q=linter", result{Name: "tools/linter", Match: "substring"}),
+		Entry("with a typo", "?q=lagre", result{Name: "lager", Match: "fuzzy"}),
+		Entry("by description", "?q=logger", result{Name: "lager", Match: "description"}),
+		Entry("up to the limit", "?q=bbs&limit=1", result{Name: "bbs", Match: "exact"}),
+		Entry("for format=json", "?q=clock&format=json", result{Name: "clock", Match: "exact"}),
+	)
+
+	It("returns no results for an empty query", func() {
+		Expect(search("?q=")).To(BeEmpty())
+	})
+
+	It("only searches for the start of long queries", func() {
+		req, err := http.NewRequest("GET", "/-/search?format=json&q=lager"+strings.Repeat("x", 512*1024), nil)
+		Expect(err).NotTo(HaveOccurred())
+		res := httptest.NewRecorder()
+		handler.Search(res, req)
+		Expect(res.Code).To(Equal(http.StatusOK))
+
+		var page struct {
+			Query   string   `json:"query"`
+			Results []result `json:"results"`
+		}
+		Expect(json.Unmarshal(res.Body.Bytes(), &page)).To(Succeed())
+		Expect(page.Query).To(HaveLen(128))
+		Expect(page.Query).To(HavePrefix("lagerxxx"))
+		Expect(page.Results).To(BeEmpty())
+	})
+
+	It("renders HTML for browsers", func() {
+		req, err := http.NewRequest("GET", "/-/search?q=lager", nil)
+		Expect(err).NotTo(HaveOccurred())
+		res := httptest.NewRecorder()
+		handler.Search(res, req)
+
+		Expect(res.Code).To(Equal(http.StatusOK))
+		Expect(res.Header().Get("Content-Type")).To(Equal("text/html; charset=utf-8"))
+		Expect(res.Body.String()).To(ContainSubstring(`<input name="q" type="search" value="lager"`))
+		Expect(res.Body.String()).To(ContainSubstring(`<li><a href="https://github.com/org/lager">import-prefix/lager</a> - A structured logger</li>`))
+	})
+})
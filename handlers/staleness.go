@@ -0,0 +1,53 @@
+package handlers
+
+import (
+	"net/http"
+
+	"code.cloudfoundry.org/lager"
+	"github.com/cloudfoundry/go-fetcher/cache"
+)
+
+// freshness is how up to date a repo is under the staleness policy.
+type freshness int
+
+const (
+	fresh freshness = iota
+	stale
+	expired
+)
+
+func (f freshness) String() string {
+	switch f {
+	case stale:
+		return "stale"
+	case expired:
+		return "expired"
+	}
+	return "fresh"
+}
+
+// freshness classifies an entry by its age. Overrides have no update time,
+// so they are always fresh.
+func (t *tenant) freshness(entry cache.Entry) freshness {
+	policy := t.staleness
+	switch {
+	case policy.MaxAge == 0 || entry.UpdatedAt.IsZero() || entry.Age <= policy.MaxAge:
+		return fresh
+	case entry.Age <= policy.MaxAge+policy.StaleWhileError:
+		return stale
+	}
+	return expired
+}
+
+// warnIfStale flags responses about repos that are out of date with a
+// Warning header, as caches do when they serve stale responses.
+func (t *tenant) warnIfStale(logger lager.Logger, writer http.ResponseWriter, entry cache.Entry) {
+	switch t.freshness(entry) {
+	case stale:
+		logger.Info("serving-stale-repo", lager.Data{"age": entry.Age.String()})
+		writer.Header().Set("Warning", `110 go-fetcher "Response is Stale"`)
+	case expired:
+		logger.Info("serving-expired-repo", lager.Data{"age": entry.Age.String()})
+		writer.Header().Set("Warning", `111 go-fetcher "Revalidation Failed"`)
+	}
+}
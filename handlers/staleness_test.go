@@ -0,0 +1,122 @@
+package handlers_test
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"time"
+
+	"code.cloudfoundry.org/clock/fakeclock"
+	"code.cloudfoundry.org/lager/lagertest"
+	"github.com/cloudfoundry/go-fetcher/cache"
+	"github.com/cloudfoundry/go-fetcher/config"
+	"github.com/cloudfoundry/go-fetcher/handlers"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Staleness", func() {
+	var (
+		clock         *fakeclock.FakeClock
+		cfg           config.Config
+		locationCache *cache.LocationCache
+		handler       *handlers.Handler
+	)
+
+	get := func(path string) *httptest.ResponseRecorder {
+		req, err := http.NewRequest("GET", path, nil)
+		Expect(err).NotTo(HaveOccurred())
+		res := httptest.NewRecorder()
+		if path == "/-/status" {
+			handler.Status(res, req)
+		} else {
+			handler.GetMeta(res, req)
+		}
+		return res
+	}
+
+	BeforeEach(func() {
+		clock = fakeclock.NewFakeClock(time.Date(2019, 6, 1, 12, 0, 0, 0, time.UTC))
+		cfg = config.Config{
+			ImportPrefix:    "import-prefix",
+			Overrides:       map[string]config.Override{"override": {Repo: "https://example.com/override"}},
+			MaxAge:          "1h",
+			StaleWhileError: "2h",
+		}
+
+		locationCache = cache.NewLocationCache(lagertest.NewTestLogger("cache"), clock)
+		locationCache.Add("old", "https://github.com/org/old")
+		clock.Increment(90 * time.Minute)
+		locationCache.Add("recent", "https://github.com/org/recent")
+	})
+
+	JustBeforeEach(func() {
+		handler = handlers.NewHandler(lagertest.NewTestLogger("test"), cfg, map[string]cache.Store{"": locationCache}, nil, nil)
+	})
+
+	It("serves fresh repos and overrides without a warning", func() {
+		res := get("/recent?go-get=1")
+		Expect(res.Code).To(Equal(http.StatusOK))
+		Expect(res.Header().Get("Warning")).To(BeEmpty())
+
+		clock.Increment(24 * time.Hour)
+		res = get("/override?go-get=1")
+		Expect(res.Code).To(Equal(http.StatusOK))
+		Expect(res.Header().Get("Warning")).To(BeEmpty())
+	})
+
+	It("serves stale repos with a warning", func() {
+		res := get("/old?go-get=1")
+		Expect(res.Code).To(Equal(http.StatusOK))
+		Expect(res.Header().Get("Warning")).To(Equal(`110 go-fetcher "Response is Stale"`))
+	})
+
+	Context("when repos are older than MaxAge plus StaleWhileError", func() {
+		BeforeEach(func() {
+			clock.Increment(2 * time.Hour)
+		})
+
+		It("serves them with a warning by default", func() {
+			res := get("/old?go-get=1")
+			Expect(res.Code).To(Equal(http.StatusOK))
+			Expect(res.Header().Get("Warning")).To(Equal(`111 go-fetcher "Revalidation Failed"`))
+		})
+
+		Context("when expired repos are dropped", func() {
+			BeforeEach(func() {
+				cfg.ExpiredRepos = config.ExpiredReposDrop
+			})
+
+			It("does not serve them", func() {
+				Expect(get("/old?go-get=1").Code).To(Equal(http.StatusNotFound))
+				Expect(get("/recent?go-get=1").Code).To(Equal(http.StatusOK))
+			})
+		})
+
+		It("reports the out of date repos in the status", func() {
+			var s struct {
+				Tenants []struct {
+					OldestRepoAgeSeconds float64 `json:"oldest_repo_age_seconds"`
+					StaleRepos           int     `json:"stale_repos"`
+					ExpiredRepos         int     `json:"expired_repos"`
+				} `json:"tenants"`
+			}
+			Expect(json.Unmarshal(get("/-/status").Body.Bytes(), &s)).To(Succeed())
+			Expect(s.Tenants).To(HaveLen(1))
+			Expect(s.Tenants[0].OldestRepoAgeSeconds).To(Equal((3*time.Hour + 30*time.Minute).Seconds()))
+			Expect(s.Tenants[0].StaleRepos).To(Equal(1))
+			Expect(s.Tenants[0].ExpiredRepos).To(Equal(1))
+		})
+
+		It("lists the freshness of each repo in the JSON API", func() {
+			req, err := http.NewRequest("GET", "/-/api/v1/repos?freshness=expired", nil)
+			Expect(err).NotTo(HaveOccurred())
+			res := httptest.NewRecorder()
+			handler.ListRepos(res, req)
+
+			Expect(res.Body.String()).To(ContainSubstring(`"name":"old"`))
+			Expect(res.Body.String()).To(ContainSubstring(`"age_seconds":12600`))
+			Expect(res.Body.String()).NotTo(ContainSubstring(`"name":"recent"`))
+		})
+	})
+})
@@ -0,0 +1,176 @@
+package handlers
+
+import (
+	"net/http"
+	"sort"
+	"time"
+
+	"code.cloudfoundry.org/lager"
+)
+
+type status struct {
+	Tenants []tenantStatus `json:"tenants"`
+}
+
+type tenantStatus struct {
+	Host         string `json:"host"`
+	ImportPrefix string `json:"import_prefix"`
+	Repos        int    `json:"repos"`
+	// RefreshedAt is when the repos of the orgs were fetched, which may be
+	// before the last restart if they were loaded from the CacheFile.
+	RefreshedAt        *time.Time `json:"refreshed_at,omitempty"`
+	SnapshotAgeSeconds *float64   `json:"snapshot_age_seconds,omitempty"`
+	// OldestRepoAgeSeconds is the age of the least recently updated repo.
+	OldestRepoAgeSeconds *float64 `json:"oldest_repo_age_seconds,omitempty"`
+	// StaleRepos and ExpiredRepos count the repos that are out of date
+	// under the staleness policy, e.g. because refreshes keep failing.
+	StaleRepos   int `json:"stale_repos"`
+	ExpiredRepos int `json:"expired_repos"`
+	// GithubRateLimit is the quota of the GitHub API as last reported to the
+	// cache loader.
+	GithubRateLimit *rateLimit `json:"github_rate_limit,omitempty"`
+	// RateLimitedUntil is set while the cache loader waits for the quota to
+	// be reset.
+	RateLimitedUntil *time.Time `json:"rate_limited_until,omitempty"`
+	// ConsecutiveFailures counts the refreshes that failed since the last
+	// successful one, LastRefreshError is the error of the last of them, and
+	// NextRefreshAt is when the cache loader tries again. The error is only
+	// reported to requests with the AdminToken, as are the names of the
+	// repos of the BlockedRefresh and the errors of the Orgs.
+	ConsecutiveFailures int        `json:"consecutive_failures,omitempty"`
+	LastRefreshError    string     `json:"last_refresh_error,omitempty"`
+	NextRefreshAt       *time.Time `json:"next_refresh_at,omitempty"`
+	// Orgs reports the state of each org, as the repos of failed orgs are
+	// kept from their last successful fetch.
+	Orgs []orgStatus `json:"orgs,omitempty"`
+	// BlockedRefresh is the last refresh if it would have removed too many
+	// repos, until it is accepted or a later refresh is stored.
+	BlockedRefresh *blockedRefresh `json:"blocked_refresh,omitempty"`
+}
+
+type blockedRefresh struct {
+	ID           string    `json:"id"`
+	BlockedAt    time.Time `json:"blocked_at"`
+	Repos        int       `json:"repos"`
+	RemovedRepos int       `json:"removed_repos"`
+	AddedRepos   int       `json:"added_repos"`
+	Removed      []string  `json:"removed,omitempty"`
+	Added        []string  `json:"added,omitempty"`
+}
+
+type orgStatus struct {
+	Org                 string     `json:"org"`
+	Repos               int        `json:"repos"`
+	LastSuccessAt       *time.Time `json:"last_success_at,omitempty"`
+	ConsecutiveFailures int        `json:"consecutive_failures"`
+	LastError           string     `json:"last_error,omitempty"`
+}
+
+type rateLimit struct {
+	Limit     int       `json:"limit"`
+	Remaining int       `json:"remaining"`
+	Reset     time.Time `json:"reset"`
+}
+
+// Status serves GET /-/status, a JSON report of the state of the location
+// cache of every tenant and of the loader keeping it up to date. Errors and
+// the names of the repos of blocked refreshes are left out unless the request
+// carries the AdminToken as a bearer token.
+func (h *Handler) Status(writer http.ResponseWriter, request *http.Request) {
+	logger := h.logger.Session("handler.status")
+
+	detailed := h.config.AdminToken != "" && validToken(h.config.AdminToken, request.Header.Get("Authorization"))
+	s := status{Tenants: []tenantStatus{}}
+	for _, t := range h.tenants {
+		s.Tenants = append(s.Tenants, t.status(detailed))
+	}
+	sort.Slice(s.Tenants, func(i, j int) bool {
+		return s.Tenants[i].Host < s.Tenants[j].Host
+	})
+
+	logger.Debug("status", lager.Data{"tenants": len(s.Tenants), "detailed": detailed})
+	writeJSON(logger, writer, http.StatusOK, s)
+}
+
+// status reports the state of the tenant, with the errors and the names of
+// the repos of a blocked refresh if detailed is set.
+func (t *tenant) status(detailed bool) tenantStatus {
+	s := tenantStatus{
+		Host:         t.config.Host,
+		ImportPrefix: t.config.ImportPrefix,
+	}
+	if t.loader != nil {
+		loaderStatus := t.loader.Status()
+		if rate := loaderStatus.RateLimit; rate != nil {
+			s.GithubRateLimit = &rateLimit{Limit: rate.Limit, Remaining: rate.Remaining, Reset: rate.Reset.UTC()}
+		}
+		if !loaderStatus.RateLimitedUntil.IsZero() {
+			until := loaderStatus.RateLimitedUntil.UTC()
+			s.RateLimitedUntil = &until
+		}
+		s.ConsecutiveFailures = loaderStatus.ConsecutiveFailures
+		if detailed {
+			s.LastRefreshError = loaderStatus.LastError
+		}
+		if !loaderStatus.NextRefresh.IsZero() {
+			next := loaderStatus.NextRefresh.UTC()
+			s.NextRefreshAt = &next
+		}
+		for _, org := range loaderStatus.Orgs {
+			o := orgStatus{
+				Org:                 org.Org,
+				Repos:               org.Repos,
+				ConsecutiveFailures: org.ConsecutiveFailures,
+			}
+			if detailed {
+				o.LastError = org.LastError
+			}
+			if !org.LastSuccess.IsZero() {
+				lastSuccess := org.LastSuccess.UTC()
+				o.LastSuccessAt = &lastSuccess
+			}
+			s.Orgs = append(s.Orgs, o)
+		}
+		if blocked := loaderStatus.Blocked; blocked != nil {
+			s.BlockedRefresh = &blockedRefresh{
+				ID:           blocked.ID,
+				BlockedAt:    blocked.BlockedAt.UTC(),
+				Repos:        blocked.Repos,
+				RemovedRepos: len(blocked.Removed),
+				AddedRepos:   len(blocked.Added),
+			}
+			if detailed {
+				s.BlockedRefresh.Removed = blocked.Removed
+				s.BlockedRefresh.Added = blocked.Added
+			}
+		}
+	}
+	if t.store == nil {
+		return s
+	}
+
+	stats := t.store.Stats()
+	s.Repos = stats.Repos
+	if !stats.RefreshedAt.IsZero() {
+		refreshedAt := stats.RefreshedAt.UTC()
+		seconds := stats.Age.Seconds()
+		s.RefreshedAt = &refreshedAt
+		s.SnapshotAgeSeconds = &seconds
+	}
+	if stats.OldestEntryAge > 0 {
+		seconds := stats.OldestEntryAge.Seconds()
+		s.OldestRepoAgeSeconds = &seconds
+	}
+
+	if t.staleness.MaxAge > 0 {
+		for _, repo := range t.store.List() {
+			switch t.freshness(repo.Entry) {
+			case stale:
+				s.StaleRepos++
+			case expired:
+				s.ExpiredRepos++
+			}
+		}
+	}
+	return s
+}
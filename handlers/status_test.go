@@ -0,0 +1,198 @@
+package handlers_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"time"
+
+	"code.cloudfoundry.org/clock/fakeclock"
+	"code.cloudfoundry.org/lager"
+	"code.cloudfoundry.org/lager/lagertest"
+	"github.com/cloudfoundry/go-fetcher/cache"
+	"github.com/cloudfoundry/go-fetcher/cache/fakes"
+	"github.com/cloudfoundry/go-fetcher/config"
+	"github.com/cloudfoundry/go-fetcher/handlers"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+type fakeLoader struct {
+	status cache.LoaderStatus
+
+	acceptedID string
+	accepted   cache.BlockedRefresh
+	acceptErr  error
+
+	diffs []cache.RefreshDiff
+
+	events []cache.RepositoryEvent
+}
+
+func (l *fakeLoader) Status() cache.LoaderStatus {
+	return l.status
+}
+
+func (l *fakeLoader) AcceptBlocked(id string) (cache.BlockedRefresh, error) {
+	l.acceptedID = id
+	return l.accepted, l.acceptErr
+}
+
+func (l *fakeLoader) Diffs() []cache.RefreshDiff {
+	return l.diffs
+}
+
+func (l *fakeLoader) ApplyRepositoryEvent(_ lager.Logger, event cache.RepositoryEvent) (bool, error) {
+	l.events = append(l.events, event)
+	return true, nil
+}
+
+var _ = Describe("Status", func() {
+	It("reports the size and age of the cache of each tenant", func() {
+		clock := fakeclock.NewFakeClock(time.Date(2019, 6, 1, 12, 0, 0, 0, time.UTC))
+		cfg := config.Config{
+			Tenants: []config.Tenant{
+				{Host: "go.example.org", ImportPrefix: "go.example.org/x"},
+				{ImportPrefix: "default.example.net"},
+			},
+		}
+
+		locationCache := cache.NewLocationCache(lagertest.NewTestLogger("cache"), clock)
+		locationCache.ReplaceAll([]cache.Repo{
+			{Name: "repo1", Entry: cache.Entry{Location: "https://github.com/org/repo1"}},
+			{Name: "repo2", Entry: cache.Entry{Location: "https://github.com/org/repo2"}},
+		})
+		clock.Increment(90 * time.Second)
+
+		handler := handlers.NewHandler(lagertest.NewTestLogger("test"), cfg, map[string]cache.Store{
+			"go.example.org": locationCache,
+			"":               cache.NewLocationCache(lagertest.NewTestLogger("cache"), clock),
+		}, nil, nil)
+
+		req, err := http.NewRequest("GET", "/-/status", nil)
+		Expect(err).NotTo(HaveOccurred())
+		res := httptest.NewRecorder()
+		handler.Status(res, req)
+
+		Expect(res.Code).To(Equal(http.StatusOK))
+		Expect(res.Body.String()).To(MatchJSON(`{"tenants": [
+			{"host": "", "import_prefix": "default.example.net", "repos": 0, "stale_repos": 0, "expired_repos": 0},
+			{"host": "go.example.org", "import_prefix": "go.example.org/x", "repos": 2, "refreshed_at": "2019-06-01T12:00:00Z", "snapshot_age_seconds": 90, "oldest_repo_age_seconds": 90, "stale_repos": 0, "expired_repos": 0}
+		]}`))
+	})
+
+	It("reports the stats of any store", func() {
+		fakeStore := &fakes.FakeStore{}
+		fakeStore.StatsReturns(cache.Stats{
+			Repos:       3,
+			RefreshedAt: time.Date(2019, 6, 1, 12, 0, 0, 0, time.UTC),
+			Age:         time.Minute,
+		})
+
+		handler := handlers.NewHandler(lagertest.NewTestLogger("test"), config.Config{ImportPrefix: "import-prefix"}, map[string]cache.Store{"": fakeStore}, nil, nil)
+
+		req, err := http.NewRequest("GET", "/-/status", nil)
+		Expect(err).NotTo(HaveOccurred())
+		res := httptest.NewRecorder()
+		handler.Status(res, req)
+
+		Expect(res.Body.String()).To(MatchJSON(`{"tenants": [
+			{"host": "", "import_prefix": "import-prefix", "repos": 3, "refreshed_at": "2019-06-01T12:00:00Z", "snapshot_age_seconds": 60, "stale_repos": 0, "expired_repos": 0}
+		]}`))
+	})
+
+	It("reports the retry state of the cache loader and its orgs, with the errors for admins", func() {
+		loader := &fakeLoader{status: cache.LoaderStatus{
+			ConsecutiveFailures: 2,
+			LastError:           "failed fetching orgs org2: github is down",
+			NextRefresh:         time.Date(2019, 6, 1, 12, 0, 20, 0, time.UTC),
+			Orgs: []cache.OrgStatus{
+				{Org: "org1", Repos: 12, LastSuccess: time.Date(2019, 6, 1, 12, 0, 0, 0, time.UTC)},
+				{Org: "org2", ConsecutiveFailures: 2, LastError: "github is down"},
+			},
+		}}
+
+		handler := handlers.NewHandler(lagertest.NewTestLogger("test"), config.Config{ImportPrefix: "import-prefix", AdminToken: "admin-token"}, map[string]cache.Store{"": &fakes.FakeStore{}}, map[string]handlers.Loader{"": loader}, nil)
+
+		req, err := http.NewRequest("GET", "/-/status", nil)
+		Expect(err).NotTo(HaveOccurred())
+		res := httptest.NewRecorder()
+		handler.Status(res, req)
+
+		Expect(res.Body.String()).To(MatchJSON(`{"tenants": [
+			{"host": "", "import_prefix": "import-prefix", "repos": 0, "stale_repos": 0, "expired_repos": 0,
+			 "consecutive_failures": 2, "next_refresh_at": "2019-06-01T12:00:20Z",
+			 "orgs": [
+				{"org": "org1", "repos": 12, "last_success_at": "2019-06-01T12:00:00Z", "consecutive_failures": 0},
+				{"org": "org2", "repos": 0, "consecutive_failures": 2}
+			 ]}
+		]}`))
+
+		req.Header.Set("Authorization", "Bearer admin-token")
+		res = httptest.NewRecorder()
+		handler.Status(res, req)
+
+		Expect(res.Body.String()).To(MatchJSON(`{"tenants": [
+			{"host": "", "import_prefix": "import-prefix", "repos": 0, "stale_repos": 0, "expired_repos": 0,
+			 "consecutive_failures": 2, "last_refresh_error": "failed fetching orgs org2: github is down", "next_refresh_at": "2019-06-01T12:00:20Z",
+			 "orgs": [
+				{"org": "org1", "repos": 12, "last_success_at": "2019-06-01T12:00:00Z", "consecutive_failures": 0},
+				{"org": "org2", "repos": 0, "consecutive_failures": 2, "last_error": "github is down"}
+			 ]}
+		]}`))
+	})
+
+	It("reports the refresh that was blocked for removing too many repos, with the names of the repos for admins", func() {
+		loader := &fakeLoader{status: cache.LoaderStatus{
+			Blocked: &cache.BlockedRefresh{
+				ID:        "0123456789ab",
+				BlockedAt: time.Date(2019, 6, 1, 12, 0, 0, 0, time.UTC),
+				Repos:     3,
+				Removed:   []string{"repo2", "repo3"},
+			},
+		}}
+
+		handler := handlers.NewHandler(lagertest.NewTestLogger("test"), config.Config{ImportPrefix: "import-prefix", AdminToken: "admin-token"}, map[string]cache.Store{"": &fakes.FakeStore{}}, map[string]handlers.Loader{"": loader}, nil)
+
+		req, err := http.NewRequest("GET", "/-/status", nil)
+		Expect(err).NotTo(HaveOccurred())
+		req.Header.Set("Authorization", "Bearer wrong-token")
+		res := httptest.NewRecorder()
+		handler.Status(res, req)
+
+		Expect(res.Code).To(Equal(http.StatusOK))
+		Expect(res.Body.String()).To(MatchJSON(`{"tenants": [
+			{"host": "", "import_prefix": "import-prefix", "repos": 0, "stale_repos": 0, "expired_repos": 0,
+			 "blocked_refresh": {"id": "0123456789ab", "blocked_at": "2019-06-01T12:00:00Z", "repos": 3, "removed_repos": 2, "added_repos": 0}}
+		]}`))
+
+		req.Header.Set("Authorization", "Bearer admin-token")
+		res = httptest.NewRecorder()
+		handler.Status(res, req)
+
+		Expect(res.Body.String()).To(MatchJSON(`{"tenants": [
+			{"host": "", "import_prefix": "import-prefix", "repos": 0, "stale_repos": 0, "expired_repos": 0,
+			 "blocked_refresh": {"id": "0123456789ab", "blocked_at": "2019-06-01T12:00:00Z", "repos": 3, "removed_repos": 2, "added_repos": 0, "removed": ["repo2", "repo3"]}}
+		]}`))
+	})
+
+	It("reports the github rate limit of the cache loader", func() {
+		reset := time.Date(2019, 6, 1, 13, 0, 0, 0, time.UTC)
+		loader := &fakeLoader{status: cache.LoaderStatus{
+			RateLimit:        &cache.RateLimit{Limit: 5000, Remaining: 0, Reset: reset},
+			RateLimitedUntil: reset,
+		}}
+
+		handler := handlers.NewHandler(lagertest.NewTestLogger("test"), config.Config{ImportPrefix: "import-prefix"}, map[string]cache.Store{"": &fakes.FakeStore{}}, map[string]handlers.Loader{"": loader}, nil)
+
+		req, err := http.NewRequest("GET", "/-/status", nil)
+		Expect(err).NotTo(HaveOccurred())
+		res := httptest.NewRecorder()
+		handler.Status(res, req)
+
+		Expect(res.Body.String()).To(MatchJSON(`{"tenants": [
+			{"host": "", "import_prefix": "import-prefix", "repos": 0, "stale_repos": 0, "expired_repos": 0,
+			 "github_rate_limit": {"limit": 5000, "remaining": 0, "reset": "2019-06-01T13:00:00Z"},
+			 "rate_limited_until": "2019-06-01T13:00:00Z"}
+		]}`))
+	})
+})
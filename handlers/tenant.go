@@ -0,0 +1,101 @@
+package handlers
+
+import (
+	"html/template"
+	"net"
+	"sort"
+	"strings"
+
+	"code.cloudfoundry.org/lager"
+	"github.com/cloudfoundry/go-fetcher/cache"
+	"github.com/cloudfoundry/go-fetcher/config"
+)
+
+// tenant is the state of a single vanity host.
+type tenant struct {
+	config    config.Tenant
+	store     cache.Store
+	loader    Loader
+	index     *template.Template
+	staleness config.StalenessPolicy
+	// rootSegments is how many segments of a request path are tried as a
+	// vanity root, which is enough for the deepest override.
+	rootSegments int
+	// foldedOverrides maps the lower-case form of the override names to the
+	// names.
+	foldedOverrides map[string]string
+}
+
+func newTenants(logger lager.Logger, cfg config.Config, stores map[string]cache.Store, loaders map[string]Loader) map[string]*tenant {
+	staleness := cfg.GetStalenessPolicy()
+	tenants := map[string]*tenant{}
+	for _, t := range cfg.GetTenants() {
+		foldedOverrides := map[string]string{}
+		rootSegments := maxRootSegments
+		for name, override := range t.Overrides {
+			if segments := strings.Count(name, "/") + 1; segments > rootSegments {
+				rootSegments = segments
+			}
+			folded := strings.ToLower(name)
+			if existing, ok := foldedOverrides[folded]; override.Repo != "" && (!ok || name < existing) {
+				foldedOverrides[folded] = name
+			}
+		}
+
+		tenants[strings.ToLower(t.Host)] = &tenant{
+			config:          t,
+			store:           stores[t.Host],
+			loader:          loaders[t.Host],
+			index:           loadTemplate(logger, t.IndexPath, indexTemplate),
+			foldedOverrides: foldedOverrides,
+			staleness:       staleness,
+			rootSegments:    rootSegments,
+		}
+	}
+	return tenants
+}
+
+// tenant returns the tenant serving host, falling back to the tenant without
+// a Host, and false if there is neither.
+func (h *Handler) tenant(host string) (*tenant, bool) {
+	if hostname, _, err := net.SplitHostPort(host); err == nil {
+		host = hostname
+	}
+
+	if t, ok := h.tenants[strings.ToLower(host)]; ok {
+		return t, true
+	}
+	t, ok := h.tenants[""]
+	return t, ok
+}
+
+// pkg is a repo known to a tenant, either from its overrides or from its
+// store.
+type pkg struct {
+	Name     string
+	Entry    cache.Entry
+	Override bool
+}
+
+// packages returns all repos of the tenant sorted by name, with overrides
+// shadowing stored repos of the same name.
+func (t *tenant) packages() []pkg {
+	byName := map[string]pkg{}
+	if t.store != nil {
+		for _, repo := range t.store.List() {
+			byName[repo.Name] = pkg{Name: repo.Name, Entry: repo.Entry}
+		}
+	}
+	for name, override := range t.config.Overrides {
+		byName[name] = pkg{Name: name, Entry: overrideEntry(override), Override: true}
+	}
+
+	packages := make([]pkg, 0, len(byName))
+	for _, p := range byName {
+		packages = append(packages, p)
+	}
+	sort.Slice(packages, func(i, j int) bool {
+		return packages[i].Name < packages[j].Name
+	})
+	return packages
+}
@@ -0,0 +1,100 @@
+package handlers_test
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+
+	"code.cloudfoundry.org/clock"
+	"code.cloudfoundry.org/lager/lagertest"
+	"github.com/cloudfoundry/go-fetcher/cache"
+	"github.com/cloudfoundry/go-fetcher/config"
+	"github.com/cloudfoundry/go-fetcher/handlers"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/ginkgo/extensions/table"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Tenants", func() {
+	var (
+		cfg            config.Config
+		locationCaches map[string]cache.Store
+	)
+
+	get := func(host, path string) *httptest.ResponseRecorder {
+		handler := handlers.NewHandler(lagertest.NewTestLogger("test"), cfg, locationCaches, nil, nil)
+		req, err := http.NewRequest("GET", path, nil)
+		Expect(err).NotTo(HaveOccurred())
+		req.Host = host
+		res := httptest.NewRecorder()
+		handler.GetMeta(res, req)
+		return res
+	}
+
+	BeforeEach(func() {
+		cfg = config.Config{
+			IndexPath: "../public/index.html",
+			Tenants: []config.Tenant{
+				{
+					Host:         "code.example.com",
+					ImportPrefix: "code.example.com",
+					Overrides: map[string]config.Override{
+						"tools": {Repo: "https://example.com/code-tools"},
+					},
+				},
+				{
+					Host:         "go.example.org",
+					ImportPrefix: "go.example.org/x",
+				},
+				{
+					ImportPrefix: "default.example.net",
+				},
+			},
+		}
+
+		newCache := func(repo, location string) *cache.LocationCache {
+			locationCache := cache.NewLocationCache(lagertest.NewTestLogger("cache"), clock.NewClock())
+			locationCache.Add(repo, location)
+			return locationCache
+		}
+		locationCaches = map[string]cache.Store{
+			"code.example.com": newCache("repo1", "https://github.com/code-org/repo1"),
+			"go.example.org":   newCache("repo1", "https://github.com/org-org/repo1"),
+			"":                 newCache("repo2", "https://github.com/default-org/repo2"),
+		}
+	})
+
+	DescribeTable("serves each host from its own tenant",
+		func(host, path, expectedImport string) {
+			res := get(host, path+"?go-get=1")
+			Expect(res.Code).To(Equal(http.StatusOK))
+			Expect(res.Body.String()).To(ContainSubstring(fmt.Sprintf(`<meta name="go-import" content="%s">`, expectedImport)))
+		},
+		Entry("a cache entry of the first tenant", "code.example.com", "/repo1", "code.example.com/repo1 git https://github.com/code-org/repo1"),
+		Entry("an override of the first tenant", "code.example.com", "/tools", "code.example.com/tools git https://example.com/code-tools"),
+		Entry("a cache entry of the second tenant", "go.example.org", "/repo1", "go.example.org/x/repo1 git https://github.com/org-org/repo1"),
+		Entry("a host with a port", "go.example.org:8080", "/repo1", "go.example.org/x/repo1 git https://github.com/org-org/repo1"),
+		Entry("a host in another case", "Code.Example.COM", "/repo1", "code.example.com/repo1 git https://github.com/code-org/repo1"),
+		Entry("an unknown host served by the default tenant", "other.example.com", "/repo2", "default.example.net/repo2 git https://github.com/default-org/repo2"),
+	)
+
+	It("does not serve the repos of one tenant from another", func() {
+		Expect(get("go.example.org", "/tools?go-get=1").Code).To(Equal(http.StatusNotFound))
+		Expect(get("code.example.com", "/repo2?go-get=1").Code).To(Equal(http.StatusNotFound))
+	})
+
+	It("serves the index page for each tenant", func() {
+		Expect(get("code.example.com", "/").Code).To(Equal(http.StatusOK))
+	})
+
+	Context("when there is no default tenant", func() {
+		BeforeEach(func() {
+			cfg.Tenants = cfg.Tenants[:2]
+		})
+
+		It("returns a 404 Not Found for unknown hosts", func() {
+			Expect(get("other.example.com", "/repo2?go-get=1").Code).To(Equal(http.StatusNotFound))
+			Expect(get("other.example.com", "/").Code).To(Equal(http.StatusNotFound))
+		})
+	})
+})
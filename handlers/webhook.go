@@ -0,0 +1,148 @@
+package handlers
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"sort"
+	"strings"
+
+	"code.cloudfoundry.org/lager"
+	"github.com/cloudfoundry/go-fetcher/cache"
+)
+
+// maxWebhookPayload is the largest webhook payload GitHub sends.
+const maxWebhookPayload = 25 << 20
+
+type webhookResult struct {
+	Event string `json:"event"`
+	// Tenants is the number of tenants whose repos changed.
+	Tenants int `json:"updated_tenants"`
+	// FailedTenants are the hosts of the tenants the event could not be
+	// applied to.
+	FailedTenants []string `json:"failed_tenants,omitempty"`
+}
+
+// GithubWebhook serves POST /-/webhooks/github. Repository events are
+// applied to the stores of the tenants with the repo's org in their OrgList,
+// so new, renamed and removed repos show up before the next refresh. Requests
+// must be signed with the GithubWebhookSecret, and the endpoint is disabled
+// if there is none.
+//
+// An event is applied to every tenant even if it fails for some, which makes
+// the response a 500 so that GitHub can redeliver it. Applying an event again
+// leaves the repos it already changed as they are.
+func (h *Handler) GithubWebhook(writer http.ResponseWriter, request *http.Request) {
+	event := request.Header.Get("X-GitHub-Event")
+	logger := h.logger.Session("handler.github-webhook", lager.Data{"event": event, "delivery": request.Header.Get("X-GitHub-Delivery")})
+
+	if h.config.GithubWebhookSecret == "" {
+		writeJSON(logger, writer, http.StatusNotFound, apiError{Error: "webhooks are not configured"})
+		return
+	}
+	if request.Method != http.MethodPost {
+		writer.Header().Set("Allow", http.MethodPost)
+		writeJSON(logger, writer, http.StatusMethodNotAllowed, apiError{Error: "method not allowed"})
+		return
+	}
+
+	body, err := ioutil.ReadAll(http.MaxBytesReader(writer, request.Body, maxWebhookPayload))
+	if err != nil {
+		logger.Error("failed-reading-payload", err)
+		writeJSON(logger, writer, http.StatusBadRequest, apiError{Error: "cannot read payload"})
+		return
+	}
+
+	if !validSignature(h.config.GithubWebhookSecret, body, request.Header.Get("X-Hub-Signature-256")) {
+		logger.Info("invalid-signature")
+		writeJSON(logger, writer, http.StatusUnauthorized, apiError{Error: "invalid signature"})
+		return
+	}
+
+	payload, err := webhookPayload(request.Header.Get("Content-Type"), body)
+	if err != nil {
+		logger.Error("invalid-payload", err)
+		writeJSON(logger, writer, http.StatusBadRequest, apiError{Error: err.Error()})
+		return
+	}
+
+	result := webhookResult{Event: event}
+	if event != "repository" {
+		logger.Debug("ignored-event")
+		writeJSON(logger, writer, http.StatusOK, result)
+		return
+	}
+
+	var repositoryEvent cache.RepositoryEvent
+	if err := json.Unmarshal(payload, &repositoryEvent); err != nil || repositoryEvent.Repository.GetName() == "" {
+		logger.Info("invalid-repository-event")
+		writeJSON(logger, writer, http.StatusBadRequest, apiError{Error: "invalid repository event"})
+		return
+	}
+
+	for _, tenant := range h.tenants {
+		if tenant.store == nil {
+			continue
+		}
+
+		// the loader records the changes along with those of the refreshes
+		tenantLogger := logger.WithData(lager.Data{"host": tenant.config.Host})
+		var (
+			changed bool
+			err     error
+		)
+		if tenant.loader != nil {
+			changed, err = tenant.loader.ApplyRepositoryEvent(tenantLogger, repositoryEvent)
+		} else {
+			changed, err = cache.ApplyRepositoryEvent(tenantLogger, tenant.store, tenant.config.OrgList, repositoryEvent)
+		}
+		if changed {
+			result.Tenants++
+		}
+		if err != nil {
+			tenantLogger.Error("failed-applying-repository-event", err)
+			result.FailedTenants = append(result.FailedTenants, tenant.config.Host)
+		}
+	}
+
+	if len(result.FailedTenants) > 0 {
+		sort.Strings(result.FailedTenants)
+		writeJSON(logger, writer, http.StatusInternalServerError, result)
+		return
+	}
+	logger.Info("applied-repository-event", lager.Data{"action": repositoryEvent.Action, "tenants": result.Tenants})
+	writeJSON(logger, writer, http.StatusOK, result)
+}
+
+// validSignature checks the X-Hub-Signature-256 header, the hex encoded
+// HMAC-SHA256 of the request body.
+func validSignature(secret string, body []byte, signature string) bool {
+	if !strings.HasPrefix(signature, "sha256=") {
+		return false
+	}
+	sum, err := hex.DecodeString(strings.TrimPrefix(signature, "sha256="))
+	if err != nil {
+		return false
+	}
+
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write(body)
+	return hmac.Equal(sum, mac.Sum(nil))
+}
+
+// webhookPayload returns the JSON payload of a webhook, which GitHub sends
+// either as the body or as the payload form field.
+func webhookPayload(contentType string, body []byte) ([]byte, error) {
+	if strings.HasPrefix(contentType, "application/x-www-form-urlencoded") {
+		form, err := url.ParseQuery(string(body))
+		if err != nil {
+			return nil, err
+		}
+		return []byte(form.Get("payload")), nil
+	}
+	return body, nil
+}
@@ -0,0 +1,291 @@
+package handlers_test
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"path/filepath"
+	"time"
+
+	"code.cloudfoundry.org/clock/fakeclock"
+	"code.cloudfoundry.org/lager/lagertest"
+	"github.com/cloudfoundry/go-fetcher/cache"
+	"github.com/cloudfoundry/go-fetcher/cache/fakes"
+	"github.com/cloudfoundry/go-fetcher/config"
+	"github.com/cloudfoundry/go-fetcher/handlers"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/ginkgo/extensions/table"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("GitHub webhook", func() {
+	const secret = "webhook-secret"
+
+	var (
+		cfg           config.Config
+		locationCache *cache.LocationCache
+		loaders       map[string]handlers.Loader
+		handler       *handlers.Handler
+	)
+
+	sign := func(body []byte) string {
+		mac := hmac.New(sha256.New, []byte(secret))
+		mac.Write(body)
+		return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+	}
+
+	deliver := func(event string, body []byte, signature string) *httptest.ResponseRecorder {
+		req, err := http.NewRequest("POST", "/-/webhooks/github", bytes.NewReader(body))
+		Expect(err).NotTo(HaveOccurred())
+		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set("X-GitHub-Event", event)
+		req.Header.Set("X-GitHub-Delivery", "72d3162e-cc78-11e3-81ab-4c9367dc0958")
+		req.Header.Set("X-Hub-Signature-256", signature)
+		res := httptest.NewRecorder()
+		handler.GithubWebhook(res, req)
+		return res
+	}
+
+	replay := func(fixture string) *httptest.ResponseRecorder {
+		body, err := ioutil.ReadFile(filepath.Join("testdata", "github", fixture+".json"))
+		Expect(err).NotTo(HaveOccurred())
+		return deliver("repository", body, sign(body))
+	}
+
+	lookup := func(name string) (cache.Entry, bool) {
+		found, entry, ok := locationCache.Lookup(name)
+		return entry, ok && found == name
+	}
+
+	BeforeEach(func() {
+		cfg = config.Config{
+			ImportPrefix:        "import-prefix",
+			OrgList:             []string{"org1", "org2"},
+			GithubWebhookSecret: secret,
+		}
+
+		locationCache = cache.NewLocationCache(lagertest.NewTestLogger("cache"), fakeclock.NewFakeClock(time.Now()))
+		locationCache.ReplaceAll([]cache.Repo{
+			{Name: "repo1", Entry: cache.Entry{Location: "https://github.com/org1/repo1", Org: "org1"}},
+			{Name: "repo2", Entry: cache.Entry{Location: "https://github.com/org2/repo2", Org: "org2"}},
+		})
+		loaders = nil
+	})
+
+	JustBeforeEach(func() {
+		handler = handlers.NewHandler(lagertest.NewTestLogger("test"), cfg, map[string]cache.Store{"": locationCache}, loaders, nil)
+	})
+
+	Context("when the tenant has a loader", func() {
+		var loader *fakeLoader
+
+		BeforeEach(func() {
+			loader = &fakeLoader{}
+			loaders = map[string]handlers.Loader{"": loader}
+		})
+
+		It("hands the events to the loader, which records their changes", func() {
+			res := replay("repository-renamed")
+			Expect(res.Code).To(Equal(http.StatusOK))
+			Expect(res.Body.String()).To(MatchJSON(`{"event": "repository", "updated_tenants": 1}`))
+			Expect(loader.events).To(HaveLen(1))
+			Expect(loader.events[0].Action).To(Equal("renamed"))
+
+			_, ok := lookup("repo1")
+			Expect(ok).To(BeTrue())
+		})
+	})
+
+	It("adds created repos", func() {
+		res := replay("repository-created")
+		Expect(res.Code).To(Equal(http.StatusOK))
+		Expect(res.Body.String()).To(MatchJSON(`{"event": "repository", "updated_tenants": 1}`))
+
+		entry, ok := lookup("new-repo")
+		Expect(ok).To(BeTrue())
+		Expect(entry.Location).To(Equal("https://github.com/org1/new-repo"))
+		Expect(entry.Org).To(Equal("org1"))
+		Expect(entry.Description).To(Equal("A new repo"))
+		Expect(entry.DefaultBranch).To(Equal("main"))
+	})
+
+	It("removes deleted repos", func() {
+		Expect(replay("repository-deleted").Code).To(Equal(http.StatusOK))
+
+		_, ok := lookup("repo1")
+		Expect(ok).To(BeFalse())
+	})
+
+	It("moves renamed repos to their new name", func() {
+		Expect(replay("repository-renamed").Code).To(Equal(http.StatusOK))
+
+		_, ok := lookup("repo1")
+		Expect(ok).To(BeFalse())
+		entry, ok := lookup("renamed-repo")
+		Expect(ok).To(BeTrue())
+		Expect(entry.Location).To(Equal("https://github.com/org1/renamed-repo"))
+	})
+
+	It("removes repos transferred to another owner", func() {
+		Expect(replay("repository-transferred").Code).To(Equal(http.StatusOK))
+
+		_, ok := lookup("repo1")
+		Expect(ok).To(BeFalse())
+	})
+
+	It("marks archived repos", func() {
+		Expect(replay("repository-archived").Code).To(Equal(http.StatusOK))
+
+		entry, ok := lookup("repo1")
+		Expect(ok).To(BeTrue())
+		Expect(entry.Archived).To(BeTrue())
+	})
+
+	It("removes repos that were made private", func() {
+		Expect(replay("repository-privatized").Code).To(Equal(http.StatusOK))
+
+		_, ok := lookup("repo1")
+		Expect(ok).To(BeFalse())
+	})
+
+	It("adds repos that were made public, matching the org ignoring case", func() {
+		Expect(replay("repository-publicized").Code).To(Equal(http.StatusOK))
+
+		entry, ok := lookup("secret-repo")
+		Expect(ok).To(BeTrue())
+		Expect(entry.Org).To(Equal("org2"))
+	})
+
+	Context("when the repo is in another org", func() {
+		BeforeEach(func() {
+			cfg.OrgList = []string{"org2"}
+		})
+
+		It("ignores it", func() {
+			res := replay("repository-created")
+			Expect(res.Code).To(Equal(http.StatusOK))
+			Expect(res.Body.String()).To(MatchJSON(`{"event": "repository", "updated_tenants": 0}`))
+			_, ok := lookup("new-repo")
+			Expect(ok).To(BeFalse())
+		})
+	})
+
+	It("only updates the tenants with the org", func() {
+		otherCache := cache.NewLocationCache(lagertest.NewTestLogger("cache"), fakeclock.NewFakeClock(time.Now()))
+		cfg.Tenants = []config.Tenant{
+			{Host: "a.example.com", ImportPrefix: "a.example.com", OrgList: []string{"org1"}},
+			{Host: "b.example.com", ImportPrefix: "b.example.com", OrgList: []string{"org3"}},
+		}
+		handler = handlers.NewHandler(lagertest.NewTestLogger("test"), cfg, map[string]cache.Store{
+			"a.example.com": locationCache,
+			"b.example.com": otherCache,
+		}, nil, nil)
+
+		body, err := ioutil.ReadFile(filepath.Join("testdata", "github", "repository-created.json"))
+		Expect(err).NotTo(HaveOccurred())
+		req, err := http.NewRequest("POST", "/-/webhooks/github", bytes.NewReader(body))
+		Expect(err).NotTo(HaveOccurred())
+		req.Header.Set("X-GitHub-Event", "repository")
+		req.Header.Set("X-Hub-Signature-256", sign(body))
+		res := httptest.NewRecorder()
+		handler.GithubWebhook(res, req)
+
+		Expect(res.Body.String()).To(MatchJSON(`{"event": "repository", "updated_tenants": 1}`))
+		_, ok := lookup("new-repo")
+		Expect(ok).To(BeTrue())
+		Expect(otherCache.List()).To(BeEmpty())
+	})
+
+	It("applies the event to every tenant before failing for those it could not be applied to", func() {
+		failingStore := &fakes.FakeStore{}
+		failingStore.UpsertReturns(errors.New("disk full"))
+		cfg.Tenants = []config.Tenant{
+			{Host: "a.example.com", ImportPrefix: "a.example.com", OrgList: []string{"org1"}},
+			{Host: "b.example.com", ImportPrefix: "b.example.com", OrgList: []string{"org1"}},
+			{Host: "c.example.com", ImportPrefix: "c.example.com", OrgList: []string{"org1"}},
+		}
+		handler = handlers.NewHandler(lagertest.NewTestLogger("test"), cfg, map[string]cache.Store{
+			"a.example.com": failingStore,
+			"b.example.com": locationCache,
+			"c.example.com": failingStore,
+		}, nil, nil)
+
+		res := replay("repository-created")
+
+		Expect(res.Code).To(Equal(http.StatusInternalServerError))
+		Expect(res.Body.String()).To(MatchJSON(`{"event": "repository", "updated_tenants": 1, "failed_tenants": ["a.example.com", "c.example.com"]}`))
+		Expect(failingStore.UpsertCallCount()).To(Equal(2))
+		_, ok := lookup("new-repo")
+		Expect(ok).To(BeTrue())
+	})
+
+	It("accepts form encoded payloads", func() {
+		payload, err := ioutil.ReadFile(filepath.Join("testdata", "github", "repository-created.json"))
+		Expect(err).NotTo(HaveOccurred())
+		body := []byte(url.Values{"payload": {string(payload)}}.Encode())
+
+		req, err := http.NewRequest("POST", "/-/webhooks/github", bytes.NewReader(body))
+		Expect(err).NotTo(HaveOccurred())
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		req.Header.Set("X-GitHub-Event", "repository")
+		req.Header.Set("X-Hub-Signature-256", sign(body))
+		res := httptest.NewRecorder()
+		handler.GithubWebhook(res, req)
+
+		Expect(res.Code).To(Equal(http.StatusOK))
+		_, ok := lookup("new-repo")
+		Expect(ok).To(BeTrue())
+	})
+
+	It("acknowledges other events without changes", func() {
+		body := []byte(`{"zen": "Keep it logically awesome.", "hook_id": 1}`)
+		res := deliver("ping", body, sign(body))
+		Expect(res.Code).To(Equal(http.StatusOK))
+		Expect(res.Body.String()).To(MatchJSON(`{"event": "ping", "updated_tenants": 0}`))
+	})
+
+	DescribeTable("rejects payloads without a valid signature",
+		func(signature string) {
+			body, err := ioutil.ReadFile(filepath.Join("testdata", "github", "repository-deleted.json"))
+			Expect(err).NotTo(HaveOccurred())
+			if signature == "" {
+				signature = sign(append(body, ' '))
+			}
+
+			res := deliver("repository", body, signature)
+			Expect(res.Code).To(Equal(http.StatusUnauthorized))
+			Expect(res.Body.String()).To(MatchJSON(`{"error": "invalid signature"}`))
+			_, ok := lookup("repo1")
+			Expect(ok).To(BeTrue())
+		},
+		Entry("with the signature of another payload", ""),
+		Entry("with a SHA-1 signature", "sha1=7d38cdd689735b008b3c702edd92eea23791c5f6"),
+		Entry("with a signature that is not hex", "sha256=zz"),
+		Entry("without a signature", "none"),
+	)
+
+	It("rejects invalid payloads", func() {
+		body := []byte(`{"action": "created"}`)
+		res := deliver("repository", body, sign(body))
+		Expect(res.Code).To(Equal(http.StatusBadRequest))
+	})
+
+	Context("without a secret", func() {
+		BeforeEach(func() {
+			cfg.GithubWebhookSecret = ""
+		})
+
+		It("is disabled", func() {
+			res := replay("repository-deleted")
+			Expect(res.Code).To(Equal(http.StatusNotFound))
+			_, ok := lookup("repo1")
+			Expect(ok).To(BeTrue())
+		})
+	})
+})
@@ -14,6 +14,7 @@ import (
 	"github.com/cloudfoundry/go-fetcher/cache"
 	"github.com/cloudfoundry/go-fetcher/config"
 	"github.com/cloudfoundry/go-fetcher/handlers"
+	"github.com/cloudfoundry/go-fetcher/proxy"
 	"github.com/cloudfoundry/go-fetcher/util"
 	"github.com/google/go-github/github"
 	"github.com/tedsuo/ifrit"
@@ -64,15 +65,31 @@ func main() {
 	sink := lager.NewReconfigurableSink(lager.NewWriterSink(os.Stdout, lager.DEBUG), config.GetLogLevel())
 	logger.RegisterSink(sink)
 
+	for _, skipped := range config.SkippedOverrides() {
+		logger.Info("skipped-override", lager.Data{"host": skipped.Host, "name": skipped.Name, "reason": skipped.Reason})
+	}
+
 	port := os.Getenv("PORT")
 	if port == "" {
 		logger.Error("server.failed", fmt.Errorf("$PORT must be set"))
 	}
 
 	clock := clock.NewClock()
-	locationCache := cache.NewLocationCache(logger.Session("cache"), clock)
-	handler := handlers.NewHandler(logger, *config, locationCache)
-	http.HandleFunc("/", handler.GetMeta)
+	tenants := config.GetTenants()
+	stores := map[string]cache.Store{}
+	for _, tenant := range tenants {
+		storeLogger := logger.Session("cache", lager.Data{"host": tenant.Host})
+		if tenant.CacheFile != "" {
+			stores[tenant.Host] = cache.NewDiskStore(storeLogger, clock, tenant.CacheFile)
+		} else {
+			stores[tenant.Host] = cache.NewLocationCache(storeLogger, clock)
+		}
+	}
+
+	var moduleProxy *proxy.Proxy
+	if config.ModuleCacheDir != "" {
+		moduleProxy = proxy.NewProxy(logger.Session("proxy"), config.ModuleCacheDir, clock)
+	}
 
 	var tc *http.Client
 	if config.GithubAPIKey != "" {
@@ -89,20 +106,46 @@ func main() {
 	}
 	client.BaseURL = githubURL
 
-	httpServer := http_server.New(":"+port, http.DefaultServeMux)
-	cacheLoader := cache.NewCacheLoader(
-		logger.Session("cache-loader"),
-		config.OrgList,
-		locationCache,
-		client.Repositories,
-		clock,
-	)
-
-	members := grouper.Members{
-		{"cache-loader", cacheLoader},
-		{"http-server", httpServer},
+	retry := config.GetRetryPolicy()
+	retryPolicy := cache.DefaultRetryPolicy
+	retryPolicy.StartupTimeout = retry.StartupTimeout
+	retryPolicy.MaxBackoff = retry.RetryInterval
+
+	var members grouper.Members
+	loaders := map[string]handlers.Loader{}
+	for _, tenant := range tenants {
+		name := "cache-loader"
+		if tenant.Host != "" {
+			name += "-" + tenant.Host
+		}
+		cacheLoader := cache.NewCacheLoader(
+			logger.Session(name),
+			tenant.OrgList,
+			stores[tenant.Host],
+			client.Repositories,
+			clock,
+			retryPolicy,
+			config.GetFetchConcurrency(),
+			cache.DeletionGuard{MaxRemoved: config.MaxRemovedRepos, MaxRemovedPercent: config.MaxRemovedPercent},
+		)
+		loaders[tenant.Host] = cacheLoader
+		members = append(members, grouper.Member{Name: name, Runner: cacheLoader})
 	}
 
+	handler := handlers.NewHandler(logger, *config, stores, loaders, moduleProxy)
+	http.HandleFunc("/", handler.GetMeta)
+	// the other endpoints are below /-/, which is never the path of a repo
+	http.HandleFunc("/-/api/v1/repos", handler.ListRepos)
+	http.HandleFunc("/-/api/v1/repos/", handler.GetRepo)
+	http.HandleFunc("/-/api/v1/refreshes", handler.ListRefreshes)
+	http.HandleFunc("/-/search", handler.Search)
+	http.HandleFunc("/-/status", handler.Status)
+	http.HandleFunc("/-/webhooks/github", handler.GithubWebhook)
+	http.HandleFunc("/-/admin/accept-refresh", handler.AcceptRefresh)
+
+	httpServer := http_server.New(":"+port, http.DefaultServeMux)
+	members = append(members, grouper.Member{Name: "http-server", Runner: httpServer})
+
 	group := grouper.NewOrdered(os.Interrupt, members)
 
 	monitor := ifrit.Invoke(sigmon.New(group))
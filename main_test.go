@@ -117,7 +117,7 @@ var _ = Describe("Import Path Redirect Service", func() {
 				fakeGithubServer.URL())))
 
 			Expect(body).To(ContainSubstring(fmt.Sprintf(
-				`<meta name="go-source" content="%s/repository-1 _ %s/cloudfoundry/repository-1">`,
+				`<meta name="go-source" content="%[1]s/repository-1 %[2]s/cloudfoundry/repository-1 %[2]s/cloudfoundry/repository-1/tree/master{/dir} %[2]s/cloudfoundry/repository-1/blob/master{/dir}/{file}#L{line}">`,
 				conf.ImportPrefix,
 				fakeGithubServer.URL())))
 		})
@@ -186,7 +186,26 @@ var _ = Describe("Import Path Redirect Service", func() {
 		})
 
 		Context("when go-get is set", func() {
-			It("will redirect to godoc.org with an HTML meta tag redirect", func() {
+			It("will return the go-import meta tag regardless of the user agent", func() {
+				client := &http.Client{}
+
+				req, err := http.NewRequest("GET", "http://:"+port+"/repository-1/test?go-get=1", nil)
+				Expect(err).NotTo(HaveOccurred())
+				req.Header.Set("User-Agent", "some-unknown-agent")
+
+				res, err := client.Do(req)
+				Expect(err).NotTo(HaveOccurred())
+				defer res.Body.Close()
+
+				body, err := ioutil.ReadAll(res.Body)
+				Expect(err).NotTo(HaveOccurred())
+				Expect(body).To(ContainSubstring(fmt.Sprintf(
+					`<meta name="go-import" content="%s/repository-1 git %s/cloudfoundry/repository-1">`,
+					conf.ImportPrefix,
+					fakeGithubServer.URL())))
+			})
+
+			It("will redirect to pkg.go.dev with an HTML meta tag redirect", func() {
 				client := &http.Client{}
 
 				req, err := http.NewRequest("GET", "http://:"+port+"/repository-1/test?go-get=1", nil)
@@ -199,9 +218,34 @@ var _ = Describe("Import Path Redirect Service", func() {
 				var body []byte
 				body, err = ioutil.ReadAll(res.Body)
 				Expect(err).NotTo(HaveOccurred())
-				expectedMeta := fmt.Sprintf("<meta http-equiv=\"refresh\" content=\"0; url=https://godoc.org/%s/repository-1/test\">", conf.ImportPrefix)
+				expectedMeta := fmt.Sprintf("<meta http-equiv=\"refresh\" content=\"0; url=https://pkg.go.dev/%s/repository-1/test\">", conf.ImportPrefix)
 				Expect(body).To(ContainSubstring(expectedMeta))
 			})
 		})
 	})
+
+	Describe("JSON API", func() {
+		It("resolves the repos of the orgs", func() {
+			res, err := http.Get("http://:" + port + "/-/api/v1/repos/repo-in-incubator")
+			Expect(err).NotTo(HaveOccurred())
+			defer res.Body.Close()
+			Expect(res.StatusCode).To(Equal(http.StatusOK))
+
+			var repo map[string]interface{}
+			Expect(json.NewDecoder(res.Body).Decode(&repo)).To(Succeed())
+			Expect(repo).To(HaveKeyWithValue("location", fmt.Sprintf("%s/cloudfoundry-incubator/repo-in-incubator", fakeGithubServer.URL())))
+			Expect(repo).To(HaveKeyWithValue("org", "cloudfoundry-incubator"))
+		})
+
+		It("lists the repos of the orgs", func() {
+			res, err := http.Get("http://:" + port + "/-/api/v1/repos?org=cloudfoundry")
+			Expect(err).NotTo(HaveOccurred())
+			defer res.Body.Close()
+			Expect(res.StatusCode).To(Equal(http.StatusOK))
+
+			var list map[string]interface{}
+			Expect(json.NewDecoder(res.Body).Decode(&list)).To(Succeed())
+			Expect(list).To(HaveKeyWithValue("total", BeNumerically("==", 2)))
+		})
+	})
 })
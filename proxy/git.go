@@ -0,0 +1,194 @@
+package proxy
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// gitRepo is a local bare clone of an upstream repository. All access goes
+// through the git binary so that any transport git understands (https, ssh,
+// or a plain directory) can be used as the upstream.
+type gitRepo struct {
+	dir string
+}
+
+func (g *gitRepo) run(stdout io.Writer, args ...string) error {
+	var stderr bytes.Buffer
+	cmd := exec.Command("git", args...)
+	cmd.Dir = g.dir
+	cmd.Env = append(os.Environ(), "GIT_TERMINAL_PROMPT=0")
+	cmd.Stdout = stdout
+	cmd.Stderr = &stderr
+
+	if err := cmd.Run(); err != nil {
+		return fmt.Errorf("git %s: %s: %s", args[0], err, strings.TrimSpace(stderr.String()))
+	}
+	return nil
+}
+
+func (g *gitRepo) output(args ...string) (string, error) {
+	var stdout bytes.Buffer
+	if err := g.run(&stdout, args...); err != nil {
+		return "", err
+	}
+	return strings.TrimSpace(stdout.String()), nil
+}
+
+func (g *gitRepo) exists() bool {
+	_, err := os.Stat(g.dir)
+	return err == nil
+}
+
+func (g *gitRepo) init(location string) error {
+	if err := os.MkdirAll(g.dir, 0755); err != nil {
+		return err
+	}
+	if _, err := g.output("init", "--bare", "--quiet"); err != nil {
+		os.RemoveAll(g.dir)
+		return err
+	}
+	if _, err := g.output("remote", "add", "origin", location); err != nil {
+		os.RemoveAll(g.dir)
+		return err
+	}
+	return nil
+}
+
+// fetch mirrors the branches and tags of the upstream into the clone, pruning
+// anything that was deleted upstream.
+func (g *gitRepo) fetch() error {
+	_, err := g.output("fetch", "--quiet", "--prune", "--force", "origin",
+		"+refs/heads/*:refs/heads/*",
+		"+refs/tags/*:refs/tags/*",
+		"+HEAD:refs/remotes/origin/HEAD",
+	)
+	return err
+}
+
+func (g *gitRepo) tags() ([]string, error) {
+	out, err := g.output("tag", "--list")
+	if err != nil {
+		return nil, err
+	}
+	if out == "" {
+		return nil, nil
+	}
+	return strings.Split(out, "\n"), nil
+}
+
+// mergedTags returns the tags that are reachable from rev.
+func (g *gitRepo) mergedTags(rev string) ([]string, error) {
+	out, err := g.output("tag", "--list", "--merged", rev)
+	if err != nil {
+		return nil, err
+	}
+	if out == "" {
+		return nil, nil
+	}
+	return strings.Split(out, "\n"), nil
+}
+
+// commit resolves rev to a full commit hash and its commit time.
+func (g *gitRepo) commit(rev string) (string, time.Time, error) {
+	out, err := g.output("log", "-n", "1", "--format=%H %ct", rev+"^{commit}", "--")
+	if err != nil {
+		return "", time.Time{}, err
+	}
+
+	fields := strings.Fields(out)
+	if len(fields) != 2 {
+		return "", time.Time{}, fmt.Errorf("unexpected git log output: %q", out)
+	}
+	seconds, err := strconv.ParseInt(fields[1], 10, 64)
+	if err != nil {
+		return "", time.Time{}, err
+	}
+	return fields[0], time.Unix(seconds, 0).UTC(), nil
+}
+
+// treeEntry is an entry of a git tree, as listed by git ls-tree.
+type treeEntry struct {
+	mode   string
+	kind   string
+	object string
+	// size is the size of blobs, and -1 for other kinds of objects.
+	size int64
+	path string
+}
+
+// listTree returns the entries of the tree at rev that match path, all of
+// them if path is empty. With recursive set it lists the files below the
+// directories instead of the directories themselves.
+func (g *gitRepo) listTree(rev, path string, recursive bool) ([]treeEntry, error) {
+	args := []string{"ls-tree", "-l", "-z", "--full-tree"}
+	if recursive {
+		args = append(args, "-r")
+	}
+	args = append(args, rev)
+	if path != "" {
+		args = append(args, "--", path)
+	}
+
+	var stdout bytes.Buffer
+	if err := g.run(&stdout, args...); err != nil {
+		return nil, err
+	}
+
+	var entries []treeEntry
+	for _, record := range strings.Split(stdout.String(), "\x00") {
+		if record == "" {
+			continue
+		}
+		tab := strings.IndexByte(record, '\t')
+		if tab < 0 {
+			return nil, fmt.Errorf("unexpected git ls-tree output: %q", record)
+		}
+		fields := strings.Fields(record[:tab])
+		if len(fields) != 4 {
+			return nil, fmt.Errorf("unexpected git ls-tree output: %q", record)
+		}
+
+		entry := treeEntry{mode: fields[0], kind: fields[1], object: fields[2], size: -1, path: record[tab+1:]}
+		if fields[3] != "-" {
+			size, err := strconv.ParseInt(fields[3], 10, 64)
+			if err != nil {
+				return nil, fmt.Errorf("unexpected git ls-tree output: %q", record)
+			}
+			entry.size = size
+		}
+		entries = append(entries, entry)
+	}
+	return entries, nil
+}
+
+// readFile returns the contents of path at rev, and false if it does not exist.
+func (g *gitRepo) readFile(rev, path string) ([]byte, bool, error) {
+	entries, err := g.listTree(rev, path, false)
+	if err != nil {
+		return nil, false, err
+	}
+	if len(entries) != 1 || entries[0].kind != "blob" {
+		return nil, false, nil
+	}
+
+	var stdout bytes.Buffer
+	if err := g.run(&stdout, "cat-file", "blob", entries[0].object); err != nil {
+		return nil, false, err
+	}
+	return stdout.Bytes(), true, nil
+}
+
+// archive writes a tar archive of the tree at rev to w, limited to dir if it
+// is not empty.
+func (g *gitRepo) archive(rev, dir string, w io.Writer) error {
+	if dir == "" {
+		return g.run(w, "archive", "--format=tar", rev)
+	}
+	return g.run(w, "archive", "--format=tar", rev, "--", dir)
+}
@@ -0,0 +1,413 @@
+package proxy
+
+import (
+	"fmt"
+	"io"
+	"path"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"code.cloudfoundry.org/lager"
+)
+
+// incompatibleSuffix marks the versions of major version 2 or higher of a
+// module path without a major version suffix, which are only valid for tags
+// without a go.mod file.
+const incompatibleSuffix = "+incompatible"
+
+var (
+	pseudoVersionRE = regexp.MustCompile(`^v[0-9]+\.(0\.0-|\d+\.\d+-([^+]*\.)?0\.)\d{14}-[A-Za-z0-9]+(\+[0-9A-Za-z-]+(\.[0-9A-Za-z-]+)*)?$`)
+
+	// queryRE restricts non-semver version queries (branches and commit
+	// hashes) to characters that are safe to hand to git.
+	queryRE = regexp.MustCompile(`^[A-Za-z0-9][A-Za-z0-9._/-]*$`)
+)
+
+// Module is a single Go module served from a mirrored repository.
+type Module struct {
+	path   string
+	dir    string
+	major  int
+	mirror *mirror
+	proxy  *Proxy
+	logger lager.Logger
+}
+
+// List returns the tagged versions of the module, in semver order.
+func (m *Module) List() ([]string, error) {
+	m.mirror.lock.Lock()
+	defer m.mirror.lock.Unlock()
+
+	if err := m.mirror.refresh(m.logger, m.proxy.clock.Now()); err != nil {
+		return nil, err
+	}
+	return m.versions()
+}
+
+// Latest returns the highest release version of the module, falling back to
+// the highest prerelease and then to a pseudo-version of the default branch.
+func (m *Module) Latest() (Info, error) {
+	m.mirror.lock.Lock()
+	defer m.mirror.lock.Unlock()
+
+	if err := m.mirror.refresh(m.logger, m.proxy.clock.Now()); err != nil {
+		return Info{}, err
+	}
+
+	versions, err := m.versions()
+	if err != nil {
+		return Info{}, err
+	}
+
+	var compatible []string
+	for _, v := range versions {
+		if !strings.HasSuffix(v, incompatibleSuffix) {
+			compatible = append(compatible, v)
+		}
+	}
+
+	// like the go command, prefer the latest compatible version over
+	// +incompatible ones once the module has a go.mod file
+	latest := latestVersion(versions)
+	if strings.HasSuffix(latest, incompatibleSuffix) {
+		if v := latestVersion(compatible); v != "" {
+			hasMod, err := m.hasGoMod(m.tagRef(v))
+			if err != nil {
+				return Info{}, err
+			}
+			if hasMod {
+				latest = v
+			}
+		}
+	}
+	if latest != "" {
+		return m.tagInfo(latest)
+	}
+
+	return m.pseudoInfo("refs/remotes/origin/HEAD")
+}
+
+// Info resolves a version query, which may be a semantic version, a
+// pseudo-version, a branch name or a commit hash.
+func (m *Module) Info(query string) (Info, error) {
+	m.mirror.lock.Lock()
+	defer m.mirror.lock.Unlock()
+
+	switch {
+	case isValidSemver(query) && !pseudoVersionRE.MatchString(query):
+		if err := m.ensureTag(query); err != nil {
+			return Info{}, err
+		}
+		return m.tagInfo(query)
+	case pseudoVersionRE.MatchString(query):
+		rev, err := m.resolve(query)
+		if err != nil {
+			return Info{}, err
+		}
+		_, t, err := m.mirror.repo.commit(rev)
+		if err != nil {
+			return Info{}, err
+		}
+		return Info{Version: query, Time: t}, nil
+	case queryRE.MatchString(query):
+		if err := m.mirror.refresh(m.logger, m.proxy.clock.Now()); err != nil {
+			return Info{}, err
+		}
+		if _, _, err := m.mirror.repo.commit(query); err != nil {
+			return Info{}, ErrNotFound
+		}
+		return m.pseudoInfo(query)
+	}
+	return Info{}, ErrNotFound
+}
+
+// Mod returns the go.mod file of the given version, synthesizing one for
+// repositories that do not have it.
+func (m *Module) Mod(version string) ([]byte, error) {
+	m.mirror.lock.Lock()
+	defer m.mirror.lock.Unlock()
+
+	rev, err := m.resolve(version)
+	if err != nil {
+		return nil, err
+	}
+
+	contents, ok, err := m.mirror.repo.readFile(rev, path.Join(m.dir, "go.mod"))
+	if err != nil {
+		return nil, err
+	}
+	if !ok {
+		return []byte(fmt.Sprintf("module %s\n", m.path)), nil
+	}
+	return contents, nil
+}
+
+// Zip streams the module zip file of the given version to w. Nothing is
+// written if the version does not exist or its files are not a valid module
+// zip, in which case the error is an *InvalidZipError.
+func (m *Module) Zip(version string, w io.Writer) error {
+	m.mirror.lock.Lock()
+	defer m.mirror.lock.Unlock()
+
+	rev, err := m.resolve(version)
+	if err != nil {
+		return err
+	}
+
+	files, err := m.files(rev)
+	if err != nil {
+		return err
+	}
+	return m.writeZip(w, rev, m.path+"@"+version, files)
+}
+
+// versions returns the tags that are valid versions for the module's major
+// version, sorted in semver order. Tags of major version 2 or higher without
+// a go.mod file are +incompatible versions of a module path without a major
+// version suffix.
+func (m *Module) versions() ([]string, error) {
+	tags, err := m.mirror.repo.tags()
+	if err != nil {
+		return nil, err
+	}
+
+	var versions []string
+	for _, version := range m.tagVersions(tags) {
+		if m.acceptsVersion(version) {
+			versions = append(versions, version)
+			continue
+		}
+		if !m.acceptsVersion(version + incompatibleSuffix) {
+			continue
+		}
+		hasMod, err := m.hasGoMod(m.tagRef(version))
+		if err != nil {
+			return nil, err
+		}
+		if !hasMod {
+			versions = append(versions, version+incompatibleSuffix)
+		}
+	}
+	sort.Slice(versions, func(i, j int) bool {
+		return compareSemver(versions[i], versions[j]) < 0
+	})
+	return versions, nil
+}
+
+// acceptsVersion reports whether v is a tagged version of the module's
+// major version, not checking whether a +incompatible one has a go.mod file.
+func (m *Module) acceptsVersion(v string) bool {
+	tag := strings.TrimSuffix(v, incompatibleSuffix)
+	if !isValidSemver(tag) || strings.Contains(tag, "+") || pseudoVersionRE.MatchString(tag) {
+		return false
+	}
+	return m.acceptsMajor(v)
+}
+
+// hasGoMod reports whether the module has a go.mod file at rev.
+func (m *Module) hasGoMod(rev string) (bool, error) {
+	_, ok, err := m.mirror.repo.readFile(rev, path.Join(m.dir, "go.mod"))
+	return ok, err
+}
+
+// latestVersion returns the highest release of versions, which are in semver
+// order, or the highest prerelease if there is no release.
+func latestVersion(versions []string) string {
+	latest := ""
+	for _, v := range versions {
+		if !isPrerelease(v) || latest == "" || isPrerelease(latest) {
+			latest = v
+		}
+	}
+	return latest
+}
+
+// resolve maps a canonical version to a git revision, fetching from upstream
+// if the version is not known locally yet.
+func (m *Module) resolve(version string) (string, error) {
+	if pseudoVersionRE.MatchString(version) {
+		if !m.acceptsMajor(version) {
+			return "", ErrNotFound
+		}
+		hash := version[strings.LastIndex(version, "-")+1:]
+		if i := strings.Index(hash, "+"); i >= 0 {
+			hash = hash[:i]
+		}
+
+		full, _, err := m.mirror.repo.commit(hash)
+		if err != nil {
+			if err := m.mirror.refresh(m.logger, m.proxy.clock.Now()); err != nil {
+				return "", err
+			}
+			if full, _, err = m.mirror.repo.commit(hash); err != nil {
+				return "", ErrNotFound
+			}
+		}
+		if strings.HasSuffix(version, incompatibleSuffix) {
+			if hasMod, err := m.hasGoMod(full); err != nil || hasMod {
+				return "", ErrNotFound
+			}
+		}
+		return full, nil
+	}
+
+	if err := m.ensureTag(version); err != nil {
+		return "", err
+	}
+	return m.tagRef(version), nil
+}
+
+// acceptsMajor reports whether the major version of version belongs to the
+// module: for module paths without a major version suffix that is 0 and 1,
+// or 2 and higher for +incompatible versions.
+func (m *Module) acceptsMajor(version string) bool {
+	incompatible := strings.HasSuffix(version, incompatibleSuffix)
+	major := semverMajor(strings.TrimSuffix(version, incompatibleSuffix))
+	if m.major >= 2 {
+		return major == m.major && !incompatible
+	}
+	if incompatible {
+		return major >= 2
+	}
+	return major == 0 || major == 1
+}
+
+func (m *Module) ensureTag(version string) error {
+	if !m.acceptsVersion(version) {
+		return ErrNotFound
+	}
+
+	if _, _, err := m.mirror.repo.commit(m.tagRef(version)); err == nil {
+		return m.checkIncompatible(version)
+	}
+	if err := m.mirror.refresh(m.logger, m.proxy.clock.Now()); err != nil {
+		return err
+	}
+	if _, _, err := m.mirror.repo.commit(m.tagRef(version)); err != nil {
+		return ErrNotFound
+	}
+	return m.checkIncompatible(version)
+}
+
+// checkIncompatible returns ErrNotFound for +incompatible versions whose tag
+// has a go.mod file, as those only exist with a major version suffix.
+func (m *Module) checkIncompatible(version string) error {
+	if !strings.HasSuffix(version, incompatibleSuffix) {
+		return nil
+	}
+	hasMod, err := m.hasGoMod(m.tagRef(version))
+	if err != nil {
+		return err
+	}
+	if hasMod {
+		return ErrNotFound
+	}
+	return nil
+}
+
+func (m *Module) tagInfo(version string) (Info, error) {
+	_, t, err := m.mirror.repo.commit(m.tagRef(version))
+	if err != nil {
+		return Info{}, err
+	}
+	return Info{Version: version, Time: t}, nil
+}
+
+// pseudoInfo returns the version of rev: the tag pointing at it if there is
+// one, and otherwise a pseudo-version based on the closest reachable tag.
+func (m *Module) pseudoInfo(rev string) (Info, error) {
+	hash, t, err := m.mirror.repo.commit(rev)
+	if err != nil {
+		return Info{}, err
+	}
+
+	tags, err := m.mirror.repo.mergedTags(hash)
+	if err != nil {
+		return Info{}, err
+	}
+
+	base := ""
+	for _, version := range m.tagVersions(tags) {
+		if m.acceptsVersion(version) && compareSemver(version, base) > 0 {
+			base = version
+		}
+	}
+
+	if base != "" {
+		tagHash, _, err := m.mirror.repo.commit(m.tagRef(base))
+		if err == nil && tagHash == hash {
+			return Info{Version: base, Time: t}, nil
+		}
+	}
+
+	return Info{Version: pseudoVersion(m.major, base, t, hash), Time: t}, nil
+}
+
+// tagRef returns the git ref of the tag for a version. Modules in a
+// subdirectory are tagged with the subdirectory as a prefix, e.g.
+// sdk/go/v1.0.0, and +incompatible versions without the suffix.
+func (m *Module) tagRef(version string) string {
+	version = strings.TrimSuffix(version, incompatibleSuffix)
+	if m.dir == "" {
+		return "refs/tags/" + version
+	}
+	return "refs/tags/" + m.dir + "/" + version
+}
+
+// tagVersions returns the versions of the tags that belong to the module's
+// subdirectory.
+func (m *Module) tagVersions(tags []string) []string {
+	if m.dir == "" {
+		return tags
+	}
+
+	var versions []string
+	for _, tag := range tags {
+		if strings.HasPrefix(tag, m.dir+"/") {
+			versions = append(versions, strings.TrimPrefix(tag, m.dir+"/"))
+		}
+	}
+	return versions
+}
+
+func pseudoVersion(major int, base string, t time.Time, hash string) string {
+	suffix := t.UTC().Format("20060102150405") + "-" + hash[:12]
+
+	if base == "" {
+		if major < 2 {
+			major = 0
+		}
+		return fmt.Sprintf("v%d.0.0-%s", major, suffix)
+	}
+
+	sv, _ := parseSemver(base)
+	if sv.prerelease != "" {
+		return fmt.Sprintf("v%s.%s.%s-%s.0.%s", sv.major, sv.minor, sv.patch, sv.prerelease, suffix)
+	}
+
+	patch, _ := strconv.Atoi(sv.patch)
+	return fmt.Sprintf("v%s.%s.%d-0.%s", sv.major, sv.minor, patch+1, suffix)
+}
+
+// pathMajor returns the major version encoded in a module path suffix such
+// as /v2, or 0 if there is none.
+func pathMajor(modulePath string) int {
+	i := strings.LastIndex(modulePath, "/")
+	if i < 0 {
+		return 0
+	}
+
+	suffix := modulePath[i+1:]
+	if len(suffix) < 2 || suffix[0] != 'v' || !isNumber(suffix[1:]) {
+		return 0
+	}
+
+	n, err := strconv.Atoi(suffix[1:])
+	if err != nil || n < 2 {
+		return 0
+	}
+	return n
+}
@@ -0,0 +1,109 @@
+package proxy
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"code.cloudfoundry.org/clock"
+	"code.cloudfoundry.org/lager"
+)
+
+// RefreshInterval is the minimum time between two fetches of the same
+// upstream repository.
+const RefreshInterval = time.Minute
+
+// ErrNotFound is returned when a module version does not exist upstream.
+var ErrNotFound = errors.New("not found")
+
+// Info is the JSON document served for the .info and @latest endpoints of the
+// module proxy protocol.
+type Info struct {
+	Version string
+	Time    time.Time
+}
+
+// Proxy builds Go modules from the tags of upstream git repositories, using
+// bare clones stored below a local directory.
+type Proxy struct {
+	logger lager.Logger
+	dir    string
+	clock  clock.Clock
+
+	lock    sync.Mutex
+	mirrors map[string]*mirror
+}
+
+type mirror struct {
+	lock      sync.Mutex
+	repo      *gitRepo
+	location  string
+	fetchedAt time.Time
+}
+
+func NewProxy(logger lager.Logger, dir string, clock clock.Clock) *Proxy {
+	return &Proxy{
+		logger:  logger,
+		dir:     dir,
+		clock:   clock,
+		mirrors: map[string]*mirror{},
+	}
+}
+
+// Module returns the module at modulePath whose sources live in the
+// repository at location, in the directory dir or at the root if dir is
+// empty.
+func (p *Proxy) Module(modulePath, location, dir string) *Module {
+	return &Module{
+		path:   modulePath,
+		dir:    strings.Trim(dir, "/"),
+		major:  pathMajor(modulePath),
+		mirror: p.mirror(location),
+		proxy:  p,
+		logger: p.logger.Session("module", lager.Data{"module": modulePath, "location": location}),
+	}
+}
+
+func (p *Proxy) mirror(location string) *mirror {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+
+	if m, ok := p.mirrors[location]; ok {
+		return m
+	}
+
+	sum := sha256.Sum256([]byte(location))
+	m := &mirror{
+		repo:     &gitRepo{dir: filepath.Join(p.dir, hex.EncodeToString(sum[:])+".git")},
+		location: location,
+	}
+	p.mirrors[location] = m
+	return m
+}
+
+// refresh fetches from upstream unless that has already happened within the
+// RefreshInterval. The caller must hold the mirror lock.
+func (m *mirror) refresh(logger lager.Logger, now time.Time) error {
+	if !m.fetchedAt.IsZero() && now.Sub(m.fetchedAt) < RefreshInterval {
+		return nil
+	}
+
+	if !m.repo.exists() {
+		logger.Info("cloning", lager.Data{"dir": m.repo.dir})
+		if err := m.repo.init(m.location); err != nil {
+			return err
+		}
+	}
+
+	logger.Debug("fetching")
+	if err := m.repo.fetch(); err != nil {
+		logger.Error("failed-fetching", err)
+		return err
+	}
+	m.fetchedAt = now
+	return nil
+}
@@ -0,0 +1,400 @@
+package proxy_test
+
+import (
+	"archive/zip"
+	"bytes"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"code.cloudfoundry.org/clock/fakeclock"
+	"code.cloudfoundry.org/lager/lagertest"
+	"github.com/cloudfoundry/go-fetcher/proxy"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Proxy", func() {
+	var (
+		tmpDir      string
+		upstream    string
+		fakeClock   *fakeclock.FakeClock
+		moduleProxy *proxy.Proxy
+		module      *proxy.Module
+		commitTime  time.Time
+	)
+
+	git := func(args ...string) string {
+		cmd := exec.Command("git", args...)
+		cmd.Dir = upstream
+		cmd.Env = append(os.Environ(),
+			"GIT_AUTHOR_NAME=test",
+			"GIT_AUTHOR_EMAIL=test@example.com",
+			"GIT_COMMITTER_NAME=test",
+			"GIT_COMMITTER_EMAIL=test@example.com",
+			"GIT_AUTHOR_DATE="+commitTime.Format(time.RFC3339),
+			"GIT_COMMITTER_DATE="+commitTime.Format(time.RFC3339),
+		)
+		out, err := cmd.CombinedOutput()
+		Expect(err).NotTo(HaveOccurred(), string(out))
+		return string(bytes.TrimSpace(out))
+	}
+
+	writeFile := func(name, contents string) {
+		path := filepath.Join(upstream, name)
+		Expect(os.MkdirAll(filepath.Dir(path), 0755)).To(Succeed())
+		Expect(ioutil.WriteFile(path, []byte(contents), 0644)).To(Succeed())
+	}
+
+	commit := func(message string) string {
+		commitTime = commitTime.Add(time.Hour)
+		git("add", "-A")
+		git("commit", "--quiet", "-m", message)
+		return git("rev-parse", "HEAD")
+	}
+
+	BeforeEach(func() {
+		var err error
+		tmpDir, err = ioutil.TempDir("", "proxy")
+		Expect(err).NotTo(HaveOccurred())
+
+		commitTime = time.Date(2019, 6, 1, 12, 0, 0, 0, time.UTC)
+		upstream = filepath.Join(tmpDir, "upstream")
+		Expect(os.MkdirAll(upstream, 0755)).To(Succeed())
+		git("init", "--quiet")
+		git("symbolic-ref", "HEAD", "refs/heads/master")
+
+		fakeClock = fakeclock.NewFakeClock(time.Now())
+		moduleProxy = proxy.NewProxy(lagertest.NewTestLogger("proxy"), filepath.Join(tmpDir, "mirrors"), fakeClock)
+		module = moduleProxy.Module("example.com/repo", upstream, "")
+	})
+
+	AfterEach(func() {
+		Expect(os.RemoveAll(tmpDir)).To(Succeed())
+	})
+
+	Context("when the repository has version tags", func() {
+		BeforeEach(func() {
+			writeFile("go.mod", "module example.com/repo\n")
+			writeFile("repo.go", "package repo\n")
+			commit("first")
+			git("tag", "v1.0.0")
+			git("tag", "not-a-version")
+
+			writeFile("repo.go", "package repo\n\nconst Version = 2\n")
+			commit("second")
+			git("tag", "-a", "-m", "annotated", "v1.10.0")
+			git("tag", "v1.2.0-rc.1")
+			git("tag", "v2.0.0")
+		})
+
+		It("lists the versions for the module's major version in semver order", func() {
+			versions, err := module.List()
+			Expect(err).NotTo(HaveOccurred())
+			Expect(versions).To(Equal([]string{"v1.0.0", "v1.2.0-rc.1", "v1.10.0"}))
+		})
+
+		It("lists the versions of a major version suffix separately", func() {
+			versions, err := moduleProxy.Module("example.com/repo/v2", upstream, "").List()
+			Expect(err).NotTo(HaveOccurred())
+			Expect(versions).To(Equal([]string{"v2.0.0"}))
+		})
+
+		It("returns the highest release as the latest version", func() {
+			info, err := module.Latest()
+			Expect(err).NotTo(HaveOccurred())
+			Expect(info.Version).To(Equal("v1.10.0"))
+			Expect(info.Time).To(Equal(time.Date(2019, 6, 1, 14, 0, 0, 0, time.UTC)))
+		})
+
+		It("returns the commit time of a tag", func() {
+			info, err := module.Info("v1.0.0")
+			Expect(err).NotTo(HaveOccurred())
+			Expect(info).To(Equal(proxy.Info{Version: "v1.0.0", Time: time.Date(2019, 6, 1, 13, 0, 0, 0, time.UTC)}))
+		})
+
+		It("returns not found for unknown versions", func() {
+			_, err := module.Info("v1.5.0")
+			Expect(err).To(Equal(proxy.ErrNotFound))
+
+			_, err = module.Mod("v2.0.0")
+			Expect(err).To(Equal(proxy.ErrNotFound))
+		})
+
+		It("returns the go.mod of a version", func() {
+			mod, err := module.Mod("v1.0.0")
+			Expect(err).NotTo(HaveOccurred())
+			Expect(string(mod)).To(Equal("module example.com/repo\n"))
+		})
+
+		It("resolves branches to the tag pointing at them", func() {
+			info, err := module.Info("master")
+			Expect(err).NotTo(HaveOccurred())
+			Expect(info.Version).To(Equal("v1.10.0"))
+		})
+
+		It("picks up tags pushed after the last fetch once the refresh interval passed", func() {
+			_, err := module.List()
+			Expect(err).NotTo(HaveOccurred())
+
+			git("tag", "v1.11.0")
+
+			versions, err := module.List()
+			Expect(err).NotTo(HaveOccurred())
+			Expect(versions).NotTo(ContainElement("v1.11.0"))
+
+			fakeClock.Increment(proxy.RefreshInterval)
+
+			versions, err = module.List()
+			Expect(err).NotTo(HaveOccurred())
+			Expect(versions).To(ContainElement("v1.11.0"))
+		})
+
+		Context("when there are commits after the latest tag", func() {
+			var hash string
+
+			BeforeEach(func() {
+				writeFile("new.go", "package repo\n")
+				hash = commit("third")
+			})
+
+			It("resolves branches to a pseudo-version", func() {
+				info, err := module.Info("master")
+				Expect(err).NotTo(HaveOccurred())
+				Expect(info.Version).To(Equal("v1.10.1-0.20190601150000-" + hash[:12]))
+			})
+
+			It("serves the go.mod and zip of pseudo-versions", func() {
+				version := "v1.10.1-0.20190601150000-" + hash[:12]
+
+				info, err := module.Info(version)
+				Expect(err).NotTo(HaveOccurred())
+				Expect(info.Version).To(Equal(version))
+
+				_, err = module.Mod(version)
+				Expect(err).NotTo(HaveOccurred())
+
+				var buf bytes.Buffer
+				Expect(module.Zip(version, &buf)).To(Succeed())
+				Expect(zipFiles(buf.Bytes())).To(ContainElement("example.com/repo@" + version + "/new.go"))
+			})
+		})
+
+		Describe("Zip", func() {
+			BeforeEach(func() {
+				writeFile("vendor/modules.txt", "# vendored\n")
+				writeFile("vendor/example.org/dep/dep.go", "package dep\n")
+				writeFile("nested/go.mod", "module example.com/repo/nested\n")
+				writeFile("nested/nested.go", "package nested\n")
+				writeFile("sub/sub.go", "package sub\n")
+				Expect(os.Symlink("repo.go", filepath.Join(upstream, "link.go"))).To(Succeed())
+				commit("layout")
+				git("tag", "v1.11.0")
+			})
+
+			It("contains the module files below the module@version prefix", func() {
+				var buf bytes.Buffer
+				Expect(module.Zip("v1.11.0", &buf)).To(Succeed())
+
+				Expect(zipFiles(buf.Bytes())).To(Equal([]string{
+					"example.com/repo@v1.11.0/go.mod",
+					"example.com/repo@v1.11.0/repo.go",
+					"example.com/repo@v1.11.0/sub/sub.go",
+					"example.com/repo@v1.11.0/vendor/modules.txt",
+				}))
+			})
+
+			It("rejects files whose paths only differ in case", func() {
+				writeFile("Sub/other.go", "package sub\n")
+				commit("collision")
+				git("tag", "v1.12.0")
+
+				var buf bytes.Buffer
+				err := module.Zip("v1.12.0", &buf)
+				Expect(err).To(BeAssignableToTypeOf(&proxy.InvalidZipError{}))
+				Expect(err.Error()).To(ContainSubstring("case-insensitive file name collision"))
+				Expect(buf.Len()).To(BeZero())
+			})
+
+			It("rejects file paths the go command does not accept", func() {
+				writeFile("sub/aux.go", "package sub\n")
+				commit("reserved name")
+				git("tag", "v1.12.0")
+
+				var buf bytes.Buffer
+				err := module.Zip("v1.12.0", &buf)
+				Expect(err).To(BeAssignableToTypeOf(&proxy.InvalidZipError{}))
+				Expect(err.Error()).To(ContainSubstring(`malformed file path "sub/aux.go"`))
+				Expect(buf.Len()).To(BeZero())
+			})
+
+			It("rejects LICENSE files larger than the go command accepts", func() {
+				writeFile("LICENSE", strings.Repeat("license\n", 17<<20/8))
+				commit("large license")
+				git("tag", "v1.12.0")
+
+				var buf bytes.Buffer
+				err := module.Zip("v1.12.0", &buf)
+				Expect(err).To(BeAssignableToTypeOf(&proxy.InvalidZipError{}))
+				Expect(err.Error()).To(ContainSubstring("LICENSE file too large"))
+				Expect(buf.Len()).To(BeZero())
+			})
+		})
+	})
+
+	Context("when the module is in a subdirectory of the repository", func() {
+		BeforeEach(func() {
+			writeFile("go.mod", "module example.com/repo\n")
+			writeFile("sdk/go/go.mod", "module example.com/sdk\n")
+			writeFile("sdk/go/sdk.go", "package sdk\n")
+			commit("first")
+			git("tag", "v1.0.0")
+			git("tag", "sdk/go/v0.1.0")
+
+			module = moduleProxy.Module("example.com/sdk", upstream, "sdk/go")
+		})
+
+		It("lists the versions tagged with the subdirectory prefix", func() {
+			versions, err := module.List()
+			Expect(err).NotTo(HaveOccurred())
+			Expect(versions).To(Equal([]string{"v0.1.0"}))
+		})
+
+		It("returns the go.mod of the subdirectory", func() {
+			mod, err := module.Mod("v0.1.0")
+			Expect(err).NotTo(HaveOccurred())
+			Expect(string(mod)).To(Equal("module example.com/sdk\n"))
+		})
+
+		It("only zips the subdirectory", func() {
+			var buf bytes.Buffer
+			Expect(module.Zip("v0.1.0", &buf)).To(Succeed())
+			Expect(zipFiles(buf.Bytes())).To(Equal([]string{
+				"example.com/sdk@v0.1.0/go.mod",
+				"example.com/sdk@v0.1.0/sdk.go",
+			}))
+		})
+	})
+
+	Context("when the repository has tags of major version 2 or higher without a go.mod file", func() {
+		BeforeEach(func() {
+			writeFile("repo.go", "package repo\n")
+			commit("first")
+			git("tag", "v1.0.0")
+			git("tag", "v2.0.0")
+
+			writeFile("repo.go", "package repo\n\nconst Version = 2\n")
+			commit("second")
+			git("tag", "v2.1.0")
+
+			writeFile("go.mod", "module example.com/repo/v3\n")
+			commit("third")
+			git("tag", "v3.0.0")
+		})
+
+		It("lists them as +incompatible versions", func() {
+			versions, err := module.List()
+			Expect(err).NotTo(HaveOccurred())
+			Expect(versions).To(Equal([]string{"v1.0.0", "v2.0.0+incompatible", "v2.1.0+incompatible"}))
+		})
+
+		It("serves the +incompatible versions", func() {
+			info, err := module.Info("v2.1.0+incompatible")
+			Expect(err).NotTo(HaveOccurred())
+			Expect(info).To(Equal(proxy.Info{Version: "v2.1.0+incompatible", Time: time.Date(2019, 6, 1, 14, 0, 0, 0, time.UTC)}))
+
+			mod, err := module.Mod("v2.1.0+incompatible")
+			Expect(err).NotTo(HaveOccurred())
+			Expect(string(mod)).To(Equal("module example.com/repo\n"))
+
+			var buf bytes.Buffer
+			Expect(module.Zip("v2.1.0+incompatible", &buf)).To(Succeed())
+			Expect(zipFiles(buf.Bytes())).To(Equal([]string{"example.com/repo@v2.1.0+incompatible/repo.go"}))
+		})
+
+		It("does not serve them without the +incompatible suffix", func() {
+			_, err := module.Info("v2.1.0")
+			Expect(err).To(Equal(proxy.ErrNotFound))
+		})
+
+		It("does not serve tags with a go.mod file as +incompatible versions", func() {
+			_, err := module.Info("v3.0.0+incompatible")
+			Expect(err).To(Equal(proxy.ErrNotFound))
+
+			versions, err := moduleProxy.Module("example.com/repo/v3", upstream, "").List()
+			Expect(err).NotTo(HaveOccurred())
+			Expect(versions).To(Equal([]string{"v3.0.0"}))
+		})
+
+		It("returns the highest +incompatible version as the latest version", func() {
+			info, err := module.Latest()
+			Expect(err).NotTo(HaveOccurred())
+			Expect(info.Version).To(Equal("v2.1.0+incompatible"))
+		})
+
+		It("prefers the latest compatible version once it has a go.mod file", func() {
+			writeFile("go.mod", "module example.com/repo\n")
+			commit("fourth")
+			git("tag", "v1.1.0")
+
+			info, err := module.Latest()
+			Expect(err).NotTo(HaveOccurred())
+			Expect(info.Version).To(Equal("v1.1.0"))
+		})
+	})
+
+	Context("when the repository has no tags", func() {
+		var hash string
+
+		BeforeEach(func() {
+			writeFile("repo.go", "package repo\n")
+			hash = commit("first")
+		})
+
+		It("lists no versions", func() {
+			versions, err := module.List()
+			Expect(err).NotTo(HaveOccurred())
+			Expect(versions).To(BeEmpty())
+		})
+
+		It("returns a pseudo-version of the default branch as the latest version", func() {
+			info, err := module.Latest()
+			Expect(err).NotTo(HaveOccurred())
+			Expect(info.Version).To(Equal("v0.0.0-20190601130000-" + hash[:12]))
+		})
+
+		It("synthesizes a go.mod", func() {
+			mod, err := module.Mod("v0.0.0-20190601130000-" + hash[:12])
+			Expect(err).NotTo(HaveOccurred())
+			Expect(string(mod)).To(Equal("module example.com/repo\n"))
+		})
+	})
+
+	Context("when the upstream does not exist", func() {
+		BeforeEach(func() {
+			module = moduleProxy.Module("example.com/missing", filepath.Join(tmpDir, "missing"), "")
+		})
+
+		It("returns an error", func() {
+			_, err := module.List()
+			Expect(err).To(HaveOccurred())
+		})
+	})
+})
+
+func zipFiles(contents []byte) []string {
+	reader, err := zip.NewReader(bytes.NewReader(contents), int64(len(contents)))
+	Expect(err).NotTo(HaveOccurred())
+
+	var names []string
+	for _, f := range reader.File {
+		names = append(names, f.Name)
+	}
+	sort.Strings(names)
+	return names
+}
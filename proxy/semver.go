@@ -0,0 +1,190 @@
+package proxy
+
+import (
+	"strconv"
+	"strings"
+)
+
+// semver holds the parsed form of a semantic version tag such as
+// v1.2.3-rc.1+build. Only the subset of semver used by Go modules is
+// supported: the leading "v" is required and the build suffix is ignored
+// when comparing.
+type semver struct {
+	major, minor, patch string
+	prerelease          string
+}
+
+func parseSemver(v string) (semver, bool) {
+	var sv semver
+	if !strings.HasPrefix(v, "v") {
+		return sv, false
+	}
+	v = v[1:]
+
+	if i := strings.Index(v, "+"); i >= 0 {
+		if !validIdentifiers(v[i+1:], false) {
+			return sv, false
+		}
+		v = v[:i]
+	}
+
+	if i := strings.Index(v, "-"); i >= 0 {
+		sv.prerelease = v[i+1:]
+		if !validIdentifiers(sv.prerelease, true) {
+			return sv, false
+		}
+		v = v[:i]
+	}
+
+	parts := strings.Split(v, ".")
+	if len(parts) != 3 {
+		return sv, false
+	}
+	for _, p := range parts {
+		if !isNumber(p) {
+			return sv, false
+		}
+	}
+	sv.major, sv.minor, sv.patch = parts[0], parts[1], parts[2]
+	return sv, true
+}
+
+// isValidSemver reports whether v is a complete semantic version that can be
+// used as a module version.
+func isValidSemver(v string) bool {
+	_, ok := parseSemver(v)
+	return ok
+}
+
+// semverMajor returns the major version number of v, or -1 if v is invalid.
+func semverMajor(v string) int {
+	sv, ok := parseSemver(v)
+	if !ok {
+		return -1
+	}
+	n, err := strconv.Atoi(sv.major)
+	if err != nil {
+		return -1
+	}
+	return n
+}
+
+func isPrerelease(v string) bool {
+	sv, ok := parseSemver(v)
+	return ok && sv.prerelease != ""
+}
+
+// compareSemver returns -1, 0 or 1 depending on whether a is lower than,
+// equal to or greater than b. Invalid versions sort before valid ones.
+func compareSemver(a, b string) int {
+	sa, okA := parseSemver(a)
+	sb, okB := parseSemver(b)
+	switch {
+	case !okA && !okB:
+		return 0
+	case !okA:
+		return -1
+	case !okB:
+		return 1
+	}
+
+	if c := compareNumber(sa.major, sb.major); c != 0 {
+		return c
+	}
+	if c := compareNumber(sa.minor, sb.minor); c != 0 {
+		return c
+	}
+	if c := compareNumber(sa.patch, sb.patch); c != 0 {
+		return c
+	}
+	return comparePrerelease(sa.prerelease, sb.prerelease)
+}
+
+func comparePrerelease(a, b string) int {
+	if a == b {
+		return 0
+	}
+	// a release is always greater than any of its prereleases
+	if a == "" {
+		return 1
+	}
+	if b == "" {
+		return -1
+	}
+
+	as := strings.Split(a, ".")
+	bs := strings.Split(b, ".")
+	for i := 0; i < len(as) && i < len(bs); i++ {
+		if as[i] == bs[i] {
+			continue
+		}
+		aNum, bNum := isNumber(as[i]), isNumber(bs[i])
+		switch {
+		case aNum && bNum:
+			return compareNumber(as[i], bs[i])
+		case aNum:
+			return -1
+		case bNum:
+			return 1
+		case as[i] < bs[i]:
+			return -1
+		default:
+			return 1
+		}
+	}
+
+	switch {
+	case len(as) < len(bs):
+		return -1
+	case len(as) > len(bs):
+		return 1
+	}
+	return 0
+}
+
+func compareNumber(a, b string) int {
+	if len(a) != len(b) {
+		if len(a) < len(b) {
+			return -1
+		}
+		return 1
+	}
+	return strings.Compare(a, b)
+}
+
+func isNumber(s string) bool {
+	if s == "" || (len(s) > 1 && s[0] == '0') {
+		return false
+	}
+	for _, r := range s {
+		if r < '0' || r > '9' {
+			return false
+		}
+	}
+	return true
+}
+
+func validIdentifiers(s string, numericLeadingZeros bool) bool {
+	if s == "" {
+		return false
+	}
+	for _, ident := range strings.Split(s, ".") {
+		if ident == "" {
+			return false
+		}
+		allDigits := true
+		for _, r := range ident {
+			switch {
+			case r >= '0' && r <= '9':
+			case r >= 'a' && r <= 'z', r >= 'A' && r <= 'Z', r == '-':
+				allDigits = false
+			default:
+				return false
+			}
+		}
+		if numericLeadingZeros && allDigits && len(ident) > 1 && ident[0] == '0' {
+			return false
+		}
+	}
+	return true
+}
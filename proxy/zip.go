@@ -0,0 +1,261 @@
+package proxy
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"errors"
+	"fmt"
+	"io"
+	"path"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// The limits of the go command on module zips: the total size of their files,
+// and the size of the go.mod and LICENSE files.
+const (
+	maxZipSize     = 500 << 20
+	maxGoModSize   = 16 << 20
+	maxLicenseSize = 16 << 20
+)
+
+// InvalidZipError is returned when the files of a version cannot be served as
+// a module zip, because the go command would reject it.
+type InvalidZipError struct {
+	Reason string
+}
+
+func (e *InvalidZipError) Error() string {
+	return "invalid module zip: " + e.Reason
+}
+
+// badWindowsNames are the names, up to the first dot, that the go command
+// rejects as path elements because Windows reserves them.
+var badWindowsNames = []string{
+	"CON", "PRN", "AUX", "NUL",
+	"COM1", "COM2", "COM3", "COM4", "COM5", "COM6", "COM7", "COM8", "COM9",
+	"LPT1", "LPT2", "LPT3", "LPT4", "LPT5", "LPT6", "LPT7", "LPT8", "LPT9",
+}
+
+// files returns the paths in the repository of the files at rev that belong
+// in the module zip, checking them the way the go command checks the files of
+// a module zip before anything is written.
+func (m *Module) files(rev string) (map[string]bool, error) {
+	entries, err := m.mirror.repo.listTree(rev, m.dir, true)
+	if err != nil {
+		return nil, err
+	}
+
+	names := map[string]treeEntry{}
+	nestedModules := map[string]bool{}
+	for _, entry := range entries {
+		// symlinks and submodules are never part of a module zip
+		if entry.mode != "100644" && entry.mode != "100755" {
+			continue
+		}
+
+		name := entry.path
+		if m.dir != "" {
+			if !strings.HasPrefix(name, m.dir+"/") {
+				continue
+			}
+			name = strings.TrimPrefix(name, m.dir+"/")
+		}
+		names[name] = entry
+
+		if dir, file := path.Split(name); file == "go.mod" && dir != "" {
+			nestedModules[dir] = true
+		}
+	}
+
+	files := map[string]bool{}
+	folded := map[string]string{}
+	var size int64
+	for name, entry := range names {
+		if isVendoredPackage(name) || inNestedModule(name, nestedModules) {
+			continue
+		}
+
+		if err := checkFilePath(name); err != nil {
+			return nil, &InvalidZipError{Reason: fmt.Sprintf("malformed file path %q: %s", name, err)}
+		}
+		for p := name; p != "."; p = path.Dir(p) {
+			fold := toFold(p)
+			if other, ok := folded[fold]; ok && other != p {
+				return nil, &InvalidZipError{Reason: fmt.Sprintf("case-insensitive file name collision: %q and %q", other, p)}
+			}
+			folded[fold] = p
+		}
+
+		switch {
+		case name == "go.mod" && entry.size > maxGoModSize:
+			return nil, &InvalidZipError{Reason: fmt.Sprintf("go.mod file too large (max size is %d bytes)", maxGoModSize)}
+		case name == "LICENSE" && entry.size > maxLicenseSize:
+			return nil, &InvalidZipError{Reason: fmt.Sprintf("LICENSE file too large (max size is %d bytes)", maxLicenseSize)}
+		}
+		size += entry.size
+		if size > maxZipSize {
+			return nil, &InvalidZipError{Reason: fmt.Sprintf("total size of files exceeds %d bytes", maxZipSize)}
+		}
+
+		files[entry.path] = true
+	}
+	return files, nil
+}
+
+// isVendoredPackage reports whether name is a file in a vendored package.
+// Files directly inside a vendor directory, such as vendor/modules.txt, are
+// kept, as the go command does.
+func isVendoredPackage(name string) bool {
+	var i int
+	if strings.HasPrefix(name, "vendor/") {
+		i += len("vendor/")
+	} else if j := strings.Index(name, "/vendor/"); j >= 0 {
+		i += j + len("/vendor/")
+	} else {
+		return false
+	}
+	return strings.Contains(name[i:], "/")
+}
+
+func inNestedModule(name string, nestedModules map[string]bool) bool {
+	for dir := range nestedModules {
+		if strings.HasPrefix(name, dir) {
+			return true
+		}
+	}
+	return false
+}
+
+// writeZip streams the files of the archive of the tree at rev to w as a zip,
+// below the prefix. files are the paths in the repository to include.
+func (m *Module) writeZip(w io.Writer, rev, prefix string, files map[string]bool) error {
+	archive, archiveWriter := io.Pipe()
+	go func() {
+		archiveWriter.CloseWithError(m.mirror.repo.archive(rev, m.dir, archiveWriter))
+	}()
+	// stops git archive if the zip is not written to the end
+	defer archive.Close()
+
+	zw := zip.NewWriter(&limitedWriter{w: w, remaining: maxZipSize})
+	reader := tar.NewReader(archive)
+	for {
+		header, err := reader.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return err
+		}
+		if header.Typeflag != tar.TypeReg || !files[header.Name] {
+			continue
+		}
+
+		name := header.Name
+		if m.dir != "" {
+			name = strings.TrimPrefix(name, m.dir+"/")
+		}
+		f, err := zw.Create(prefix + "/" + name)
+		if err != nil {
+			return err
+		}
+		if _, err := io.Copy(f, reader); err != nil {
+			return err
+		}
+	}
+	return zw.Close()
+}
+
+var errZipTooLarge = fmt.Errorf("module zip exceeds %d bytes", maxZipSize)
+
+// limitedWriter fails writes once more than remaining bytes were written.
+type limitedWriter struct {
+	w         io.Writer
+	remaining int64
+}
+
+func (l *limitedWriter) Write(p []byte) (int, error) {
+	if int64(len(p)) > l.remaining {
+		return 0, errZipTooLarge
+	}
+	n, err := l.w.Write(p)
+	l.remaining -= int64(n)
+	return n, err
+}
+
+// checkFilePath checks a path of a file in a module zip like the go command
+// does: it has to be valid UTF-8, made of non-empty elements other than . and
+// .. that do not end in a dot, are not reserved on Windows, and only use
+// letters, digits and a few punctuation characters.
+func checkFilePath(name string) error {
+	if !utf8.ValidString(name) {
+		return errors.New("invalid UTF-8")
+	}
+	for _, elem := range strings.Split(name, "/") {
+		if err := checkPathElement(elem); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func checkPathElement(elem string) error {
+	if elem == "" {
+		return errors.New("empty path element")
+	}
+	if strings.Count(elem, ".") == len(elem) {
+		return fmt.Errorf("invalid path element %q", elem)
+	}
+	if elem[len(elem)-1] == '.' {
+		return errors.New("trailing dot in path element")
+	}
+	for _, r := range elem {
+		if !fileNameOK(r) {
+			return fmt.Errorf("invalid char %q", r)
+		}
+	}
+
+	short := elem
+	if i := strings.Index(short, "."); i >= 0 {
+		short = short[:i]
+	}
+	for _, bad := range badWindowsNames {
+		if strings.EqualFold(bad, short) {
+			return fmt.Errorf("%q disallowed as path element component on Windows", short)
+		}
+	}
+	// names such as PROGRA~1 are short names of other files on Windows
+	if tilde := strings.LastIndexByte(short, '~'); tilde >= 0 && tilde < len(short)-1 && strings.Trim(short[tilde+1:], "0123456789") == "" {
+		return errors.New("trailing tilde and digits in path element")
+	}
+	return nil
+}
+
+func fileNameOK(r rune) bool {
+	if r < utf8.RuneSelf {
+		const allowed = "!#$%&()+,-.=@[]^_{}~ "
+		return '0' <= r && r <= '9' || 'A' <= r && r <= 'Z' || 'a' <= r && r <= 'z' || strings.ContainsRune(allowed, r)
+	}
+	return unicode.IsLetter(r)
+}
+
+// toFold returns the case folded form of s, which is equal for strings that
+// only differ in case.
+func toFold(s string) string {
+	var b strings.Builder
+	for _, r := range s {
+		// SimpleFold cycles through the runes that are equal ignoring case,
+		// so this stops at the smallest one
+		for {
+			next := unicode.SimpleFold(r)
+			if next <= r {
+				r = next
+				break
+			}
+			r = next
+		}
+		b.WriteRune(r)
+	}
+	return b.String()
+}